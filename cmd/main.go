@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"strconv"
+	"time"
 
 	"github.com/joho/godotenv"
 )
@@ -16,12 +18,164 @@ func main() {
 		panic("Error loading .env file")
 	}
 
+	// Subcomando para generar JWT de prueba sin depender del gateway
+	if len(os.Args) > 1 && os.Args[1] == "token" {
+		if err := runToken(os.Args[2:]); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	token := os.Getenv("Token")
 	port := os.Getenv("Port")
 
+	// Cantidad de generaciones .bak del archivo de datos (opcional)
+	var backups int
+	if backupsStr := os.Getenv("StorageBackups"); backupsStr != "" {
+		backups, err = strconv.Atoi(backupsStr)
+		if err != nil {
+			panic("El valor de StorageBackups debe ser un número entero")
+		}
+	}
+
+	// Archivo de datos y su formato; si no se indica el formato se deduce de la extensión
+	storageFile := os.Getenv("StorageFile")
+	if storageFile == "" {
+		storageFile = "./docs/db/products.json"
+	}
+	storageType := os.Getenv("StorageType")
+
+	// Cantidad de mutaciones del journal a partir de la cual se compacta (opcional)
+	var compactEvery int
+	if compactEveryStr := os.Getenv("JournalCompactEvery"); compactEveryStr != "" {
+		compactEvery, err = strconv.Atoi(compactEveryStr)
+		if err != nil {
+			panic("El valor de JournalCompactEvery debe ser un número entero")
+		}
+	}
+
+	// Claves de API y permisos; sin keyring se usa el Token como única clave
+	keyringPath := os.Getenv("KeyringPath")
+	authReads := os.Getenv("AuthReads") == "true"
+
+	// Verificación de JWT firmados con HS256 por el gateway (opcional)
+	jwtSecret := os.Getenv("JWTSecret")
+	jwtIssuer := os.Getenv("JWTIssuer")
+	jwtAudience := os.Getenv("JWTAudience")
+
+	// Exigir If-Match en las modificaciones para evitar sobrescrituras concurrentes
+	requireIfMatch := os.Getenv("RequireIfMatch") == "true"
+
+	// Retención de la papelera en días y cada cuánto se vacía (opcionales)
+	var trashRetentionDays int
+	if trashRetentionStr := os.Getenv("TrashRetentionDays"); trashRetentionStr != "" {
+		trashRetentionDays, err = strconv.Atoi(trashRetentionStr)
+		if err != nil {
+			panic("El valor de TrashRetentionDays debe ser un número entero")
+		}
+	}
+
+	var trashPurgeInterval time.Duration
+	if trashPurgeIntervalStr := os.Getenv("TrashPurgeInterval"); trashPurgeIntervalStr != "" {
+		trashPurgeInterval, err = time.ParseDuration(trashPurgeIntervalStr)
+		if err != nil {
+			panic("El valor de TrashPurgeInterval debe ser una duración, por ejemplo 1h")
+		}
+	}
+
+	// Registro de auditoría de las mutaciones de productos (opcional)
+	auditFile := os.Getenv("AuditFile")
+
+	// Cada cuánto se ponen en vigencia los precios programados (opcional)
+	var priceScheduleInterval time.Duration
+	if priceScheduleIntervalStr := os.Getenv("PriceScheduleInterval"); priceScheduleIntervalStr != "" {
+		priceScheduleInterval, err = time.ParseDuration(priceScheduleIntervalStr)
+		if err != nil {
+			panic("El valor de PriceScheduleInterval debe ser una duración, por ejemplo 1m")
+		}
+	}
+
+	// Tabla de cotizaciones y cada cuánto se vuelve a leer (opcionales)
+	exchangeRatesFile := os.Getenv("ExchangeRatesFile")
+	var exchangeRatesReloadInterval time.Duration
+	if reloadIntervalStr := os.Getenv("ExchangeRatesReloadInterval"); reloadIntervalStr != "" {
+		exchangeRatesReloadInterval, err = time.ParseDuration(reloadIntervalStr)
+		if err != nil {
+			panic("El valor de ExchangeRatesReloadInterval debe ser una duración, por ejemplo 1m")
+		}
+	}
+
+	// Árbol de categorías y depósitos (opcionales)
+	categoriesFile := os.Getenv("CategoriesFile")
+	warehousesFile := os.Getenv("WarehousesFile")
+
+	// Libro de stock y si los egresos pueden dejar existencias negativas (opcionales)
+	stockMovementsFile := os.Getenv("StockMovementsFile")
+	allowNegativeStock := os.Getenv("AllowNegativeStock") == "true"
+
+	// Cada cuánto se despublican los productos vencidos (opcional)
+	var expirationCheckInterval time.Duration
+	if expirationCheckIntervalStr := os.Getenv("ExpirationCheckInterval"); expirationCheckIntervalStr != "" {
+		expirationCheckInterval, err = time.ParseDuration(expirationCheckIntervalStr)
+		if err != nil {
+			panic("El valor de ExpirationCheckInterval debe ser una duración, por ejemplo 1h")
+		}
+	}
+
+	// Reservas de stock: archivo, plazo por defecto y cada cuánto se cierran las vencidas (opcionales)
+	reservationsFile := os.Getenv("ReservationsFile")
+	var reservationTTL time.Duration
+	if reservationTTLStr := os.Getenv("ReservationTTL"); reservationTTLStr != "" {
+		reservationTTL, err = time.ParseDuration(reservationTTLStr)
+		if err != nil {
+			panic("El valor de ReservationTTL debe ser una duración, por ejemplo 15m")
+		}
+	}
+	var reservationSweepInterval time.Duration
+	if reservationSweepIntervalStr := os.Getenv("ReservationSweepInterval"); reservationSweepIntervalStr != "" {
+		reservationSweepInterval, err = time.ParseDuration(reservationSweepIntervalStr)
+		if err != nil {
+			panic("El valor de ReservationSweepInterval debe ser una duración, por ejemplo 30s")
+		}
+	}
+
+	// Cuánto se esperan las solicitudes en curso al detener el servidor (opcional)
+	var shutdownTimeout time.Duration
+	if shutdownTimeoutStr := os.Getenv("ShutdownTimeout"); shutdownTimeoutStr != "" {
+		shutdownTimeout, err = time.ParseDuration(shutdownTimeoutStr)
+		if err != nil {
+			panic("El valor de ShutdownTimeout debe ser una duración, por ejemplo 10s")
+		}
+	}
+
 	cfg := &server.ConfigServer{
-		ServerAddress:   ":" + port,
-		StaticFilesPath: "./docs/db/products.json",
+		ServerAddress:               ":" + port,
+		StaticFilesPath:             storageFile,
+		StorageBackups:              backups,
+		StorageType:                 storageType,
+		JournalCompactEvery:         compactEvery,
+		KeyringPath:                 keyringPath,
+		AuthRequiredForReads:        authReads,
+		JWTSecret:                   jwtSecret,
+		JWTIssuer:                   jwtIssuer,
+		JWTAudience:                 jwtAudience,
+		RequireIfMatch:              requireIfMatch,
+		TrashRetentionDays:          trashRetentionDays,
+		TrashPurgeInterval:          trashPurgeInterval,
+		AuditFile:                   auditFile,
+		PriceScheduleInterval:       priceScheduleInterval,
+		ExchangeRatesFile:           exchangeRatesFile,
+		ExchangeRatesReloadInterval: exchangeRatesReloadInterval,
+		CategoriesFile:              categoriesFile,
+		WarehousesFile:              warehousesFile,
+		StockMovementsFile:          stockMovementsFile,
+		AllowNegativeStock:          allowNegativeStock,
+		ExpirationCheckInterval:     expirationCheckInterval,
+		ReservationsFile:            reservationsFile,
+		ReservationTTL:              reservationTTL,
+		ReservationSweepInterval:    reservationSweepInterval,
+		ShutdownTimeout:             shutdownTimeout,
 	}
 
 	log.Printf("Server running on port %s", port)
@@ -1,15 +1,25 @@
 package server
 
 import (
+	"context"
 	"fmt"
+	"log"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
+	"PRACTICAS-GO-WEB/internal/auth"
 	"PRACTICAS-GO-WEB/internal/handlers"
 	"PRACTICAS-GO-WEB/internal/repository"
+	"PRACTICAS-GO-WEB/internal/scheduler"
 	"PRACTICAS-GO-WEB/internal/service"
 	"PRACTICAS-GO-WEB/internal/storage"
+	"PRACTICAS-GO-WEB/pkg/web"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
 )
 
 type ConfigServer struct {
@@ -17,6 +27,55 @@ type ConfigServer struct {
 	ServerAddress string
 	// StaticFilesPath es la ruta de los archivos estáticos
 	StaticFilesPath string
+	// StorageBackups es la cantidad de generaciones .bak que se conservan del archivo de datos
+	StorageBackups int
+	// StorageType es el formato del archivo de datos (json, csv o journal)
+	StorageType string
+	// JournalCompactEvery es la cantidad de mutaciones del journal a partir de la cual se compacta
+	JournalCompactEvery int
+	// KeyringPath es la ruta del archivo con las claves de API habilitadas
+	KeyringPath string
+	// AuthRequiredForReads indica si las lecturas de productos requieren el permiso products:read
+	AuthRequiredForReads bool
+	// JWTSecret es el secreto compartido para verificar los JWT; vacío deshabilita los JWT
+	JWTSecret string
+	// JWTIssuer es el emisor esperado en el claim iss de los JWT (opcional)
+	JWTIssuer string
+	// JWTAudience es la audiencia esperada en el claim aud de los JWT (opcional)
+	JWTAudience string
+	// RequireIfMatch indica si PUT, PATCH y DELETE requieren el encabezado If-Match
+	RequireIfMatch bool
+	// TrashRetentionDays es la cantidad de días que un producto permanece en la papelera
+	// antes de eliminarse de forma definitiva; 0 los conserva indefinidamente
+	TrashRetentionDays int
+	// TrashPurgeInterval es cada cuánto se vacía la papelera; 0 la vacía solo al iniciar
+	TrashPurgeInterval time.Duration
+	// AuditFile es la ruta del registro de auditoría de las mutaciones de productos
+	AuditFile string
+	// PriceScheduleInterval es cada cuánto se ponen en vigencia los precios programados
+	PriceScheduleInterval time.Duration
+	// ExchangeRatesFile es la ruta del archivo JSON con la tabla de cotizaciones
+	ExchangeRatesFile string
+	// ExchangeRatesReloadInterval es cada cuánto se vuelve a leer la tabla de cotizaciones
+	ExchangeRatesReloadInterval time.Duration
+	// CategoriesFile es la ruta del archivo JSON con el árbol de categorías
+	CategoriesFile string
+	// WarehousesFile es la ruta del archivo JSON con los depósitos
+	WarehousesFile string
+	// StockMovementsFile es la ruta del libro de movimientos de stock
+	StockMovementsFile string
+	// AllowNegativeStock permite que los egresos dejen existencias negativas
+	AllowNegativeStock bool
+	// ExpirationCheckInterval es cada cuánto se despublican los productos vencidos
+	ExpirationCheckInterval time.Duration
+	// ReservationsFile es la ruta del archivo JSON con las reservas de stock
+	ReservationsFile string
+	// ReservationTTL es el plazo de las reservas que no indican otro
+	ReservationTTL time.Duration
+	// ReservationSweepInterval es cada cuánto se cierran las reservas vencidas
+	ReservationSweepInterval time.Duration
+	// ShutdownTimeout es cuánto se esperan las solicitudes en curso al detener el servidor
+	ShutdownTimeout time.Duration
 }
 
 type Server struct {
@@ -24,13 +83,76 @@ type Server struct {
 	serverAddress string
 	// StaticFilesPath es la ruta de los archivos estáticos
 	staticFilesPath string
+	// StorageBackups es la cantidad de generaciones .bak que se conservan del archivo de datos
+	storageBackups int
+	// StorageType es el formato del archivo de datos (json, csv o journal)
+	storageType string
+	// JournalCompactEvery es la cantidad de mutaciones del journal a partir de la cual se compacta
+	journalCompactEvery int
+	// KeyringPath es la ruta del archivo con las claves de API habilitadas
+	keyringPath string
+	// AuthRequiredForReads indica si las lecturas de productos requieren el permiso products:read
+	authRequiredForReads bool
+	// JWTSecret es el secreto compartido para verificar los JWT; vacío deshabilita los JWT
+	jwtSecret string
+	// JWTIssuer es el emisor esperado en el claim iss de los JWT (opcional)
+	jwtIssuer string
+	// JWTAudience es la audiencia esperada en el claim aud de los JWT (opcional)
+	jwtAudience string
+	// RequireIfMatch indica si PUT, PATCH y DELETE requieren el encabezado If-Match
+	requireIfMatch bool
+	// TrashRetentionDays es la cantidad de días que un producto permanece en la papelera
+	// antes de eliminarse de forma definitiva; 0 los conserva indefinidamente
+	trashRetentionDays int
+	// TrashPurgeInterval es cada cuánto se vacía la papelera; 0 la vacía solo al iniciar
+	trashPurgeInterval time.Duration
+	// AuditFile es la ruta del registro de auditoría de las mutaciones de productos
+	auditFile string
+	// PriceScheduleInterval es cada cuánto se ponen en vigencia los precios programados
+	priceScheduleInterval time.Duration
+	// ExchangeRatesFile es la ruta del archivo JSON con la tabla de cotizaciones
+	exchangeRatesFile string
+	// ExchangeRatesReloadInterval es cada cuánto se vuelve a leer la tabla de cotizaciones
+	exchangeRatesReloadInterval time.Duration
+	// CategoriesFile es la ruta del archivo JSON con el árbol de categorías
+	categoriesFile string
+	// WarehousesFile es la ruta del archivo JSON con los depósitos
+	warehousesFile string
+	// StockMovementsFile es la ruta del libro de movimientos de stock
+	stockMovementsFile string
+	// AllowNegativeStock permite que los egresos dejen existencias negativas
+	allowNegativeStock bool
+	// ExpirationCheckInterval es cada cuánto se despublican los productos vencidos
+	expirationCheckInterval time.Duration
+	// ReservationsFile es la ruta del archivo JSON con las reservas de stock
+	reservationsFile string
+	// ReservationTTL es el plazo de las reservas que no indican otro
+	reservationTTL time.Duration
+	// ReservationSweepInterval es cada cuánto se cierran las reservas vencidas
+	reservationSweepInterval time.Duration
+	// ShutdownTimeout es cuánto se esperan las solicitudes en curso al detener el servidor
+	shutdownTimeout time.Duration
 }
 
 func NewServer(cfg *ConfigServer) *Server {
 
 	defaultConfig := &ConfigServer{
-		ServerAddress:   ":8080",
-		StaticFilesPath: "./docs/db",
+		ServerAddress:       ":8080",
+		StaticFilesPath:     "./docs/db",
+		JournalCompactEvery: 1000,
+		AuditFile:           "./docs/db/audit.jsonl",
+		// Los precios programados entran en vigencia con, como mucho, un minuto de demora
+		PriceScheduleInterval:       time.Minute,
+		ExchangeRatesFile:           "./docs/db/exchange_rates.json",
+		ExchangeRatesReloadInterval: time.Minute,
+		CategoriesFile:              "./docs/db/categories.json",
+		WarehousesFile:              "./docs/db/warehouses.json",
+		StockMovementsFile:          "./docs/db/stock_movements.jsonl",
+		ExpirationCheckInterval:     time.Hour,
+		ReservationsFile:            "./docs/db/reservations.json",
+		ReservationTTL:              15 * time.Minute,
+		ReservationSweepInterval:    30 * time.Second,
+		ShutdownTimeout:             10 * time.Second,
 	}
 
 	if cfg != nil {
@@ -40,38 +162,284 @@ func NewServer(cfg *ConfigServer) *Server {
 		if cfg.StaticFilesPath != "" {
 			defaultConfig.StaticFilesPath = cfg.StaticFilesPath
 		}
+		if cfg.StorageBackups > 0 {
+			defaultConfig.StorageBackups = cfg.StorageBackups
+		}
+		if cfg.StorageType != "" {
+			defaultConfig.StorageType = cfg.StorageType
+		}
+		if cfg.JournalCompactEvery > 0 {
+			defaultConfig.JournalCompactEvery = cfg.JournalCompactEvery
+		}
+		if cfg.KeyringPath != "" {
+			defaultConfig.KeyringPath = cfg.KeyringPath
+		}
+		defaultConfig.AuthRequiredForReads = cfg.AuthRequiredForReads
+		defaultConfig.JWTSecret = cfg.JWTSecret
+		defaultConfig.JWTIssuer = cfg.JWTIssuer
+		defaultConfig.JWTAudience = cfg.JWTAudience
+		defaultConfig.RequireIfMatch = cfg.RequireIfMatch
+		if cfg.TrashRetentionDays > 0 {
+			defaultConfig.TrashRetentionDays = cfg.TrashRetentionDays
+		}
+		if cfg.TrashPurgeInterval > 0 {
+			defaultConfig.TrashPurgeInterval = cfg.TrashPurgeInterval
+		}
+		if cfg.AuditFile != "" {
+			defaultConfig.AuditFile = cfg.AuditFile
+		}
+		if cfg.PriceScheduleInterval > 0 {
+			defaultConfig.PriceScheduleInterval = cfg.PriceScheduleInterval
+		}
+		if cfg.ExchangeRatesFile != "" {
+			defaultConfig.ExchangeRatesFile = cfg.ExchangeRatesFile
+		}
+		if cfg.ExchangeRatesReloadInterval > 0 {
+			defaultConfig.ExchangeRatesReloadInterval = cfg.ExchangeRatesReloadInterval
+		}
+		if cfg.CategoriesFile != "" {
+			defaultConfig.CategoriesFile = cfg.CategoriesFile
+		}
+		if cfg.WarehousesFile != "" {
+			defaultConfig.WarehousesFile = cfg.WarehousesFile
+		}
+		if cfg.StockMovementsFile != "" {
+			defaultConfig.StockMovementsFile = cfg.StockMovementsFile
+		}
+		defaultConfig.AllowNegativeStock = cfg.AllowNegativeStock
+		if cfg.ExpirationCheckInterval > 0 {
+			defaultConfig.ExpirationCheckInterval = cfg.ExpirationCheckInterval
+		}
+		if cfg.ReservationsFile != "" {
+			defaultConfig.ReservationsFile = cfg.ReservationsFile
+		}
+		if cfg.ReservationTTL > 0 {
+			defaultConfig.ReservationTTL = cfg.ReservationTTL
+		}
+		if cfg.ReservationSweepInterval > 0 {
+			defaultConfig.ReservationSweepInterval = cfg.ReservationSweepInterval
+		}
+		if cfg.ShutdownTimeout > 0 {
+			defaultConfig.ShutdownTimeout = cfg.ShutdownTimeout
+		}
+	}
+
+	if defaultConfig.StorageType == "" {
+		defaultConfig.StorageType = storage.TypeFromFileName(defaultConfig.StaticFilesPath)
 	}
 
 	return &Server{
-		serverAddress:   defaultConfig.ServerAddress,
-		staticFilesPath: defaultConfig.StaticFilesPath,
+		serverAddress:               defaultConfig.ServerAddress,
+		staticFilesPath:             defaultConfig.StaticFilesPath,
+		storageBackups:              defaultConfig.StorageBackups,
+		storageType:                 defaultConfig.StorageType,
+		journalCompactEvery:         defaultConfig.JournalCompactEvery,
+		keyringPath:                 defaultConfig.KeyringPath,
+		authRequiredForReads:        defaultConfig.AuthRequiredForReads,
+		jwtSecret:                   defaultConfig.JWTSecret,
+		jwtIssuer:                   defaultConfig.JWTIssuer,
+		jwtAudience:                 defaultConfig.JWTAudience,
+		requireIfMatch:              defaultConfig.RequireIfMatch,
+		trashRetentionDays:          defaultConfig.TrashRetentionDays,
+		trashPurgeInterval:          defaultConfig.TrashPurgeInterval,
+		auditFile:                   defaultConfig.AuditFile,
+		priceScheduleInterval:       defaultConfig.PriceScheduleInterval,
+		exchangeRatesFile:           defaultConfig.ExchangeRatesFile,
+		exchangeRatesReloadInterval: defaultConfig.ExchangeRatesReloadInterval,
+		categoriesFile:              defaultConfig.CategoriesFile,
+		warehousesFile:              defaultConfig.WarehousesFile,
+		stockMovementsFile:          defaultConfig.StockMovementsFile,
+		allowNegativeStock:          defaultConfig.AllowNegativeStock,
+		expirationCheckInterval:     defaultConfig.ExpirationCheckInterval,
+		reservationsFile:            defaultConfig.ReservationsFile,
+		reservationTTL:              defaultConfig.ReservationTTL,
+		reservationSweepInterval:    defaultConfig.ReservationSweepInterval,
+		shutdownTimeout:             defaultConfig.ShutdownTimeout,
 	}
 
 }
 
 func (s *Server) Run(tokenAuthorization string) error {
 
-	sj, err := storage.NewStorageJSON(s.staticFilesPath)
+	st, err := storage.NewStorage(storage.Config{
+		Type:                s.storageType,
+		FileName:            s.staticFilesPath,
+		Backups:             s.storageBackups,
+		JournalCompactEvery: s.journalCompactEvery,
+	})
 	if err != nil {
-		return fmt.Errorf("Error al crear el almacenamiento JSON: %s", err.Error())
+		return fmt.Errorf("Error al crear el almacenamiento %s: %s", s.storageType, err.Error())
 	}
 
-	pr, err := repository.NewProductRepository(sj)
+	// Al iniciar se compactan las mutaciones pendientes en un snapshot nuevo
+	if journal, ok := st.(storage.Journal); ok {
+		if err := journal.Compact(); err != nil {
+			return fmt.Errorf("Error al compactar el journal: %s", err.Error())
+		}
+	}
+
+	pr, err := repository.NewProductRepository(st)
 	if err != nil {
 		return fmt.Errorf("Error al crear el repositorio de productos: %s", err.Error())
 	}
 
-	ps, err := service.NewProductService(pr)
+	auditStorage, err := storage.NewStorageAppendLog(s.auditFile)
+	if err != nil {
+		return fmt.Errorf("Error al crear el registro de auditoría: %s", err.Error())
+	}
+
+	ar, err := repository.NewAuditRepository(auditStorage)
+	if err != nil {
+		return fmt.Errorf("Error al crear el repositorio de auditoría: %s", err.Error())
+	}
+
+	ratesStorage, err := storage.NewStorageJSON(s.exchangeRatesFile, 0)
+	if err != nil {
+		return fmt.Errorf("Error al abrir la tabla de cotizaciones: %s", err.Error())
+	}
+
+	rr, err := repository.NewExchangeRateRepository(ratesStorage)
+	if err != nil {
+		return fmt.Errorf("Error al crear el repositorio de cotizaciones: %s", err.Error())
+	}
+
+	categoriesStorage, err := storage.NewStorageJSON(s.categoriesFile, s.storageBackups)
+	if err != nil {
+		return fmt.Errorf("Error al abrir el archivo de categorías: %s", err.Error())
+	}
+
+	cr, err := repository.NewCategoryRepository(categoriesStorage)
+	if err != nil {
+		return fmt.Errorf("Error al crear el repositorio de categorías: %s", err.Error())
+	}
+
+	warehousesStorage, err := storage.NewStorageJSON(s.warehousesFile, s.storageBackups)
+	if err != nil {
+		return fmt.Errorf("Error al abrir el archivo de depósitos: %s", err.Error())
+	}
+
+	wr, err := repository.NewWarehouseRepository(warehousesStorage)
+	if err != nil {
+		return fmt.Errorf("Error al crear el repositorio de depósitos: %s", err.Error())
+	}
+
+	movementsStorage, err := storage.NewStorageAppendLog(s.stockMovementsFile)
+	if err != nil {
+		return fmt.Errorf("Error al crear el libro de stock: %s", err.Error())
+	}
+
+	mr, err := repository.NewStockMovementRepository(movementsStorage)
+	if err != nil {
+		return fmt.Errorf("Error al crear el repositorio de movimientos de stock: %s", err.Error())
+	}
+
+	// Los IDs de los productos purgados solo quedan en la auditoría y en el libro de stock; un
+	// producto nuevo que los reutilizara heredaría su historia y sus existencias
+	pr.ReserveIDs(max(ar.LastProductID(), mr.LastProductID()))
+
+	reservationsStorage, err := storage.NewStorageJSON(s.reservationsFile, s.storageBackups)
+	if err != nil {
+		return fmt.Errorf("Error al abrir el archivo de reservas: %s", err.Error())
+	}
+
+	resr, err := repository.NewReservationRepository(reservationsStorage)
+	if err != nil {
+		return fmt.Errorf("Error al crear el repositorio de reservas: %s", err.Error())
+	}
+
+	ps, err := service.NewProductService(pr, ar, rr, cr, wr, mr, resr, s.allowNegativeStock, s.reservationTTL)
 	if err != nil {
 		return fmt.Errorf("Error al crear el servicio de productos: %s", err.Error())
 	}
 
-	ph := handlers.NewProductHandler(ps)
+	// El libro de stock prevalece sobre la cantidad guardada en cada producto
+	reconciled, err := ps.ReconcileStock(context.Background())
+	if err != nil {
+		return fmt.Errorf("Error al conciliar las existencias con el libro de stock: %s", err.Error())
+	}
+	if reconciled > 0 {
+		log.Printf("Se corrigió la cantidad de %d productos según el libro de stock", reconciled)
+	}
+
+	as, err := service.NewAuditService(ar)
+	if err != nil {
+		return fmt.Errorf("Error al crear el servicio de auditoría: %s", err.Error())
+	}
+
+	es, err := service.NewExchangeRateService(rr)
+	if err != nil {
+		return fmt.Errorf("Error al crear el servicio de cotizaciones: %s", err.Error())
+	}
+
+	cs, err := service.NewCategoryService(cr, ps)
+	if err != nil {
+		return fmt.Errorf("Error al crear el servicio de categorías: %s", err.Error())
+	}
+
+	ws, err := service.NewWarehouseService(wr, ps)
+	if err != nil {
+		return fmt.Errorf("Error al crear el servicio de depósitos: %s", err.Error())
+	}
+
+	// Tareas periódicas en segundo plano; se detienen al terminar el servidor
+	jobs := scheduler.New()
+	defer jobs.Stop()
+
+	if err := s.scheduleTrashPurge(ps, jobs); err != nil {
+		return err
+	}
+
+	if err := s.schedulePrices(ps, jobs); err != nil {
+		return err
+	}
+
+	if err := s.scheduleExchangeRates(es, jobs); err != nil {
+		return err
+	}
+
+	if err := s.scheduleExpirations(ps, jobs); err != nil {
+		return err
+	}
+
+	if err := s.scheduleReservations(ps, jobs); err != nil {
+		return err
+	}
+
+	jobs.Start()
+
+	ph := handlers.NewProductHandler(ps, s.requireIfMatch)
+	ah := handlers.NewAuditHandler(as)
+	eh := handlers.NewExchangeRateHandler(es)
+	ch := handlers.NewCategoryHandler(cs)
+	wh := handlers.NewWarehouseHandler(ws)
+
+	keyring, err := s.loadKeyring(tokenAuthorization)
+	if err != nil {
+		return fmt.Errorf("Error al cargar las claves de API: %s", err.Error())
+	}
+
+	// Los JWT solo se aceptan si hay un secreto configurado para verificarlos
+	var jwtVerifier *auth.JWTVerifier
+	if s.jwtSecret != "" {
+		jwtVerifier, err = auth.NewJWTVerifier(s.jwtSecret, s.jwtIssuer, s.jwtAudience)
+		if err != nil {
+			return fmt.Errorf("Error al configurar la verificación de JWT: %s", err.Error())
+		}
+	}
 
 	router := chi.NewRouter()
 
 	//router.Use(middleware.Logger)
 	//router.Use(middleware.Recoverer)
+	router.Use(middleware.RequestID)
+	// Las capas internas leen el ID de la solicitud del contexto propio, sin depender del router
+	router.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := web.ContextWithRequestID(r.Context(), middleware.GetReqID(r.Context()))
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	})
+	router.Use(auth.Authenticate(keyring, jwtVerifier))
 
 	router.Group(func(router chi.Router) {
 		router.Get("/ping", ph.HandlerPing)
@@ -80,20 +448,286 @@ func (s *Server) Run(tokenAuthorization string) error {
 	router.Route("/products", func(router chi.Router) {
 
 		router.Group(func(router chi.Router) {
+			if s.authRequiredForReads {
+				router.Use(auth.RequireScope(auth.ScopeProductsRead))
+			}
 			router.Get("/", ph.HandlerGetAllProduct)
 			router.Get("/{id}", ph.HandlerGetProductByID)
-			router.Get("/search", ph.HandlerSearchProductByPrice)
+			router.Get("/search", ph.HandlerSearchProducts)
+			router.Get("/trash", ph.HandlerGetTrash)
+			router.Get("/expiring", ph.HandlerGetExpiringProducts)
+			router.Get("/expired", ph.HandlerGetExpiredProducts)
+			router.Get("/{id}/prices", ph.HandlerGetProductPrices)
+			router.Get("/{id}/stock", ph.HandlerGetProductStock)
+			router.Get("/{id}/stock-movements", ph.HandlerGetStockMovements)
+			router.Get("/{id}/reservations", ph.HandlerGetReservations)
+			router.Get("/{id}/reservations/{reservationId}", ph.HandlerGetReservation)
 		})
 
 		router.Group(func(router chi.Router) {
+			router.Use(auth.RequireScope(auth.ScopeProductsWrite))
 			router.Post("/", ph.HandlerCreateProduct)
 			router.Patch("/{id}", ph.HandlerUpdatePartialProduct)
 			router.Put("/{id}", ph.HandlerUpdateProduct)
+			router.Post("/{id}/restore", ph.HandlerRestoreProduct)
+			router.Post("/{id}/prices", ph.HandlerSchedulePrice)
+			router.Put("/{id}/stock/{warehouseId}", ph.HandlerSetStock)
+			router.Post("/{id}/stock/transfers", ph.HandlerTransferStock)
+			router.Post("/{id}/stock-movements", ph.HandlerRecordStockMovement)
+			router.Post("/{id}/reservations", ph.HandlerReserveStock)
+			router.Post("/{id}/reservations/{reservationId}/confirm", ph.HandlerConfirmReservation)
+			router.Post("/{id}/reservations/{reservationId}/release", ph.HandlerReleaseReservation)
+		})
+
+		router.Group(func(router chi.Router) {
+			router.Use(auth.RequireScope(auth.ScopeProductsDelete))
 			router.Delete("/{id}", ph.HandlerDeleteProduct)
 		})
 
+		router.Group(func(router chi.Router) {
+			router.Use(auth.RequireScope(auth.ScopeAuditRead))
+			router.Get("/{id}/history", ah.HandlerGetProductHistory)
+		})
+
+	})
+
+	router.Group(func(router chi.Router) {
+		router.Use(auth.RequireScope(auth.ScopeAuditRead))
+		router.Get("/audit", ah.HandlerGetAudit)
+	})
+
+	router.Route("/exchange-rates", func(router chi.Router) {
+
+		router.Group(func(router chi.Router) {
+			if s.authRequiredForReads {
+				router.Use(auth.RequireScope(auth.ScopeProductsRead))
+			}
+			router.Get("/", eh.HandlerGetExchangeRates)
+		})
+
+		router.Group(func(router chi.Router) {
+			router.Use(auth.RequireScope(auth.ScopeProductsWrite))
+			router.Post("/reload", eh.HandlerReloadExchangeRates)
+		})
+
+	})
+
+	router.Route("/categories", func(router chi.Router) {
+
+		router.Group(func(router chi.Router) {
+			if s.authRequiredForReads {
+				router.Use(auth.RequireScope(auth.ScopeProductsRead))
+			}
+			router.Get("/", ch.HandlerGetCategories)
+			router.Get("/tree", ch.HandlerGetCategoryTree)
+			router.Get("/{id}", ch.HandlerGetCategoryByID)
+			router.Get("/{id}/products", ch.HandlerGetCategoryProducts)
+		})
+
+		router.Group(func(router chi.Router) {
+			router.Use(auth.RequireScope(auth.ScopeProductsWrite))
+			router.Post("/", ch.HandlerCreateCategory)
+			router.Put("/{id}", ch.HandlerUpdateCategory)
+		})
+
+		router.Group(func(router chi.Router) {
+			router.Use(auth.RequireScope(auth.ScopeProductsDelete))
+			router.Delete("/{id}", ch.HandlerDeleteCategory)
+		})
+
+	})
+
+	router.Route("/warehouses", func(router chi.Router) {
+
+		router.Group(func(router chi.Router) {
+			if s.authRequiredForReads {
+				router.Use(auth.RequireScope(auth.ScopeProductsRead))
+			}
+			router.Get("/", wh.HandlerGetWarehouses)
+			router.Get("/{id}", wh.HandlerGetWarehouseByID)
+			router.Get("/{id}/stock", wh.HandlerGetWarehouseStock)
+		})
+
+		router.Group(func(router chi.Router) {
+			router.Use(auth.RequireScope(auth.ScopeProductsWrite))
+			router.Post("/", wh.HandlerCreateWarehouse)
+			router.Put("/{id}", wh.HandlerUpdateWarehouse)
+		})
+
+		router.Group(func(router chi.Router) {
+			router.Use(auth.RequireScope(auth.ScopeProductsDelete))
+			router.Delete("/{id}", wh.HandlerDeleteWarehouse)
+		})
+
+	})
+
+	return s.serve(router)
+
+}
+
+// serve atiende las solicitudes hasta recibir SIGINT o SIGTERM. Al detenerse deja de aceptar
+// conexiones y espera, como mucho shutdownTimeout, a que terminen las solicitudes en curso.
+func (s *Server) serve(handler http.Handler) error {
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	httpServer := &http.Server{Addr: s.serverAddress, Handler: handler}
+
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- httpServer.ListenAndServe()
+	}()
+
+	select {
+	case err := <-serveErr:
+		return err
+	case <-ctx.Done():
+	}
+
+	log.Printf("Deteniendo el servidor")
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), s.shutdownTimeout)
+	defer cancel()
+
+	if err := httpServer.Shutdown(shutdownCtx); err != nil {
+		return fmt.Errorf("Error al detener el servidor: %s", err.Error())
+	}
+
+	return nil
+
+}
+
+// scheduleTrashPurge vacía la papelera según la retención configurada: una vez al iniciar y,
+// si se configuró un intervalo, periódicamente
+func (s *Server) scheduleTrashPurge(ps service.ProductService, jobs *scheduler.Scheduler) error {
+
+	if s.trashRetentionDays <= 0 {
+		return nil
+	}
+
+	retention := time.Duration(s.trashRetentionDays) * 24 * time.Hour
+
+	purge := func(ctx context.Context) error {
+		purged, err := ps.PurgeTrash(ctx, retention)
+		if err != nil {
+			return err
+		}
+		if purged > 0 {
+			log.Printf("Se eliminaron %d productos de la papelera", purged)
+		}
+		return nil
+	}
+
+	if err := purge(context.Background()); err != nil {
+		return err
+	}
+
+	if s.trashPurgeInterval <= 0 {
+		return nil
+	}
+
+	return jobs.Every("vaciar papelera", s.trashPurgeInterval, purge)
+
+}
+
+// schedulePrices pone en vigencia los precios programados al iniciar y luego periódicamente
+func (s *Server) schedulePrices(ps service.ProductService, jobs *scheduler.Scheduler) error {
+
+	apply := func(ctx context.Context) error {
+		applied, err := ps.ApplyScheduledPrices(ctx)
+		if err != nil {
+			return err
+		}
+		if applied > 0 {
+			log.Printf("Entraron en vigencia los precios programados de %d productos", applied)
+		}
+		return nil
+	}
+
+	if err := apply(context.Background()); err != nil {
+		return err
+	}
+
+	return jobs.Every("aplicar precios programados", s.priceScheduleInterval, apply)
+
+}
+
+// scheduleExchangeRates vuelve a leer periódicamente la tabla de cotizaciones. Un archivo
+// inválido se informa y se sigue usando la tabla anterior.
+func (s *Server) scheduleExchangeRates(es service.ExchangeRateService, jobs *scheduler.Scheduler) error {
+
+	return jobs.Every("recargar cotizaciones", s.exchangeRatesReloadInterval, func(ctx context.Context) error {
+		rates, changed, err := es.ReloadExchangeRates()
+		if err != nil {
+			return err
+		}
+		if changed {
+			log.Printf("Se recargó la tabla de cotizaciones con base %s y %d monedas", rates.Base, len(rates.Rates))
+		}
+		return nil
 	})
 
-	return http.ListenAndServe(s.serverAddress, router)
+}
+
+// scheduleExpirations despublica los productos vencidos al iniciar y luego periódicamente.
+// Cada producto despublicado queda en la auditoría con el actor del sistema.
+func (s *Server) scheduleExpirations(ps service.ProductService, jobs *scheduler.Scheduler) error {
+
+	unpublish := func(ctx context.Context) error {
+		unpublished, err := ps.UnpublishExpired(ctx)
+		if err != nil {
+			return err
+		}
+		if unpublished > 0 {
+			log.Printf("Se despublicaron %d productos vencidos", unpublished)
+		}
+		return nil
+	}
+
+	if err := unpublish(context.Background()); err != nil {
+		return err
+	}
+
+	return jobs.Every("despublicar productos vencidos", s.expirationCheckInterval, unpublish)
+
+}
+
+// scheduleReservations cierra las reservas vencidas al iniciar y luego periódicamente. Las
+// reservas vencidas dejan de retener existencias aunque todavía no se hayan cerrado.
+func (s *Server) scheduleReservations(ps service.ProductService, jobs *scheduler.Scheduler) error {
+
+	expire := func(ctx context.Context) error {
+		expired, err := ps.ExpireReservations(ctx)
+		if err != nil {
+			return err
+		}
+		if expired > 0 {
+			log.Printf("Vencieron %d reservas de stock", expired)
+		}
+		return nil
+	}
+
+	if err := expire(context.Background()); err != nil {
+		return err
+	}
+
+	return jobs.Every("cerrar reservas vencidas", s.reservationSweepInterval, expire)
+
+}
+
+// loadKeyring carga el keyring configurado. Si no hay ninguno, el token heredado de la
+// configuración se habilita como una única clave con todos los permisos.
+func (s *Server) loadKeyring(tokenAuthorization string) (*auth.Keyring, error) {
+
+	if s.keyringPath != "" {
+		return auth.LoadKeyring(s.keyringPath)
+	}
+
+	if tokenAuthorization == "" {
+		return auth.NewKeyring(nil)
+	}
+
+	return auth.NewKeyring([]auth.APIKey{{Name: "default", Key: tokenAuthorization, Scopes: auth.AllScopes}})
 
 }
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"PRACTICAS-GO-WEB/internal/auth"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// runToken genera un JWT HS256 firmado con el secreto configurado, para pruebas locales:
+//
+//	go run ./cmd token -sub operador -scope "products:write products:delete" -ttl 1h
+func runToken(args []string) error {
+
+	flags := flag.NewFlagSet("token", flag.ContinueOnError)
+	subject := flags.String("sub", "", "identidad del token (claim sub)")
+	scope := flags.String("scope", strings.Join(auth.AllScopes, " "), "permisos separados por espacios (claim scope)")
+	ttl := flags.Duration("ttl", time.Hour, "tiempo de validez del token")
+	secret := flags.String("secret", os.Getenv("JWTSecret"), "secreto compartido (por defecto JWTSecret)")
+	issuer := flags.String("iss", os.Getenv("JWTIssuer"), "emisor (por defecto JWTIssuer)")
+	audience := flags.String("aud", os.Getenv("JWTAudience"), "audiencia (por defecto JWTAudience)")
+
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if *subject == "" {
+		return errors.New("El valor de -sub es requerido")
+	}
+	if *secret == "" {
+		return errors.New("El secreto es requerido: configure JWTSecret o use -secret")
+	}
+	if *ttl <= 0 {
+		return errors.New("El valor de -ttl debe ser positivo")
+	}
+
+	now := time.Now()
+	claims := auth.Claims{
+		Subject:   *subject,
+		Issuer:    *issuer,
+		ExpiresAt: now.Add(*ttl).Unix(),
+		NotBefore: now.Unix(),
+		IssuedAt:  now.Unix(),
+		Scope:     *scope,
+	}
+	if *audience != "" {
+		claims.Audience = auth.Audience{*audience}
+	}
+
+	token, err := auth.SignJWT(*secret, claims)
+	if err != nil {
+		return fmt.Errorf("Error al firmar el token: %s", err.Error())
+	}
+
+	fmt.Println(token)
+
+	return nil
+}
@@ -0,0 +1,41 @@
+package auth
+
+import (
+	"context"
+	"slices"
+)
+
+// Permisos que puede tener una identidad
+const (
+	ScopeProductsRead   = "products:read"
+	ScopeProductsWrite  = "products:write"
+	ScopeProductsDelete = "products:delete"
+	ScopeAuditRead      = "audit:read"
+)
+
+// AllScopes son todos los permisos conocidos
+var AllScopes = []string{ScopeProductsRead, ScopeProductsWrite, ScopeProductsDelete, ScopeAuditRead}
+
+// Identity es quien realiza la solicitud, una vez autenticado
+type Identity struct {
+	Name   string
+	Scopes []string
+}
+
+// HasScope indica si la identidad tiene el permiso indicado
+func (identity Identity) HasScope(scope string) bool {
+	return slices.Contains(identity.Scopes, scope)
+}
+
+type identityKey struct{}
+
+// ContextWithIdentity devuelve un contexto que contiene la identidad autenticada
+func ContextWithIdentity(ctx context.Context, identity Identity) context.Context {
+	return context.WithValue(ctx, identityKey{}, identity)
+}
+
+// IdentityFromContext devuelve la identidad autenticada del contexto, si existe
+func IdentityFromContext(ctx context.Context) (Identity, bool) {
+	identity, ok := ctx.Value(identityKey{}).(Identity)
+	return identity, ok
+}
@@ -0,0 +1,192 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+var (
+	ErrInvalidToken = errors.New("token inválido")
+	ErrExpiredToken = errors.New("token vencido")
+)
+
+// Audience admite el claim aud como un string o como una lista de strings
+type Audience []string
+
+func (audience *Audience) UnmarshalJSON(data []byte) error {
+
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*audience = Audience{single}
+		return nil
+	}
+
+	var list []string
+	if err := json.Unmarshal(data, &list); err != nil {
+		return errors.New("el claim aud debe ser un string o una lista de strings")
+	}
+	*audience = list
+
+	return nil
+}
+
+func (audience Audience) MarshalJSON() ([]byte, error) {
+	if len(audience) == 1 {
+		return json.Marshal(audience[0])
+	}
+	return json.Marshal([]string(audience))
+}
+
+// Claims son los claims registrados que se validan, más el claim scope con los permisos
+// separados por espacios
+type Claims struct {
+	Subject   string   `json:"sub,omitempty"`
+	Issuer    string   `json:"iss,omitempty"`
+	Audience  Audience `json:"aud,omitempty"`
+	ExpiresAt int64    `json:"exp,omitempty"`
+	NotBefore int64    `json:"nbf,omitempty"`
+	IssuedAt  int64    `json:"iat,omitempty"`
+	Scope     string   `json:"scope,omitempty"`
+}
+
+// Identity devuelve la identidad del token; los permisos desconocidos se descartan
+func (claims Claims) Identity() Identity {
+
+	identity := Identity{Name: claims.Subject}
+	for _, scope := range strings.Fields(claims.Scope) {
+		if slices.Contains(AllScopes, scope) && !slices.Contains(identity.Scopes, scope) {
+			identity.Scopes = append(identity.Scopes, scope)
+		}
+	}
+
+	return identity
+}
+
+type jwtHeader struct {
+	Algorithm string `json:"alg"`
+	Type      string `json:"typ,omitempty"`
+}
+
+// JWTVerifier valida tokens JWT firmados con HMAC-SHA256 (HS256)
+type JWTVerifier struct {
+	secret   []byte
+	issuer   string
+	audience string
+	// leeway es la tolerancia de reloj para exp y nbf
+	leeway time.Duration
+	now    func() time.Time
+}
+
+// función para crear un verificador de JWT. Si issuer o audience no están vacíos,
+// los tokens deben declararlos en los claims iss y aud.
+func NewJWTVerifier(secret string, issuer string, audience string) (*JWTVerifier, error) {
+
+	if secret == "" {
+		return nil, errors.New("El secreto para verificar los JWT es requerido")
+	}
+
+	return &JWTVerifier{
+		secret:   []byte(secret),
+		issuer:   issuer,
+		audience: audience,
+		leeway:   30 * time.Second,
+		now:      time.Now,
+	}, nil
+}
+
+// Verify valida la firma y los claims temporales, de emisor y de audiencia del token
+func (verifier *JWTVerifier) Verify(token string) (Claims, error) {
+
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return Claims{}, fmt.Errorf("%w: el token no tiene tres partes", ErrInvalidToken)
+	}
+
+	var header jwtHeader
+	if err := decodeJWTPart(parts[0], &header); err != nil {
+		return Claims{}, fmt.Errorf("%w: encabezado inválido", ErrInvalidToken)
+	}
+	if header.Algorithm != "HS256" {
+		return Claims{}, fmt.Errorf("%w: el algoritmo %q no está soportado", ErrInvalidToken, header.Algorithm)
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return Claims{}, fmt.Errorf("%w: firma inválida", ErrInvalidToken)
+	}
+	if !hmac.Equal(signature, signJWT(verifier.secret, parts[0]+"."+parts[1])) {
+		return Claims{}, fmt.Errorf("%w: la firma no coincide", ErrInvalidToken)
+	}
+
+	var claims Claims
+	if err := decodeJWTPart(parts[1], &claims); err != nil {
+		return Claims{}, fmt.Errorf("%w: claims inválidos", ErrInvalidToken)
+	}
+
+	now := verifier.now()
+
+	if claims.ExpiresAt == 0 {
+		return Claims{}, fmt.Errorf("%w: el claim exp es requerido", ErrInvalidToken)
+	}
+	if !now.Before(time.Unix(claims.ExpiresAt, 0).Add(verifier.leeway)) {
+		return Claims{}, ErrExpiredToken
+	}
+	if claims.NotBefore != 0 && now.Add(verifier.leeway).Before(time.Unix(claims.NotBefore, 0)) {
+		return Claims{}, fmt.Errorf("%w: el token todavía no es válido", ErrInvalidToken)
+	}
+
+	if verifier.issuer != "" && claims.Issuer != verifier.issuer {
+		return Claims{}, fmt.Errorf("%w: el emisor no es válido", ErrInvalidToken)
+	}
+	if verifier.audience != "" && !slices.Contains(claims.Audience, verifier.audience) {
+		return Claims{}, fmt.Errorf("%w: la audiencia no es válida", ErrInvalidToken)
+	}
+
+	if claims.Subject == "" {
+		return Claims{}, fmt.Errorf("%w: el claim sub es requerido", ErrInvalidToken)
+	}
+
+	return claims, nil
+}
+
+// SignJWT firma los claims con HS256 y devuelve el token compacto
+func SignJWT(secret string, claims Claims) (string, error) {
+
+	header, err := json.Marshal(jwtHeader{Algorithm: "HS256", Type: "JWT"})
+	if err != nil {
+		return "", err
+	}
+
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+
+	unsigned := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	signature := signJWT([]byte(secret), unsigned)
+
+	return unsigned + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+func signJWT(secret []byte, unsigned string) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(unsigned))
+	return mac.Sum(nil)
+}
+
+func decodeJWTPart(part string, target any) error {
+
+	content, err := base64.RawURLEncoding.DecodeString(part)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(content, target)
+}
@@ -0,0 +1,122 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"time"
+)
+
+var (
+	ErrInvalidCredentials = errors.New("credenciales de autentificación inválidas")
+	ErrExpiredCredentials = errors.New("credenciales de autentificación vencidas")
+)
+
+// APIKey es una clave con nombre del keyring, con sus permisos y su vencimiento opcional
+type APIKey struct {
+	Name   string   `json:"name"`
+	Key    string   `json:"key"`
+	Scopes []string `json:"scopes"`
+	// ExpiresAt es la fecha de vencimiento (dd/mm/aaaa); la clave es válida hasta el final de ese día
+	ExpiresAt string `json:"expires_at,omitempty"`
+}
+
+type keyringEntry struct {
+	name      string
+	hash      [sha256.Size]byte
+	scopes    []string
+	expiresAt *time.Time
+}
+
+// Keyring contiene las claves de API habilitadas
+type Keyring struct {
+	entries []keyringEntry
+	now     func() time.Time
+}
+
+// función para crear un keyring a partir de una lista de claves
+func NewKeyring(keys []APIKey) (*Keyring, error) {
+
+	keyring := &Keyring{now: time.Now}
+	names := make(map[string]bool)
+
+	for i, key := range keys {
+		if key.Name == "" {
+			return nil, fmt.Errorf("La clave %d del keyring no tiene nombre", i+1)
+		}
+		if names[key.Name] {
+			return nil, fmt.Errorf("La clave %s del keyring está repetida", key.Name)
+		}
+		names[key.Name] = true
+
+		if key.Key == "" {
+			return nil, fmt.Errorf("La clave %s del keyring está vacía", key.Name)
+		}
+
+		for _, scope := range key.Scopes {
+			if !slices.Contains(AllScopes, scope) {
+				return nil, fmt.Errorf("La clave %s del keyring tiene un permiso inválido: %s", key.Name, scope)
+			}
+		}
+
+		entry := keyringEntry{name: key.Name, hash: sha256.Sum256([]byte(key.Key)), scopes: slices.Clone(key.Scopes)}
+
+		if key.ExpiresAt != "" {
+			expiresAt, err := time.ParseInLocation("02/01/2006", key.ExpiresAt, time.Local)
+			if err != nil {
+				return nil, fmt.Errorf("La fecha de vencimiento de la clave %s no posee un formato válido", key.Name)
+			}
+			expiresAt = expiresAt.AddDate(0, 0, 1)
+			entry.expiresAt = &expiresAt
+		}
+
+		keyring.entries = append(keyring.entries, entry)
+	}
+
+	return keyring, nil
+}
+
+// función para cargar el keyring desde un archivo JSON con una lista de claves
+func LoadKeyring(fileName string) (*Keyring, error) {
+
+	content, err := os.ReadFile(fileName)
+	if err != nil {
+		return nil, fmt.Errorf("Error al abrir el keyring: %v", err)
+	}
+
+	var keys []APIKey
+	if err := json.Unmarshal(content, &keys); err != nil {
+		return nil, fmt.Errorf("Error al deserializar el keyring: %v", err)
+	}
+
+	return NewKeyring(keys)
+}
+
+// Authenticate devuelve la identidad asociada a la clave. La comparación es de tiempo
+// constante y recorre siempre todas las claves, para no revelar cuál coincidió.
+func (keyring *Keyring) Authenticate(key string) (Identity, error) {
+
+	hash := sha256.Sum256([]byte(key))
+
+	match := -1
+	for i, entry := range keyring.entries {
+		if subtle.ConstantTimeCompare(hash[:], entry.hash[:]) == 1 {
+			match = i
+		}
+	}
+
+	if match == -1 {
+		return Identity{}, ErrInvalidCredentials
+	}
+
+	entry := keyring.entries[match]
+	if entry.expiresAt != nil && !keyring.now().Before(*entry.expiresAt) {
+		return Identity{}, ErrExpiredCredentials
+	}
+
+	return Identity{Name: entry.name, Scopes: slices.Clone(entry.scopes)}, nil
+}
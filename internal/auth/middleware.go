@@ -0,0 +1,114 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"PRACTICAS-GO-WEB/pkg/web"
+)
+
+// HeaderToken es el encabezado en el que se envía la clave de API
+const HeaderToken = "Token"
+
+// realm es el ámbito informado en el encabezado WWW-Authenticate
+const realm = "products"
+
+// Authenticate es un middleware que autentica la solicitud y deja la identidad en su contexto.
+// Un token Bearer en Authorization se valida como JWT (si jwt no es nil) y una clave en el
+// encabezado Token se valida contra el keyring. Las solicitudes sin credenciales continúan
+// sin identidad; las que envían credenciales inválidas se rechazan.
+func Authenticate(keyring *Keyring, jwt *JWTVerifier) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+			if bearer, ok := bearerToken(r); ok && jwt != nil {
+				claims, err := jwt.Verify(bearer)
+				if err != nil {
+					log.Printf("JWT rechazado para %s %s: %s", r.Method, r.URL.Path, err)
+					// error_description solo admite caracteres ASCII (RFC 6750)
+					detail, description := "El token de acceso es inválido", "El token de acceso es invalido"
+					if errors.Is(err, ErrExpiredToken) {
+						detail, description = "El token de acceso está vencido", "El token de acceso esta vencido"
+					}
+					challenge(w, fmt.Sprintf(`error="invalid_token", error_description=%q`, description))
+					web.Problem(w, r, http.StatusUnauthorized, detail)
+					return
+				}
+
+				next.ServeHTTP(w, r.WithContext(ContextWithIdentity(r.Context(), claims.Identity())))
+				return
+			}
+
+			key := r.Header.Get(HeaderToken)
+			if key == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			identity, err := keyring.Authenticate(key)
+			if err != nil {
+				log.Printf("Autentificación rechazada para %s %s: %s", r.Method, r.URL.Path, err)
+				detail := "Token de autentificación inválido"
+				if errors.Is(err, ErrExpiredCredentials) {
+					detail = "Token de autentificación vencido"
+				}
+				challenge(w, "")
+				web.Problem(w, r, http.StatusUnauthorized, detail)
+				return
+			}
+
+			next.ServeHTTP(w, r.WithContext(ContextWithIdentity(r.Context(), identity)))
+		})
+	}
+}
+
+// RequireScope es un middleware que exige una identidad autenticada con el permiso indicado
+func RequireScope(scope string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+			identity, ok := IdentityFromContext(r.Context())
+			if !ok {
+				challenge(w, "")
+				web.Problem(w, r, http.StatusUnauthorized, "Token de autentificación requerido")
+				return
+			}
+
+			if !identity.HasScope(scope) {
+				log.Printf("Acceso denegado a %s para %s %s: falta el permiso %s", identity.Name, r.Method, r.URL.Path, scope)
+				challenge(w, fmt.Sprintf(`error="insufficient_scope", scope=%q`, scope))
+				web.Problem(w, r, http.StatusForbidden, fmt.Sprintf("El permiso %s es requerido", scope))
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// bearerToken devuelve el token del encabezado Authorization con esquema Bearer
+func bearerToken(r *http.Request) (string, bool) {
+
+	scheme, token, found := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !found || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+
+	token = strings.TrimSpace(token)
+
+	return token, token != ""
+}
+
+// challenge agrega el encabezado WWW-Authenticate con los parámetros indicados
+func challenge(w http.ResponseWriter, params string) {
+
+	value := fmt.Sprintf(`Bearer realm=%q`, realm)
+	if params != "" {
+		value += ", " + params
+	}
+
+	w.Header().Set("WWW-Authenticate", value)
+}
@@ -0,0 +1,184 @@
+package domain
+
+import (
+	"maps"
+	"reflect"
+	"slices"
+	"time"
+)
+
+// AuditOperation es el tipo de mutación registrada en la auditoría
+type AuditOperation string
+
+const (
+	AuditCreate  AuditOperation = "create"
+	AuditUpdate  AuditOperation = "update"
+	AuditPatch   AuditOperation = "patch"
+	AuditDelete  AuditOperation = "delete"
+	AuditRestore AuditOperation = "restore"
+	AuditPurge   AuditOperation = "purge"
+	// AuditSchedulePrice registra un precio programado y AuditApplyPrice la entrada en
+	// vigencia de un precio programado
+	AuditSchedulePrice AuditOperation = "schedule_price"
+	AuditApplyPrice    AuditOperation = "apply_price"
+	// AuditUnassignCategory registra que se quitó del producto una categoría eliminada
+	AuditUnassignCategory AuditOperation = "unassign_category"
+	// AuditSetStock registra que se fijó la existencia del producto en un depósito
+	AuditSetStock AuditOperation = "set_stock"
+	// AuditTransferStock registra una transferencia de existencias entre depósitos
+	AuditTransferStock AuditOperation = "transfer_stock"
+	// AuditStockMovement registra el cambio de existencias por un movimiento del libro de stock
+	AuditStockMovement AuditOperation = "stock_movement"
+	// AuditUnpublishExpired registra que se despublicó un producto vencido
+	AuditUnpublishExpired AuditOperation = "unpublish_expired"
+	// AuditReserveStock, AuditConfirmReservation, AuditReleaseReservation y
+	// AuditExpireReservation registran el cambio de la existencia reservada del producto
+	AuditReserveStock       AuditOperation = "reserve_stock"
+	AuditConfirmReservation AuditOperation = "confirm_reservation"
+	AuditReleaseReservation AuditOperation = "release_reservation"
+	AuditExpireReservation  AuditOperation = "expire_reservation"
+)
+
+// AuditActorSystem es el actor de las mutaciones que no provienen de una solicitud autenticada,
+// como las tareas periódicas
+const AuditActorSystem = "system"
+
+// FieldChange es el valor de un campo antes y después de una mutación. Before es nil en
+// los campos agregados y After es nil en los eliminados.
+type FieldChange struct {
+	Field  string `json:"field"`
+	Before any    `json:"before"`
+	After  any    `json:"after"`
+}
+
+// AuditEntry registra quién realizó una mutación sobre un producto, cuándo y qué cambió
+type AuditEntry struct {
+	ID        int            `json:"id"`
+	Timestamp time.Time      `json:"timestamp"`
+	Actor     string         `json:"actor"`
+	RequestID string         `json:"request_id,omitempty"`
+	Operation AuditOperation `json:"operation"`
+	ProductID int            `json:"product_id"`
+	CodeValue string         `json:"code_value"`
+	Changes   []FieldChange  `json:"changes"`
+}
+
+// AuditFilter son los criterios de búsqueda de la auditoría; los nil no se aplican
+type AuditFilter struct {
+	ProductID *int
+	Actor     *string
+	// From y To limitan el instante de la mutación, ambos inclusive
+	From *time.Time
+	To   *time.Time
+}
+
+// Matches indica si la entrada cumple todos los criterios del filtro
+func (filter AuditFilter) Matches(entry AuditEntry) bool {
+
+	if filter.ProductID != nil && entry.ProductID != *filter.ProductID {
+		return false
+	}
+
+	if filter.Actor != nil && entry.Actor != *filter.Actor {
+		return false
+	}
+
+	if filter.From != nil && entry.Timestamp.Before(*filter.From) {
+		return false
+	}
+
+	if filter.To != nil && entry.Timestamp.After(*filter.To) {
+		return false
+	}
+
+	return true
+
+}
+
+// AuditEntryID devuelve el ID de la entrada, para paginar por cursor
+func AuditEntryID(entry AuditEntry) int {
+	return entry.ID
+}
+
+// DiffProducts devuelve los campos que cambian entre dos versiones de un producto, tal como
+// se ven en las respuestas. before es nil en un alta y after es nil en una eliminación
+// definitiva.
+func DiffProducts(before *Product, after *Product) []FieldChange {
+
+	beforeFields := productFields(before)
+	afterFields := productFields(after)
+
+	names := make([]string, 0, len(beforeFields)+len(afterFields))
+	for name := range beforeFields {
+		names = append(names, name)
+	}
+	for name := range afterFields {
+		if _, exists := beforeFields[name]; !exists {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+
+	changes := []FieldChange{}
+	for _, name := range names {
+		beforeValue, afterValue := beforeFields[name], afterFields[name]
+		if reflect.DeepEqual(beforeValue, afterValue) {
+			continue
+		}
+		changes = append(changes, FieldChange{Field: name, Before: beforeValue, After: afterValue})
+	}
+
+	return changes
+
+}
+
+// productFields devuelve los campos auditables del producto por nombre, con el mismo formato
+// que en las respuestas. Los campos sin valor son nil.
+func productFields(product *Product) map[string]any {
+
+	if product == nil {
+		return map[string]any{}
+	}
+
+	response := ProductResponseFromProductBase(*product)
+
+	fields := map[string]any{
+		"name":            response.Name,
+		"quantity":        response.Quantity,
+		"reserved":        response.Reserved,
+		"code_value":      response.CodeValue,
+		"expiration_date": nil,
+		"is_published":    response.IsPublished,
+		// La moneda se audita en su propio campo para no informar el precio como cambiado
+		"price":         NewMoney(response.Price.Amount, ""),
+		"currency":      response.Currency,
+		"deleted_at":    nil,
+		"prices":        pricePointsToStorage(product.Prices, response.Currency),
+		"native_prices": nil,
+		"category_ids":  nil,
+		"stock":         nil,
+		"lots":          nil,
+	}
+	// Se audita la fecha propia del producto; la de sus lotes se audita con los lotes
+	if product.Expiration != nil {
+		fields["expiration_date"] = product.Expiration.Format("02/01/2006")
+	}
+	if response.DeletedAt != nil {
+		fields["deleted_at"] = *response.DeletedAt
+	}
+	if len(product.CategoryIDs) > 0 {
+		fields["category_ids"] = slices.Clone(product.CategoryIDs)
+	}
+	if len(product.NativePrices) > 0 {
+		fields["native_prices"] = nativePricesFromMap(product.NativePrices)
+	}
+	if product.HasStock() {
+		fields["stock"] = maps.Clone(product.Stock)
+	}
+	if len(product.Lots) > 0 {
+		fields["lots"] = lotsToStorage(product.Lots)
+	}
+
+	return fields
+
+}
@@ -0,0 +1,181 @@
+package domain
+
+import (
+	"PRACTICAS-GO-WEB/internal/query"
+	"cmp"
+	"slices"
+	"strings"
+)
+
+// Category es una categoría del catálogo. Las categorías forman un árbol: ParentID es nil en
+// las categorías raíz.
+type Category struct {
+	ID       int
+	Name     string
+	ParentID *int
+}
+
+type CategoryStorage struct {
+	ID       int    `json:"id"`
+	Name     string `json:"name"`
+	ParentID *int   `json:"parent_id,omitempty"`
+}
+
+type CategoryResponse struct {
+	ID       int    `json:"id"`
+	Name     string `json:"name"`
+	ParentID *int   `json:"parent_id"`
+}
+
+// CategoryTreeResponse es una categoría con sus subcategorías
+type CategoryTreeResponse struct {
+	ID       int                    `json:"id"`
+	Name     string                 `json:"name"`
+	Children []CategoryTreeResponse `json:"children"`
+}
+
+type CategoryRequest struct {
+	Name     *string `json:"name"`
+	ParentID *int    `json:"parent_id"`
+}
+
+// CategoryFields son los nombres de los campos de una categoría que se pueden ordenar y seleccionar
+var CategoryFields = []string{"id", "name", "parent_id"}
+
+// CategoryComparators compara categorías por cada uno de los campos ordenables
+var CategoryComparators = map[string]query.Comparator[Category]{
+	"id":   func(a, b Category) int { return cmp.Compare(a.ID, b.ID) },
+	"name": func(a, b Category) int { return strings.Compare(strings.ToLower(a.Name), strings.ToLower(b.Name)) },
+	"parent_id": func(a, b Category) int {
+		// Las categorías raíz quedan primero
+		switch {
+		case a.ParentID == nil && b.ParentID == nil:
+			return 0
+		case a.ParentID == nil:
+			return -1
+		case b.ParentID == nil:
+			return 1
+		}
+		return cmp.Compare(*a.ParentID, *b.ParentID)
+	},
+}
+
+// CategoryID devuelve el ID de la categoría, para paginar por cursor
+func CategoryID(category Category) int {
+	return category.ID
+}
+
+// Clone devuelve una copia de la categoría que no comparte memoria con la original
+func (category Category) Clone() Category {
+
+	if category.ParentID != nil {
+		parentID := *category.ParentID
+		category.ParentID = &parentID
+	}
+
+	return category
+
+}
+
+// Validate verifica la solicitud de alta o reemplazo de una categoría
+func (categoryRequest CategoryRequest) Validate() error {
+
+	var validation ValidationError
+
+	if categoryRequest.Name == nil || strings.TrimSpace(*categoryRequest.Name) == "" {
+		validation.Add("name", "El nombre de la categoría es un campo requerido")
+	}
+
+	if categoryRequest.ParentID != nil && *categoryRequest.ParentID <= 0 {
+		validation.Add("parent_id", "La categoría padre debe ser un ID válido")
+	}
+
+	return validation.Err()
+
+}
+
+func CategoryFromCategoryRequest(categoryRequest CategoryRequest) Category {
+
+	category := Category{ParentID: categoryRequest.ParentID}
+	if categoryRequest.Name != nil {
+		category.Name = strings.TrimSpace(*categoryRequest.Name)
+	}
+
+	return category.Clone()
+
+}
+
+func CategoryResponseFromCategory(category Category) CategoryResponse {
+
+	category = category.Clone()
+
+	return CategoryResponse{ID: category.ID, Name: category.Name, ParentID: category.ParentID}
+
+}
+
+func CategoryResponsesFromCategories(categories []Category) []CategoryResponse {
+
+	responses := make([]CategoryResponse, len(categories))
+	for i, category := range categories {
+		responses[i] = CategoryResponseFromCategory(category)
+	}
+
+	return responses
+
+}
+
+// CategoryTreeFromCategories arma el árbol de categorías; los hermanos se ordenan por nombre
+func CategoryTreeFromCategories(categories []Category) []CategoryTreeResponse {
+
+	children := make(map[int][]Category)
+	var roots []Category
+	for _, category := range categories {
+		if category.ParentID == nil {
+			roots = append(roots, category)
+			continue
+		}
+		children[*category.ParentID] = append(children[*category.ParentID], category)
+	}
+
+	var build func(level []Category) []CategoryTreeResponse
+	build = func(level []Category) []CategoryTreeResponse {
+		slices.SortFunc(level, CategoryComparators["name"])
+		nodes := make([]CategoryTreeResponse, len(level))
+		for i, category := range level {
+			nodes[i] = CategoryTreeResponse{ID: category.ID, Name: category.Name, Children: build(children[category.ID])}
+		}
+		return nodes
+	}
+
+	return build(roots)
+
+}
+
+func CategoryFromCategoryStorage(categoryStorage CategoryStorage) Category {
+
+	return Category{ID: categoryStorage.ID, Name: categoryStorage.Name, ParentID: categoryStorage.ParentID}.Clone()
+
+}
+
+func CategoriesFromCategoriesStorage(categoriesStorage []CategoryStorage) []Category {
+
+	var categories []Category
+	for _, categoryStorage := range categoriesStorage {
+		categories = append(categories, CategoryFromCategoryStorage(categoryStorage))
+	}
+
+	return categories
+
+}
+
+func CategoriesStorageFromCategories(categories []Category) []CategoryStorage {
+
+	categoriesStorage := make([]CategoryStorage, len(categories))
+	for i, category := range categories {
+		category = category.Clone()
+		categoriesStorage[i] = CategoryStorage{ID: category.ID, Name: category.Name, ParentID: category.ParentID}
+	}
+
+	return categoriesStorage
+
+}
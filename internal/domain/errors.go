@@ -0,0 +1,119 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Categorías de error del dominio; se comparan con errors.Is
+var (
+	ErrNotFound           = errors.New("recurso no encontrado")
+	ErrDuplicateCodeValue = errors.New("codigo de producto duplicado")
+	ErrValidation         = errors.New("datos inválidos")
+	ErrStorage            = errors.New("error de almacenamiento")
+	// ErrVersionMismatch indica que el recurso cambió desde la versión que conocía el cliente
+	ErrVersionMismatch = errors.New("la versión del recurso no coincide")
+	// ErrPreconditionRequired indica que la operación requiere indicar la versión esperada
+	ErrPreconditionRequired = errors.New("se requiere la versión esperada del recurso")
+	// ErrUnsupportedCurrency indica una moneda que no figura en la tabla de cotizaciones
+	ErrUnsupportedCurrency = errors.New("moneda no admitida")
+	// ErrConflict indica que la operación no es compatible con el estado actual del recurso
+	ErrConflict = errors.New("conflicto con el estado del recurso")
+)
+
+// Error asocia un mensaje descriptivo a una de las categorías de error del dominio
+type Error struct {
+	Kind    error
+	Message string
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.Kind
+}
+
+// NewError crea un error de la categoría kind con el mensaje indicado
+func NewError(kind error, format string, args ...any) error {
+	return &Error{Kind: kind, Message: fmt.Sprintf(format, args...)}
+}
+
+// FieldError describe el motivo por el que un campo no es válido
+type FieldError struct {
+	Field  string
+	Reason string
+}
+
+// ValidationError agrupa todos los campos inválidos de una validación
+type ValidationError struct {
+	Fields []FieldError
+}
+
+// Add agrega un campo inválido a la validación
+func (e *ValidationError) Add(field string, reason string) {
+	e.Fields = append(e.Fields, FieldError{Field: field, Reason: reason})
+}
+
+// Has indica si el campo ya fue informado como inválido
+func (e *ValidationError) Has(field string) bool {
+	for _, fieldError := range e.Fields {
+		if fieldError.Field == field {
+			return true
+		}
+	}
+	return false
+}
+
+// Err devuelve el error de validación, o nil si no se registró ningún campo inválido
+func (e *ValidationError) Err() error {
+	if len(e.Fields) == 0 {
+		return nil
+	}
+	return e
+}
+
+func (e *ValidationError) Error() string {
+
+	reasons := make([]string, len(e.Fields))
+	for i, field := range e.Fields {
+		reasons[i] = field.Reason
+	}
+
+	return strings.Join(reasons, "; ")
+}
+
+func (e *ValidationError) Unwrap() error {
+	return ErrValidation
+}
+
+// Merge incorpora los campos de otro error de validación; cualquier otro error se devuelve sin cambios
+func (e *ValidationError) Merge(err error) error {
+
+	var validation *ValidationError
+	if errors.As(err, &validation) {
+		e.Fields = append(e.Fields, validation.Fields...)
+		return nil
+	}
+
+	return err
+}
+
+// MergeNew incorpora los campos de otro error de validación que aún no fueron informados;
+// cualquier otro error se devuelve sin cambios
+func (e *ValidationError) MergeNew(err error) error {
+
+	var validation *ValidationError
+	if errors.As(err, &validation) {
+		for _, field := range validation.Fields {
+			if !e.Has(field.Field) {
+				e.Fields = append(e.Fields, field)
+			}
+		}
+		return nil
+	}
+
+	return err
+}
@@ -0,0 +1,246 @@
+package domain
+
+import (
+	"encoding/json"
+	"maps"
+	"math/big"
+	"slices"
+	"strings"
+	"time"
+)
+
+// RoundingMode es la forma de redondear un importe convertido al incremento de su moneda
+type RoundingMode string
+
+const (
+	// RoundHalfUp redondea al incremento más cercano y los empates se alejan de cero
+	RoundHalfUp RoundingMode = "half_up"
+	// RoundHalfEven redondea al incremento más cercano y los empates van al múltiplo par
+	RoundHalfEven RoundingMode = "half_even"
+	// RoundUp redondea alejándose de cero
+	RoundUp RoundingMode = "up"
+	// RoundDown redondea hacia cero
+	RoundDown RoundingMode = "down"
+)
+
+// Origen del precio informado en una respuesta
+const (
+	// PriceNative es un precio cargado en la moneda informada
+	PriceNative = "native"
+	// PriceConverted es un precio convertido con la tabla de cotizaciones
+	PriceConverted = "converted"
+)
+
+// RoundingRule es el redondeo de los importes convertidos a una moneda. Increment está
+// expresado en unidades menores; por ejemplo, 100 redondea a unidades enteras.
+type RoundingRule struct {
+	Increment int64
+	Mode      RoundingMode
+}
+
+// defaultRoundingRule redondea al centavo más cercano
+var defaultRoundingRule = RoundingRule{Increment: 1, Mode: RoundHalfUp}
+
+// ExchangeRates es la tabla de cotizaciones. Cada cotización indica cuántas unidades de la
+// moneda equivalen a una unidad de la moneda base; las conversiones entre dos monedas que no
+// son la base pasan por ella.
+type ExchangeRates struct {
+	Base     string
+	Rates    map[string]*big.Rat
+	Rounding map[string]RoundingRule
+	LoadedAt time.Time
+}
+
+// ExchangeRatesStorage es el formato del archivo de cotizaciones. Las cotizaciones se
+// aceptan como números o como textos decimales.
+type ExchangeRatesStorage struct {
+	Base     string                         `json:"base"`
+	Rates    map[string]json.Number         `json:"rates"`
+	Rounding map[string]RoundingRuleStorage `json:"rounding,omitempty"`
+}
+
+type RoundingRuleStorage struct {
+	// Increment es el múltiplo al que se redondea, en unidades de la moneda (por ejemplo, 1 o 0.05)
+	Increment Money        `json:"increment"`
+	Mode      RoundingMode `json:"mode"`
+}
+
+type ExchangeRatesResponse struct {
+	Base     string                         `json:"base"`
+	Rates    map[string]json.Number         `json:"rates"`
+	Rounding map[string]RoundingRuleStorage `json:"rounding"`
+	LoadedAt string                         `json:"loaded_at"`
+}
+
+// ExchangeRatesFromStorage valida el archivo de cotizaciones y devuelve la tabla
+func ExchangeRatesFromStorage(ratesStorage ExchangeRatesStorage, loadedAt time.Time) (ExchangeRates, error) {
+
+	var validation ValidationError
+
+	if !ValidCurrency(ratesStorage.Base) {
+		validation.Add("base", "La moneda base debe ser un código ISO 4217 de tres letras mayúsculas")
+	}
+
+	rates := make(map[string]*big.Rat, len(ratesStorage.Rates))
+	for _, currency := range slices.Sorted(maps.Keys(ratesStorage.Rates)) {
+		field := "rates." + currency
+		rate, ok := new(big.Rat).SetString(ratesStorage.Rates[currency].String())
+		switch {
+		case !ValidCurrency(currency):
+			validation.Add(field, "La moneda debe ser un código ISO 4217 de tres letras mayúsculas")
+		case currency == ratesStorage.Base:
+			validation.Add(field, "La moneda base no lleva cotización")
+		case !ok || !decimalPattern.MatchString(ratesStorage.Rates[currency].String()):
+			validation.Add(field, "La cotización no es un número decimal válido")
+		case rate.Sign() <= 0:
+			validation.Add(field, "La cotización debe ser mayor que cero")
+		default:
+			rates[currency] = rate
+		}
+	}
+
+	rounding := make(map[string]RoundingRule, len(ratesStorage.Rounding))
+	for _, currency := range slices.Sorted(maps.Keys(ratesStorage.Rounding)) {
+		field := "rounding." + currency
+		rule := ratesStorage.Rounding[currency]
+		if rule.Mode == "" {
+			rule.Mode = RoundHalfUp
+		}
+		switch {
+		case currency != ratesStorage.Base && ratesStorage.Rates[currency] == "":
+			validation.Add(field, "La moneda no figura en la tabla de cotizaciones")
+		case rule.Increment.Amount <= 0:
+			validation.Add(field, "El incremento de redondeo debe ser mayor que cero")
+		case !slices.Contains([]RoundingMode{RoundHalfUp, RoundHalfEven, RoundUp, RoundDown}, rule.Mode):
+			validation.Add(field, "El modo de redondeo debe ser half_up, half_even, up o down")
+		default:
+			rounding[currency] = RoundingRule{Increment: rule.Increment.Amount, Mode: rule.Mode}
+		}
+	}
+
+	if err := validation.Err(); err != nil {
+		return ExchangeRates{}, err
+	}
+
+	return ExchangeRates{Base: ratesStorage.Base, Rates: rates, Rounding: rounding, LoadedAt: loadedAt}, nil
+
+}
+
+// Supports indica si la tabla permite convertir importes a la moneda indicada
+func (rates *ExchangeRates) Supports(currency string) bool {
+
+	if rates == nil {
+		return false
+	}
+
+	_, exists := rates.Rates[currency]
+
+	return exists || currency == rates.Base
+}
+
+// Convert convierte el importe a la moneda indicada y lo redondea según la regla de esa
+// moneda. Un importe que ya está en esa moneda se devuelve sin cambios.
+func (rates *ExchangeRates) Convert(money Money, currency string) (Money, error) {
+
+	if money.Currency == currency {
+		return money, nil
+	}
+
+	for _, code := range []string{money.Currency, currency} {
+		if !rates.Supports(code) {
+			return Money{}, NewError(ErrUnsupportedCurrency, "La moneda %s no figura en la tabla de cotizaciones", code)
+		}
+	}
+
+	// Todas las monedas usan la misma cantidad de decimales, por lo que la conversión se
+	// calcula directamente sobre las unidades menores
+	value := new(big.Rat).SetInt64(money.Amount)
+	value.Mul(value, rates.rate(currency))
+	value.Quo(value, rates.rate(money.Currency))
+
+	rule, exists := rates.Rounding[currency]
+	if !exists {
+		rule = defaultRoundingRule
+	}
+
+	amount, ok := rule.round(value)
+	if !ok {
+		return Money{}, NewError(ErrUnsupportedCurrency, "El importe %s %s excede el máximo admitido en %s", money, money.Currency, currency)
+	}
+
+	return Money{Amount: amount, Currency: currency}, nil
+
+}
+
+// rate devuelve la cotización de la moneda respecto de la base
+func (rates *ExchangeRates) rate(currency string) *big.Rat {
+
+	if currency == rates.Base {
+		return big.NewRat(1, 1)
+	}
+
+	return rates.Rates[currency]
+}
+
+// round redondea un importe en unidades menores al incremento de la regla
+func (rule RoundingRule) round(value *big.Rat) (int64, bool) {
+
+	steps := new(big.Rat).Quo(value, new(big.Rat).SetInt64(rule.Increment))
+
+	quotient, remainder := new(big.Int).QuoRem(steps.Num(), steps.Denom(), new(big.Int))
+	if remainder.Sign() != 0 {
+		// La comparación del doble del resto con el divisor indica si se pasó de la mitad
+		half := new(big.Int).Abs(remainder)
+		half.Lsh(half, 1)
+		position := half.Cmp(steps.Denom())
+
+		awayFromZero := false
+		switch rule.Mode {
+		case RoundUp:
+			awayFromZero = true
+		case RoundHalfUp:
+			awayFromZero = position >= 0
+		case RoundHalfEven:
+			awayFromZero = position > 0 || (position == 0 && quotient.Bit(0) == 1)
+		}
+
+		if awayFromZero {
+			quotient.Add(quotient, big.NewInt(int64(steps.Sign())))
+		}
+	}
+
+	quotient.Mul(quotient, big.NewInt(rule.Increment))
+	if !quotient.IsInt64() {
+		return 0, false
+	}
+
+	return quotient.Int64(), true
+
+}
+
+// ExchangeRatesResponseFromExchangeRates devuelve la tabla con el formato del archivo
+func ExchangeRatesResponseFromExchangeRates(rates ExchangeRates) ExchangeRatesResponse {
+
+	response := ExchangeRatesResponse{
+		Base:     rates.Base,
+		Rates:    make(map[string]json.Number, len(rates.Rates)),
+		Rounding: make(map[string]RoundingRuleStorage, len(rates.Rounding)),
+		LoadedAt: rates.LoadedAt.Format(time.RFC3339),
+	}
+
+	for currency, rate := range rates.Rates {
+		// Las cotizaciones se leen de decimales, por lo que tienen una representación exacta
+		text := strings.TrimRight(rate.FloatString(12), "0")
+		response.Rates[currency] = json.Number(strings.TrimSuffix(text, "."))
+	}
+
+	for currency, rule := range rates.Rounding {
+		response.Rounding[currency] = RoundingRuleStorage{
+			Increment: NewMoney(rule.Increment, currency),
+			Mode:      rule.Mode,
+		}
+	}
+
+	return response
+
+}
@@ -0,0 +1,60 @@
+package domain
+
+import "time"
+
+// startOfDay devuelve el comienzo del día de t en UTC
+func startOfDay(t time.Time) time.Time {
+
+	year, month, day := t.UTC().Date()
+
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+
+}
+
+// IsExpired indica si el producto venció antes del día de now, según su vencimiento efectivo.
+// Un producto vence al terminar el día de su fecha de expiración, que no tiene hora y se
+// compara con el día en UTC.
+func (product Product) IsExpired(now time.Time) bool {
+
+	expiration := product.EffectiveExpiration()
+
+	return expiration != nil && expiration.Before(startOfDay(now))
+
+}
+
+// ExpiredFilter devuelve el filtro de los productos vencidos antes del día de now
+func ExpiredFilter(filter ProductFilter, now time.Time) ProductFilter {
+
+	before := startOfDay(now).Add(-time.Nanosecond)
+	filter.ExpirationBefore = &before
+	filter.ExpirationAfter = nil
+
+	return filter
+
+}
+
+// ExpiringFilter devuelve el filtro de los productos que todavía no vencieron y vencen en los
+// días que abarca within a partir del día de now, ambos inclusive
+func ExpiringFilter(filter ProductFilter, now time.Time, within time.Duration) ProductFilter {
+
+	after := startOfDay(now)
+	before := startOfDay(now.Add(within))
+	filter.ExpirationAfter = &after
+	filter.ExpirationBefore = &before
+
+	return filter
+
+}
+
+// UnpublishExpired despublica el producto si está vencido e indica si cambió
+func (product *Product) UnpublishExpired(now time.Time) bool {
+
+	if !product.IsPublished || !product.IsExpired(now) {
+		return false
+	}
+
+	product.IsPublished = false
+
+	return true
+
+}
@@ -0,0 +1,213 @@
+package domain
+
+import (
+	"cmp"
+	"maps"
+	"slices"
+	"time"
+)
+
+// Lot es un lote de un producto: la mercadería recibida con un mismo código y vencimiento.
+// Quantity es la existencia que queda del lote según el libro de stock.
+type Lot struct {
+	Code         string    `json:"code"`
+	Quantity     int       `json:"quantity"`
+	Expiration   time.Time `json:"expiration_date"`
+	ReceivedDate time.Time `json:"received_date"`
+}
+
+type LotStorage struct {
+	Code         string `json:"code"`
+	Quantity     int    `json:"quantity"`
+	Expiration   string `json:"expiration_date"`
+	ReceivedDate string `json:"received_date"`
+}
+
+type LotResponse struct {
+	Code         string `json:"code"`
+	Quantity     int    `json:"quantity"`
+	Expiration   string `json:"expiration_date"`
+	ReceivedDate string `json:"received_date"`
+}
+
+// LotMovement es la parte de un movimiento de stock que corresponde a un lote. Quantity tiene
+// el signo del movimiento. Expiration y ReceivedDate se informan en el ingreso que crea el lote.
+type LotMovement struct {
+	Code         string     `json:"code"`
+	Quantity     int        `json:"quantity"`
+	Expiration   *time.Time `json:"expiration_date,omitempty"`
+	ReceivedDate *time.Time `json:"received_date,omitempty"`
+}
+
+// compareLots ordena los lotes según se consumen: primero los que vencen antes y, a igual
+// vencimiento, los que se recibieron antes
+func compareLots(a, b Lot) int {
+
+	return cmp.Or(
+		a.Expiration.Compare(b.Expiration),
+		a.ReceivedDate.Compare(b.ReceivedDate),
+		cmp.Compare(a.Code, b.Code),
+	)
+
+}
+
+// ApplyLots aplica el movimiento sobre los lotes del producto, por código. Los lotes agotados
+// se conservan para conocer su vencimiento si se reciben devoluciones.
+func (movement StockMovement) ApplyLots(lots map[string]Lot) {
+
+	for _, lotMovement := range movement.Lots {
+		lot, exists := lots[lotMovement.Code]
+		if !exists {
+			lot = Lot{Code: lotMovement.Code}
+			if lotMovement.Expiration != nil {
+				lot.Expiration = *lotMovement.Expiration
+			}
+			if lotMovement.ReceivedDate != nil {
+				lot.ReceivedDate = *lotMovement.ReceivedDate
+			}
+		}
+		lot.Quantity += lotMovement.Quantity
+		lots[lotMovement.Code] = lot
+	}
+
+}
+
+// AllocateFEFO reparte un egreso de quantity unidades entre los lotes con existencias,
+// consumiendo primero los que vencen antes. La existencia sin lote, unlotted, vence con el
+// producto en expiration y sin fecha se consume al final; lo que no alcanza a cubrir ningún
+// lote también se descuenta de ella. Devuelve los egresos de cada lote, con signo negativo.
+func AllocateFEFO(lots map[string]Lot, unlotted int, expiration *time.Time, quantity int) []LotMovement {
+
+	available := slices.SortedFunc(maps.Values(lots), compareLots)
+	available = slices.DeleteFunc(available, func(lot Lot) bool { return lot.Quantity <= 0 })
+
+	var allocations []LotMovement
+	for _, lot := range available {
+		if quantity <= 0 {
+			break
+		}
+		// La existencia sin lote que vence antes que el lote se consume primero
+		if unlotted > 0 && expiration != nil && expiration.Before(lot.Expiration) {
+			taken := min(quantity, unlotted)
+			unlotted -= taken
+			quantity -= taken
+			if quantity == 0 {
+				break
+			}
+		}
+		taken := min(quantity, lot.Quantity)
+		allocations = append(allocations, LotMovement{Code: lot.Code, Quantity: -taken})
+		quantity -= taken
+	}
+
+	return allocations
+
+}
+
+// SetLots fija los lotes del producto según el libro de stock, sin los agotados y en el orden
+// en que se consumen
+func (product *Product) SetLots(lots map[string]Lot) {
+
+	product.Lots = slices.SortedFunc(maps.Values(lots), compareLots)
+	product.Lots = slices.DeleteFunc(product.Lots, func(lot Lot) bool { return lot.Quantity == 0 })
+
+	if len(product.Lots) == 0 {
+		product.Lots = nil
+	}
+
+}
+
+// LotQuantity es la existencia del producto que está en algún lote
+func (product Product) LotQuantity() int {
+
+	total := 0
+	for _, lot := range product.Lots {
+		total += lot.Quantity
+	}
+
+	return total
+
+}
+
+// EffectiveExpiration es el vencimiento del lote con existencias que vence primero o, si el
+// producto no tiene lotes con existencias, su propia fecha de expiración
+func (product Product) EffectiveExpiration() *time.Time {
+
+	for _, lot := range product.Lots {
+		if lot.Quantity > 0 {
+			expiration := lot.Expiration
+			return &expiration
+		}
+	}
+
+	return product.Expiration
+
+}
+
+// SameLots indica si dos listas de lotes son iguales
+func SameLots(a, b []Lot) bool {
+
+	return slices.EqualFunc(a, b, func(x, y Lot) bool {
+		return x.Code == y.Code && x.Quantity == y.Quantity && x.Expiration.Equal(y.Expiration) && x.ReceivedDate.Equal(y.ReceivedDate)
+	})
+
+}
+
+func lotsToStorage(lots []Lot) []LotStorage {
+
+	if len(lots) == 0 {
+		return nil
+	}
+
+	lotsStorage := make([]LotStorage, len(lots))
+	for i, lot := range lots {
+		lotsStorage[i] = LotStorage{
+			Code:         lot.Code,
+			Quantity:     lot.Quantity,
+			Expiration:   lot.Expiration.Format("02/01/2006"),
+			ReceivedDate: lot.ReceivedDate.Format("02/01/2006"),
+		}
+	}
+
+	return lotsStorage
+
+}
+
+func lotsFromStorage(lotsStorage []LotStorage) []Lot {
+
+	if len(lotsStorage) == 0 {
+		return nil
+	}
+
+	lots := make([]Lot, len(lotsStorage))
+	for i, lotStorage := range lotsStorage {
+		expiration, _ := time.Parse("02/01/2006", lotStorage.Expiration)
+		receivedDate, _ := time.Parse("02/01/2006", lotStorage.ReceivedDate)
+		lots[i] = Lot{
+			Code:         lotStorage.Code,
+			Quantity:     lotStorage.Quantity,
+			Expiration:   expiration,
+			ReceivedDate: receivedDate,
+		}
+	}
+
+	return lots
+
+}
+
+// LotResponsesFromLots devuelve los lotes con las fechas en el formato de las respuestas
+func LotResponsesFromLots(lots []Lot) []LotResponse {
+
+	lotsStorage := lotsToStorage(lots)
+	if lotsStorage == nil {
+		return nil
+	}
+
+	responses := make([]LotResponse, len(lotsStorage))
+	for i, lotStorage := range lotsStorage {
+		responses[i] = LotResponse(lotStorage)
+	}
+
+	return responses
+
+}
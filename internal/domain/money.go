@@ -0,0 +1,262 @@
+package domain
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"regexp"
+	"strings"
+)
+
+// DefaultCurrency es la moneda de los importes que no indican otra, como los precios de los
+// productos almacenados antes de registrar la moneda
+const DefaultCurrency = "USD"
+
+// MoneyDecimals es la cantidad de decimales de las unidades menores de todas las monedas
+const MoneyDecimals = 2
+
+// Money es un importe exacto expresado en unidades menores (centavos) de una moneda ISO 4217.
+// En JSON se escribe como un número decimal sin la moneda, que se informa en un campo aparte,
+// y se lee tanto de un número como de un texto. El importe no admite más decimales que las
+// unidades menores: nunca se redondea.
+type Money struct {
+	Amount   int64
+	Currency string
+}
+
+var (
+	decimalPattern  = regexp.MustCompile(`^[+-]?(\d+(\.\d*)?|\.\d+)([eE][+-]?\d{1,3})?$`)
+	currencyPattern = regexp.MustCompile(`^[A-Z]{3}$`)
+	minorUnits      = big.NewRat(100, 1)
+)
+
+// NewMoney crea un importe a partir de sus unidades menores
+func NewMoney(amount int64, currency string) Money {
+	return Money{Amount: amount, Currency: currency}
+}
+
+// StoredMoney es un importe leído del almacenamiento, que se escribe igual que Money. Los
+// precios guardados antes de usar importes exactos eran números de punto flotante y pueden
+// tener más decimales que las unidades menores: al leerlos se redondean a las unidades
+// menores con el redondeo bancario (la mitad, al par) y Rounded conserva el texto original.
+type StoredMoney struct {
+	Money
+	// Rounded es el importe almacenado si tenía más decimales y se redondeó al leerlo
+	Rounded string
+}
+
+// ParseMoney interpreta un importe decimal en la moneda indicada, como "71.42" o "1e2"
+func ParseMoney(text string, currency string) (Money, error) {
+
+	text = strings.TrimSpace(text)
+	value, err := parseMinorUnits(text)
+	if err != nil {
+		return Money{}, err
+	}
+
+	if !value.IsInt() {
+		return Money{}, fmt.Errorf("El importe %s tiene más de %d decimales", text, MoneyDecimals)
+	}
+
+	return moneyFromMinorUnits(text, value.Num(), currency)
+
+}
+
+// ParseMoneyHalfEven interpreta un importe decimal como ParseMoney, pero redondea a las
+// unidades menores los que tienen más decimales: la mitad se redondea al par, como 12.345 a
+// 12.34 y 12.355 a 12.36. Indica si el importe se redondeó.
+func ParseMoneyHalfEven(text string, currency string) (Money, bool, error) {
+
+	text = strings.TrimSpace(text)
+	value, err := parseMinorUnits(text)
+	if err != nil {
+		return Money{}, false, err
+	}
+
+	if value.IsInt() {
+		money, err := moneyFromMinorUnits(text, value.Num(), currency)
+		return money, false, err
+	}
+
+	// El cociente se trunca hacia cero; el doble del resto indica si supera la mitad
+	quotient, remainder := new(big.Int).QuoRem(value.Num(), value.Denom(), new(big.Int))
+	twice := new(big.Int).Lsh(remainder.Abs(remainder), 1)
+	switch twice.Cmp(value.Denom()) {
+	case 1:
+		quotient.Add(quotient, big.NewInt(int64(value.Sign())))
+	case 0:
+		if quotient.Bit(0) == 1 {
+			quotient.Add(quotient, big.NewInt(int64(value.Sign())))
+		}
+	}
+
+	money, err := moneyFromMinorUnits(text, quotient, currency)
+
+	return money, true, err
+
+}
+
+// parseMinorUnits interpreta un importe decimal y lo devuelve en unidades menores, sin redondear
+func parseMinorUnits(text string) (*big.Rat, error) {
+
+	if !decimalPattern.MatchString(text) {
+		return nil, fmt.Errorf("El importe %q no es un número decimal válido", text)
+	}
+
+	value, ok := new(big.Rat).SetString(text)
+	if !ok {
+		return nil, fmt.Errorf("El importe %q no es un número decimal válido", text)
+	}
+
+	return value.Mul(value, minorUnits), nil
+
+}
+
+// moneyFromMinorUnits crea el importe si las unidades menores entran en un int64
+func moneyFromMinorUnits(text string, amount *big.Int, currency string) (Money, error) {
+
+	if !amount.IsInt64() {
+		return Money{}, fmt.Errorf("El importe %s excede el máximo admitido", text)
+	}
+
+	return Money{Amount: amount.Int64(), Currency: currency}, nil
+
+}
+
+// ValidCurrency indica si el código es una moneda ISO 4217 de tres letras mayúsculas
+func ValidCurrency(currency string) bool {
+	return currencyPattern.MatchString(currency)
+}
+
+// IsZero indica si el importe es cero, sin importar la moneda
+func (money Money) IsZero() bool {
+	return money.Amount == 0
+}
+
+// IsNegative indica si el importe es menor que cero
+func (money Money) IsNegative() bool {
+	return money.Amount < 0
+}
+
+// Compare compara dos importes de la misma moneda. Los de monedas distintas se ordenan por
+// importe y luego por moneda, ya que no son comparables sin una cotización.
+func (money Money) Compare(other Money) int {
+
+	switch {
+	case money.Amount < other.Amount:
+		return -1
+	case money.Amount > other.Amount:
+		return 1
+	}
+
+	return strings.Compare(money.Currency, other.Currency)
+
+}
+
+// String devuelve el importe con sus decimales y sin la moneda, como "71.42"
+func (money Money) String() string {
+
+	amount := money.Amount
+	sign := ""
+	if amount < 0 {
+		sign = "-"
+	}
+
+	// El valor absoluto se calcula sin desbordar en el mínimo de int64
+	units := new(big.Int).Abs(big.NewInt(amount)).String()
+	if len(units) <= MoneyDecimals {
+		units = strings.Repeat("0", MoneyDecimals-len(units)+1) + units
+	}
+
+	return sign + units[:len(units)-MoneyDecimals] + "." + units[len(units)-MoneyDecimals:]
+
+}
+
+func (money Money) MarshalJSON() ([]byte, error) {
+	return []byte(money.String()), nil
+}
+
+// UnmarshalJSON acepta el importe como número o como texto y conserva la moneda del receptor
+func (money *Money) UnmarshalJSON(data []byte) error {
+
+	text, err := moneyText(data)
+	if err != nil || text == nil {
+		return err
+	}
+
+	parsed, err := ParseMoney(*text, money.Currency)
+	if err != nil {
+		return err
+	}
+
+	*money = parsed
+
+	return nil
+
+}
+
+func (money Money) MarshalText() ([]byte, error) {
+	return []byte(money.String()), nil
+}
+
+func (money *Money) UnmarshalText(text []byte) error {
+
+	parsed, err := ParseMoney(string(text), money.Currency)
+	if err != nil {
+		return err
+	}
+
+	*money = parsed
+
+	return nil
+
+}
+
+// moneyText devuelve el texto del importe en JSON, escrito como número o como texto; nil si
+// es null
+func moneyText(data []byte) (*string, error) {
+
+	data = bytes.TrimSpace(data)
+	if bytes.Equal(data, []byte("null")) {
+		return nil, nil
+	}
+
+	text := string(data)
+	if strings.HasPrefix(text, `"`) {
+		if err := json.Unmarshal(data, &text); err != nil {
+			return nil, err
+		}
+	}
+
+	return &text, nil
+
+}
+
+func (money *StoredMoney) UnmarshalJSON(data []byte) error {
+
+	text, err := moneyText(data)
+	if err != nil || text == nil {
+		return err
+	}
+
+	return money.UnmarshalText([]byte(*text))
+
+}
+
+func (money *StoredMoney) UnmarshalText(text []byte) error {
+
+	parsed, rounded, err := ParseMoneyHalfEven(string(text), money.Currency)
+	if err != nil {
+		return err
+	}
+
+	money.Money = parsed
+	money.Rounded = ""
+	if rounded {
+		money.Rounded = strings.TrimSpace(string(text))
+	}
+
+	return nil
+
+}
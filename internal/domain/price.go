@@ -0,0 +1,230 @@
+package domain
+
+import (
+	"slices"
+	"time"
+)
+
+// PricePoint es un precio del producto y el instante desde el que está vigente. Un
+// EffectiveFrom en cero indica un precio vigente desde siempre, como el de los productos
+// registrados antes de guardar la historia de precios.
+type PricePoint struct {
+	Price         Money
+	EffectiveFrom time.Time
+}
+
+// PricePointStorage guarda la moneda solo si difiere de la del producto
+type PricePointStorage struct {
+	Price         StoredMoney `json:"price"`
+	Currency      string      `json:"currency,omitempty"`
+	EffectiveFrom string      `json:"effective_from,omitempty"`
+}
+
+type PricePointResponse struct {
+	Price         Money   `json:"price"`
+	Currency      string  `json:"currency"`
+	EffectiveFrom *string `json:"effective_from"`
+	// Current indica si es el precio vigente y Scheduled si todavía no entró en vigencia
+	Current   bool `json:"current"`
+	Scheduled bool `json:"scheduled"`
+}
+
+// PriceRequest programa un precio; sin effective_from el precio entra en vigencia de inmediato
+type PriceRequest struct {
+	Price         *Money  `json:"price"`
+	EffectiveFrom *string `json:"effective_from"`
+}
+
+// ProductPricesResponse es la historia de precios de un producto
+type ProductPricesResponse struct {
+	ID      int                  `json:"id"`
+	Version int                  `json:"version"`
+	Prices  []PricePointResponse `json:"prices"`
+}
+
+// Validate verifica la solicitud y devuelve el precio en la moneda indicada con el instante
+// desde el que rige. La vigencia acepta un instante RFC 3339 o una fecha dd/mm/aaaa y no puede
+// ser anterior a now.
+func (priceRequest PriceRequest) Validate(now time.Time, currency string) (PricePoint, error) {
+
+	var validation ValidationError
+	point := PricePoint{EffectiveFrom: now}
+
+	switch {
+	case priceRequest.Price == nil || priceRequest.Price.IsZero():
+		validation.Add("price", "El precio del producto es un campo requerido")
+	case priceRequest.Price.IsNegative():
+		validation.Add("price", "El precio del producto no puede ser negativo")
+	default:
+		point.Price = *priceRequest.Price
+		point.Price.Currency = currency
+	}
+
+	if priceRequest.EffectiveFrom != nil {
+		effectiveFrom, err := time.Parse(time.RFC3339, *priceRequest.EffectiveFrom)
+		if err != nil {
+			effectiveFrom, err = time.Parse("02/01/2006", *priceRequest.EffectiveFrom)
+		}
+		switch {
+		case err != nil:
+			validation.Add("effective_from", "La fecha de vigencia no posee un formato válido")
+		case effectiveFrom.Before(now.Truncate(time.Second)):
+			validation.Add("effective_from", "La fecha de vigencia no puede ser anterior al momento actual")
+		default:
+			point.EffectiveFrom = effectiveFrom
+		}
+	}
+
+	return point, validation.Err()
+
+}
+
+// InheritPrices conserva en el producto la historia de precios de su versión anterior y, si
+// el precio cambió, lo registra como vigente desde now
+func (product *Product) InheritPrices(previous Product, now time.Time) {
+
+	price := product.Price
+	product.Prices = slices.Clone(previous.Prices)
+	product.Price = previous.Price
+
+	if price != previous.Price {
+		product.SchedulePrice(price, now, now)
+	}
+
+}
+
+// PriceAt devuelve el precio vigente en el instante indicado. Si el producto no tenía precio
+// en ese instante, el segundo valor es false.
+func (product Product) PriceAt(at time.Time) (Money, bool) {
+
+	for i := len(product.Prices) - 1; i >= 0; i-- {
+		if !product.Prices[i].EffectiveFrom.After(at) {
+			return product.Prices[i].Price, true
+		}
+	}
+
+	// Sin historia, el precio actual es el único conocido
+	if len(product.Prices) == 0 {
+		return product.Price, true
+	}
+
+	return Money{}, false
+}
+
+// SchedulePrice agrega a la historia el precio vigente desde effectiveFrom, reemplazando el
+// que hubiera para el mismo instante, y actualiza el precio actual al vigente en now
+func (product *Product) SchedulePrice(price Money, effectiveFrom time.Time, now time.Time) {
+
+	// La historia se guarda con precisión de segundos
+	effectiveFrom = effectiveFrom.Truncate(time.Second)
+
+	prices := slices.DeleteFunc(slices.Clone(product.Prices), func(point PricePoint) bool {
+		return point.EffectiveFrom.Equal(effectiveFrom)
+	})
+
+	index, _ := slices.BinarySearchFunc(prices, effectiveFrom, func(point PricePoint, target time.Time) int {
+		return point.EffectiveFrom.Compare(target)
+	})
+	product.Prices = slices.Insert(prices, index, PricePoint{Price: price, EffectiveFrom: effectiveFrom})
+
+	product.ApplyPrices(now)
+
+}
+
+// ApplyPrices actualiza el precio actual al vigente en now e indica si cambió
+func (product *Product) ApplyPrices(now time.Time) bool {
+
+	price, ok := product.PriceAt(now)
+	if !ok || price == product.Price {
+		return false
+	}
+
+	product.Price = price
+
+	return true
+}
+
+// PricePointResponsesFromProduct devuelve la historia de precios del producto, incluidos los
+// programados, indicando cuál está vigente en now
+func PricePointResponsesFromProduct(product Product, now time.Time) []PricePointResponse {
+
+	responses := make([]PricePointResponse, len(product.Prices))
+	current := -1
+
+	for i, point := range product.Prices {
+		responses[i] = PricePointResponse{
+			Price:     point.Price,
+			Currency:  point.Price.Currency,
+			Scheduled: point.EffectiveFrom.After(now),
+		}
+		if !point.EffectiveFrom.IsZero() {
+			timeStr := point.EffectiveFrom.Format(time.RFC3339)
+			responses[i].EffectiveFrom = &timeStr
+		}
+		if !responses[i].Scheduled {
+			current = i
+		}
+	}
+
+	if current >= 0 {
+		responses[current].Current = true
+	}
+
+	return responses
+
+}
+
+func pricePointsFromStorage(pricesStorage []PricePointStorage, price Money) []PricePoint {
+
+	// Los productos registrados antes de guardar la historia tienen su precio desde siempre
+	if len(pricesStorage) == 0 {
+		return []PricePoint{{Price: price}}
+	}
+
+	prices := make([]PricePoint, len(pricesStorage))
+	for i, point := range pricesStorage {
+		prices[i].Price = point.Price.Money
+		prices[i].Price.Currency = point.Currency
+		if point.Currency == "" {
+			prices[i].Price.Currency = price.Currency
+		}
+		if point.EffectiveFrom != "" {
+			prices[i].EffectiveFrom, _ = time.Parse(time.RFC3339, point.EffectiveFrom)
+		}
+	}
+
+	slices.SortStableFunc(prices, func(a, b PricePoint) int {
+		return a.EffectiveFrom.Compare(b.EffectiveFrom)
+	})
+
+	return prices
+
+}
+
+func pricePointsToStorage(prices []PricePoint, currency string) []PricePointStorage {
+
+	pricesStorage := make([]PricePointStorage, len(prices))
+	for i, point := range prices {
+		pricesStorage[i].Price = StoredMoney{Money: point.Price}
+		if point.Price.Currency != currency {
+			pricesStorage[i].Currency = point.Price.Currency
+		}
+		if !point.EffectiveFrom.IsZero() {
+			pricesStorage[i].EffectiveFrom = point.EffectiveFrom.Format(time.RFC3339)
+		}
+	}
+
+	return pricesStorage
+
+}
+
+// ProductPricesResponseFromProduct devuelve la historia de precios del producto
+func ProductPricesResponseFromProduct(product Product, now time.Time) ProductPricesResponse {
+
+	return ProductPricesResponse{
+		ID:      product.ID,
+		Version: product.Version,
+		Prices:  PricePointResponsesFromProduct(product, now),
+	}
+
+}
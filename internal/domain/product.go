@@ -1,70 +1,222 @@
 package domain
 
 import (
+	"encoding/json"
 	"errors"
-	"fmt"
+	"maps"
+	"slices"
 	"time"
 )
 
+// Product es un producto del catálogo. Version se incrementa en cada modificación y se usa
+// para el control de concurrencia optimista. DeletedAt indica cuándo se envió el producto a
+// la papelera; los productos en la papelera no aparecen en las lecturas habituales. Prices es
+// la historia de precios ordenada por vigencia, incluidos los programados; Price es el vigente
+// y su moneda es la del producto. NativePrices son los precios cargados en otras monedas, por
+// moneda, que se informan en lugar de convertir el precio principal. CategoryIDs son las
+// categorías del producto, ordenadas y sin repetir. Quantity es la existencia total según el
+// libro de stock y Stock la parte asignada a cada depósito. Lots son los lotes con
+// existencias, en el orden en que se consumen; Expiration vence la existencia sin lote.
+// Reserved es la parte de la existencia retenida por reservas activas; el servicio la recalcula
+// al informar el producto, porque la almacenada incluye las vencidas hasta que se cierran.
 type Product struct {
-	ID          int        `json:"id"`
-	Name        string     `json:"name"`
-	Quantity    int        `json:"quantity"`
-	CodeValue   string     `json:"code_value"`
-	Expiration  *time.Time `json:"expiration_date,omitempty"`
-	IsPublished bool       `json:"is_published,omitempty"`
-	Price       float64    `json:"price"`
+	ID           int              `json:"id"`
+	Name         string           `json:"name"`
+	Quantity     int              `json:"quantity"`
+	CodeValue    string           `json:"code_value"`
+	Expiration   *time.Time       `json:"expiration_date,omitempty"`
+	IsPublished  bool             `json:"is_published,omitempty"`
+	Price        Money            `json:"price"`
+	Version      int              `json:"version"`
+	DeletedAt    *time.Time       `json:"deleted_at,omitempty"`
+	Prices       []PricePoint     `json:"prices,omitempty"`
+	NativePrices map[string]Money `json:"native_prices,omitempty"`
+	CategoryIDs  []int            `json:"category_ids,omitempty"`
+	Stock        map[int]int      `json:"stock,omitempty"`
+	Lots         []Lot            `json:"lots,omitempty"`
+	Reserved     int              `json:"reserved,omitempty"`
 }
 
 type ProductStorage struct {
-	ID          int     `json:"id"`
-	Name        string  `json:"name"`
-	Quantity    int     `json:"quantity"`
-	CodeValue   string  `json:"code_value"`
-	Expiration  string  `json:"expiration_date"`
-	IsPublished bool    `json:"is_published,omitempty"`
-	Price       float64 `json:"price"`
+	ID          int         `json:"id"`
+	Name        string      `json:"name"`
+	Quantity    int         `json:"quantity"`
+	CodeValue   string      `json:"code_value"`
+	Expiration  string      `json:"expiration_date"`
+	IsPublished bool        `json:"is_published,omitempty"`
+	Price       StoredMoney `json:"price"`
+	// Currency vacío indica un producto almacenado antes de registrar la moneda
+	Currency     string              `json:"currency"`
+	Version      int                 `json:"version"`
+	DeletedAt    string              `json:"deleted_at,omitempty"`
+	Prices       []PricePointStorage `json:"prices,omitempty"`
+	NativePrices map[string]Money    `json:"native_prices,omitempty"`
+	CategoryIDs  []int               `json:"category_ids,omitempty"`
+	Stock        map[int]int         `json:"stock,omitempty"`
+	Lots         []LotStorage        `json:"lots,omitempty"`
+	Reserved     int                 `json:"reserved,omitempty"`
 }
 
 type ProductResponse struct {
 	ID          int     `json:"id"`
 	Name        string  `json:"name"`
 	Quantity    int     `json:"quantity"`
+	Reserved    int     `json:"reserved"`
+	Available   int     `json:"available"`
 	CodeValue   string  `json:"code_value"`
 	Expiration  *string `json:"expiration_date,omitempty"`
 	IsPublished bool    `json:"is_published,omitempty"`
-	Price       float64 `json:"price"`
+	Price       Money   `json:"price"`
+	Currency    string  `json:"currency"`
+	// PriceOrigin indica si el precio informado es nativo o convertido a la moneda pedida
+	PriceOrigin  string           `json:"price_origin"`
+	NativePrices map[string]Money `json:"native_prices,omitempty"`
+	CategoryIDs  []int            `json:"category_ids"`
+	// Stock son las existencias por depósito, con el ID del depósito como clave
+	Stock map[int]int `json:"stock,omitempty"`
+	// Lots son los lotes con existencias; Expiration es el vencimiento del primero
+	Lots      []LotResponse `json:"lots,omitempty"`
+	Version   int           `json:"version"`
+	DeletedAt *string       `json:"deleted_at,omitempty"`
+}
+
+// ScoredProduct es un producto encontrado por una búsqueda de texto con su relevancia
+type ScoredProduct struct {
+	Product Product
+	Score   float64
+}
+
+// ProductSearchResponse es un producto encontrado por una búsqueda de texto con su relevancia
+type ProductSearchResponse struct {
+	ProductResponse
+	Score float64 `json:"score"`
 }
 
 type ProductRequest struct {
-	Name        *string  `json:"name"`
-	Quantity    *int     `json:"quantity"`
-	CodeValue   *string  `json:"code_value"`
-	Expiration  *string  `json:"expiration_date,omitempty"`
-	IsPublished *bool    `json:"is_published,omitempty"`
-	Price       *float64 `json:"price"`
+	Name        *string `json:"name"`
+	Quantity    *int    `json:"quantity"`
+	CodeValue   *string `json:"code_value"`
+	Expiration  *string `json:"expiration_date,omitempty"`
+	IsPublished *bool   `json:"is_published,omitempty"`
+	Price       *Money  `json:"price"`
+	// Currency es la moneda del precio; si no se indica es DefaultCurrency
+	Currency *string `json:"currency,omitempty"`
+	// NativePrices reemplaza los precios cargados en otras monedas
+	NativePrices map[string]Money `json:"native_prices"`
+	// CategoryIDs reemplaza las categorías del producto
+	CategoryIDs []int `json:"category_ids"`
+}
+
+// Clone devuelve una copia del producto que no comparte memoria con el original
+func (product Product) Clone() Product {
+
+	if product.Expiration != nil {
+		expiration := *product.Expiration
+		product.Expiration = &expiration
+	}
+
+	if product.DeletedAt != nil {
+		deletedAt := *product.DeletedAt
+		product.DeletedAt = &deletedAt
+	}
+
+	product.Prices = slices.Clone(product.Prices)
+	product.NativePrices = maps.Clone(product.NativePrices)
+	product.CategoryIDs = slices.Clone(product.CategoryIDs)
+	product.Stock = maps.Clone(product.Stock)
+	product.Lots = slices.Clone(product.Lots)
+
+	return product
+
+}
+
+// IsDeleted indica si el producto está en la papelera
+func (product Product) IsDeleted() bool {
+	return product.DeletedAt != nil
 }
 
 func ProductResponseFromProductBase(product Product) ProductResponse {
 
+	// Con lotes, el producto vence con el primero de ellos
 	var expiration *string
-	if product.Expiration != nil {
-		timeStr := product.Expiration.Format("02/01/2006")
+	if effectiveExpiration := product.EffectiveExpiration(); effectiveExpiration != nil {
+		timeStr := effectiveExpiration.Format("02/01/2006")
 		expiration = &timeStr
 	} else {
 		expiration = nil
 	}
 
+	var deletedAt *string
+	if product.DeletedAt != nil {
+		timeStr := product.DeletedAt.Format(time.RFC3339)
+		deletedAt = &timeStr
+	}
+
 	return ProductResponse{
-		ID:          product.ID,
-		Name:        product.Name,
-		Quantity:    product.Quantity,
-		CodeValue:   product.CodeValue,
-		Expiration:  expiration,
-		IsPublished: product.IsPublished,
-		Price:       product.Price,
+		ID:           product.ID,
+		Name:         product.Name,
+		Quantity:     product.Quantity,
+		Reserved:     product.Reserved,
+		Available:    product.Available(),
+		CodeValue:    product.CodeValue,
+		Expiration:   expiration,
+		IsPublished:  product.IsPublished,
+		Price:        product.Price,
+		Currency:     product.Price.Currency,
+		PriceOrigin:  PriceNative,
+		NativePrices: maps.Clone(product.NativePrices),
+		CategoryIDs:  append([]int{}, product.CategoryIDs...),
+		Stock:        maps.Clone(product.Stock),
+		Lots:         LotResponsesFromLots(product.Lots),
+		Version:      product.Version,
+		DeletedAt:    deletedAt,
+	}
+
+}
+
+// ProductResponseInCurrency devuelve el producto con el precio en la moneda indicada: el
+// precio nativo en esa moneda si existe o, si no, el precio principal convertido con la tabla
+// de cotizaciones. Sin moneda se informa el precio principal.
+func ProductResponseInCurrency(product Product, currency string, rates *ExchangeRates) (ProductResponse, error) {
+
+	response := ProductResponseFromProductBase(product)
+	if currency == "" {
+		return response, nil
+	}
+
+	price, converted, err := product.PriceIn(currency, rates)
+	if err != nil {
+		return ProductResponse{}, err
+	}
+
+	response.Price = price
+	response.Currency = currency
+	if converted {
+		response.PriceOrigin = PriceConverted
+	}
+
+	return response, nil
+
+}
+
+// PriceIn devuelve el precio del producto en la moneda indicada e informa si fue convertido
+func (product Product) PriceIn(currency string, rates *ExchangeRates) (Money, bool, error) {
+
+	if product.Price.Currency == currency {
+		return product.Price, false, nil
+	}
+
+	if price, exists := product.NativePrices[currency]; exists {
+		return price, false, nil
+	}
+
+	price, err := rates.Convert(product.Price, currency)
+	if err != nil {
+		return Money{}, false, err
 	}
 
+	return price, true, nil
+
 }
 
 func ProductResponsesFromProductsBase(products []Product) []ProductResponse {
@@ -75,6 +227,88 @@ func ProductResponsesFromProductsBase(products []Product) []ProductResponse {
 	return productsResponses
 }
 
+// ProductRequestFromProduct devuelve la solicitud con todos los campos modificables del
+// producto. Es el documento sobre el que se aplican los parches.
+func ProductRequestFromProduct(product Product) ProductRequest {
+
+	var expiration *string
+	if product.Expiration != nil {
+		timeStr := product.Expiration.Format("02/01/2006")
+		expiration = &timeStr
+	}
+
+	productRequest := ProductRequest{
+		Name:        &product.Name,
+		Quantity:    &product.Quantity,
+		CodeValue:   &product.CodeValue,
+		Expiration:  expiration,
+		IsPublished: &product.IsPublished,
+		Price:       &product.Price,
+		Currency:    &product.Price.Currency,
+		// Un mapa vacío permite agregar monedas con JSON Patch
+		NativePrices: make(map[string]Money, len(product.NativePrices)),
+		CategoryIDs:  append([]int{}, product.CategoryIDs...),
+	}
+	maps.Copy(productRequest.NativePrices, product.NativePrices)
+
+	return productRequest
+
+}
+
+// ProductRequestFromDocument interpreta un documento JSON con los campos de un producto,
+// como el resultado de aplicar un parche. Los campos desconocidos o de un tipo inválido
+// se informan como errores de validación.
+func ProductRequestFromDocument(document []byte) (ProductRequest, error) {
+
+	var productRequest ProductRequest
+	var validation ValidationError
+
+	var members map[string]json.RawMessage
+	if err := json.Unmarshal(document, &members); err != nil || members == nil {
+		validation.Add("", "El producto debe ser un objeto JSON")
+		return ProductRequest{}, validation.Err()
+	}
+
+	fields := map[string]any{
+		"name":            &productRequest.Name,
+		"quantity":        &productRequest.Quantity,
+		"code_value":      &productRequest.CodeValue,
+		"expiration_date": &productRequest.Expiration,
+		"is_published":    &productRequest.IsPublished,
+		"price":           &productRequest.Price,
+		"currency":        &productRequest.Currency,
+		"native_prices":   &productRequest.NativePrices,
+		"category_ids":    &productRequest.CategoryIDs,
+	}
+
+	// Recorrer los campos en orden para que los errores sean siempre los mismos
+	names := make([]string, 0, len(members))
+	for name := range members {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	for _, name := range names {
+		field, exists := fields[name]
+		if !exists {
+			validation.Add(name, "El campo no existe o no puede modificarse")
+			continue
+		}
+		if err := json.Unmarshal(members[name], field); err != nil {
+			// Los importes informan el motivo por el que no son válidos
+			var typeError *json.UnmarshalTypeError
+			if (name == "price" || name == "native_prices") && !errors.As(err, &typeError) {
+				validation.Add(name, err.Error())
+				continue
+			}
+			validation.Add(name, "El valor del campo no posee un tipo válido")
+		}
+	}
+
+	return productRequest, validation.Err()
+
+}
+
 func ProductFromProductRequest(productRequest ProductRequest) (Product, error) {
 
 	if productRequest.Name == nil {
@@ -96,7 +330,9 @@ func ProductFromProductRequest(productRequest ProductRequest) (Product, error) {
 	if productRequest.Expiration != nil {
 		timeStr, err := time.Parse("02/01/2006", *productRequest.Expiration)
 		if err != nil {
-			return Product{}, fmt.Errorf("Error al parsear la fecha de expiración: %s", err.Error())
+			var validation ValidationError
+			validation.Add("expiration_date", "La fecha de expiración no posee un formato válido")
+			return Product{}, validation.Err()
 		}
 		expiration = &timeStr
 	} else {
@@ -109,18 +345,25 @@ func ProductFromProductRequest(productRequest ProductRequest) (Product, error) {
 	}
 
 	if productRequest.Price == nil {
-		defaultPrice := 0.0
-		productRequest.Price = &defaultPrice
+		productRequest.Price = &Money{}
+	}
+
+	price := *productRequest.Price
+	price.Currency = DefaultCurrency
+	if productRequest.Currency != nil {
+		price.Currency = *productRequest.Currency
 	}
 
 	product := Product{
-		ID:          0,
-		Name:        *productRequest.Name,
-		Quantity:    *productRequest.Quantity,
-		CodeValue:   *productRequest.CodeValue,
-		Expiration:  expiration,
-		IsPublished: *productRequest.IsPublished,
-		Price:       *productRequest.Price,
+		ID:           0,
+		Name:         *productRequest.Name,
+		Quantity:     *productRequest.Quantity,
+		CodeValue:    *productRequest.CodeValue,
+		Expiration:   expiration,
+		IsPublished:  *productRequest.IsPublished,
+		Price:        price,
+		NativePrices: nativePricesFromMap(productRequest.NativePrices),
+		CategoryIDs:  categoryIDsFromSlice(productRequest.CategoryIDs),
 	}
 
 	return product, nil
@@ -129,81 +372,295 @@ func ProductFromProductRequest(productRequest ProductRequest) (Product, error) {
 
 func (product *Product) ValidateProduct() error {
 
+	var validation ValidationError
+
+	if product.Name == "" {
+		validation.Add("name", "El nombre del producto es un campo requerido")
+	}
+
+	if product.CodeValue == "" {
+		validation.Add("code_value", "El codigo del producto es un campo requerido")
+	}
+
 	switch {
-	case product.Name == "":
-		return errors.New("El nombre del producto es un campo requerido")
-	case product.CodeValue == "":
-		return errors.New("El codigo del producto es un campo requerido")
-	case product.Price == 0:
-		return errors.New("El precio del producto es un campo requerido")
-	case product.Quantity == 0:
-		return errors.New("La stock del producto es un campo requerido")
+	case product.Price.IsZero():
+		validation.Add("price", "El precio del producto es un campo requerido")
+	case product.Price.IsNegative():
+		validation.Add("price", "El precio del producto no puede ser negativo")
+	}
+
+	if !ValidCurrency(product.Price.Currency) {
+		validation.Add("currency", "La moneda del producto debe ser un código ISO 4217 de tres letras mayúsculas")
 	}
 
-	return nil
+	if slices.ContainsFunc(product.CategoryIDs, func(id int) bool { return id <= 0 }) {
+		validation.Add("category_ids", "Las categorías del producto deben ser IDs válidos")
+	}
+
+	for _, currency := range slices.Sorted(maps.Keys(product.NativePrices)) {
+		field := "native_prices." + currency
+		switch price := product.NativePrices[currency]; {
+		case !ValidCurrency(currency):
+			validation.Add(field, "La moneda debe ser un código ISO 4217 de tres letras mayúsculas")
+		case currency == product.Price.Currency:
+			validation.Add(field, "La moneda es la del precio principal del producto")
+		case price.IsZero():
+			validation.Add(field, "El precio del producto es un campo requerido")
+		case price.IsNegative():
+			validation.Add(field, "El precio del producto no puede ser negativo")
+		}
+	}
+
+	if product.Quantity < 0 {
+		validation.Add("quantity", "El stock del producto no puede ser negativo")
+	}
+
+	return validation.Err()
 
 }
 
-func ProductsFromProductsStorage(productsStorage []ProductStorage) []Product {
+// ValidateNewProduct verifica un producto a dar de alta, que además debe tener existencia
+// inicial. Después la cantidad surge del libro de stock y un producto agotado queda en cero.
+func (product *Product) ValidateNewProduct() error {
 
-	var products []Product
+	var validation ValidationError
+	if err := validation.Merge(product.ValidateProduct()); err != nil {
+		return err
+	}
 
-	for _, productStorage := range productsStorage {
+	if product.Quantity == 0 {
+		validation.Add("quantity", "La stock del producto es un campo requerido")
+	}
 
-		var expiration *time.Time
-		if productStorage.Expiration != "" {
-			timeStr, _ := time.Parse("02/01/2006", productStorage.Expiration)
-			expiration = &timeStr
-		} else {
-			expiration = nil
+	return validation.Err()
+
+}
+
+// ValidateFull verifica que la solicitud contenga todos los campos de un producto
+// (como en un alta o un reemplazo completo) y que la fecha de expiración sea válida
+func (productRequest ProductRequest) ValidateFull() error {
+
+	var validation ValidationError
+
+	if productRequest.Name == nil {
+		validation.Add("name", "El nombre del producto es un campo requerido")
+	}
+
+	if productRequest.Quantity == nil {
+		validation.Add("quantity", "El stock del producto es un campo requerido")
+	}
+
+	if productRequest.CodeValue == nil {
+		validation.Add("code_value", "El codigo del producto es un campo requerido")
+	}
+
+	if productRequest.Expiration != nil {
+		if _, err := time.Parse("02/01/2006", *productRequest.Expiration); err != nil {
+			validation.Add("expiration_date", "La fecha de expiración no posee un formato válido")
 		}
+	}
+
+	if productRequest.IsPublished == nil {
+		validation.Add("is_published", "El estado de publicación del producto es un campo requerido")
+	}
+
+	if productRequest.Price == nil {
+		validation.Add("price", "El precio del producto es un campo requerido")
+	}
+
+	// Validar también el valor de los campos presentes, sin repetir los ya informados
+	valuesRequest := productRequest
+	if validation.Has("expiration_date") {
+		valuesRequest.Expiration = nil
+	}
+	if product, err := ProductFromProductRequest(valuesRequest); err == nil {
+		validation.MergeNew(product.ValidateProduct())
+	}
+
+	return validation.Err()
+
+}
+
+// ValidateNew verifica la solicitud de alta de un producto: contiene todos los campos y la
+// existencia inicial no es cero
+func (productRequest ProductRequest) ValidateNew() error {
+
+	var validation ValidationError
+	if err := validation.Merge(productRequest.ValidateFull()); err != nil {
+		return err
+	}
+
+	if productRequest.Quantity != nil && *productRequest.Quantity == 0 {
+		validation.Add("quantity", "La stock del producto es un campo requerido")
+	}
+
+	return validation.Err()
+
+}
+
+func ProductFromProductStorage(productStorage ProductStorage) Product {
+
+	var expiration *time.Time
+	if productStorage.Expiration != "" {
+		timeStr, _ := time.Parse("02/01/2006", productStorage.Expiration)
+		expiration = &timeStr
+	} else {
+		expiration = nil
+	}
+
+	var deletedAt *time.Time
+	if productStorage.DeletedAt != "" {
+		timeValue, _ := time.Parse(time.RFC3339, productStorage.DeletedAt)
+		deletedAt = &timeValue
+	}
+
+	// Los productos almacenados antes de registrar la moneda tienen precios en DefaultCurrency
+	price := productStorage.Price.Money
+	price.Currency = productStorage.Currency
+	if price.Currency == "" {
+		price.Currency = DefaultCurrency
+	}
+
+	return Product{
+		ID:          productStorage.ID,
+		Name:        productStorage.Name,
+		Quantity:    productStorage.Quantity,
+		CodeValue:   productStorage.CodeValue,
+		Expiration:  expiration,
+		IsPublished: productStorage.IsPublished,
+		Price:       price,
+		// Los productos almacenados antes de versionar comienzan en la versión 1
+		Version:      max(productStorage.Version, 1),
+		DeletedAt:    deletedAt,
+		Prices:       pricePointsFromStorage(productStorage.Prices, price),
+		NativePrices: nativePricesFromMap(productStorage.NativePrices),
+		CategoryIDs:  categoryIDsFromSlice(productStorage.CategoryIDs),
+		Stock:        stockFromMap(productStorage.Stock),
+		Lots:         lotsFromStorage(productStorage.Lots),
+		Reserved:     productStorage.Reserved,
+	}
+
+}
+
+// IsLegacy indica si el producto se almacenó antes de registrar la moneda de su precio
+func (productStorage ProductStorage) IsLegacy() bool {
+	return productStorage.Currency == ""
+}
 
-		product := Product{
-			ID:          productStorage.ID,
-			Name:        productStorage.Name,
-			Quantity:    productStorage.Quantity,
-			CodeValue:   productStorage.CodeValue,
-			Expiration:  expiration,
-			IsPublished: productStorage.IsPublished,
-			Price:       productStorage.Price,
+// RoundedPrices describe los precios del producto que se redondearon al leerlos, como
+// "12.345 a 12.34". Solo los productos anteriores a los importes exactos pueden tenerlos.
+func (productStorage ProductStorage) RoundedPrices() []string {
+
+	var rounded []string
+
+	if productStorage.Price.Rounded != "" {
+		rounded = append(rounded, productStorage.Price.Rounded+" a "+productStorage.Price.String())
+	}
+
+	for _, point := range productStorage.Prices {
+		if point.Price.Rounded != "" {
+			rounded = append(rounded, point.Price.Rounded+" a "+point.Price.String())
 		}
+	}
+
+	return rounded
 
-		products = append(products, product)
+}
+
+func ProductsFromProductsStorage(productsStorage []ProductStorage) []Product {
 
+	var products []Product
+
+	for _, productStorage := range productsStorage {
+		products = append(products, ProductFromProductStorage(productStorage))
 	}
 
 	return products
 
 }
 
+func ProductStorageFromProduct(product Product) ProductStorage {
+
+	var expiration string
+	if product.Expiration != nil {
+		timeStr := product.Expiration.Format("02/01/2006")
+		expiration = timeStr
+	} else {
+		expiration = ""
+	}
+
+	var deletedAt string
+	if product.DeletedAt != nil {
+		deletedAt = product.DeletedAt.Format(time.RFC3339)
+	}
+
+	return ProductStorage{
+		ID:           product.ID,
+		Name:         product.Name,
+		Quantity:     product.Quantity,
+		CodeValue:    product.CodeValue,
+		Expiration:   expiration,
+		IsPublished:  product.IsPublished,
+		Price:        StoredMoney{Money: product.Price},
+		Currency:     product.Price.Currency,
+		Version:      product.Version,
+		DeletedAt:    deletedAt,
+		Prices:       pricePointsToStorage(product.Prices, product.Price.Currency),
+		NativePrices: maps.Clone(product.NativePrices),
+		CategoryIDs:  slices.Clone(product.CategoryIDs),
+		Stock:        maps.Clone(product.Stock),
+		Lots:         lotsToStorage(product.Lots),
+		Reserved:     product.Reserved,
+	}
+
+}
+
 func ProductsStorageFromProducts(products []Product) []ProductStorage {
 
 	var productsStorage []ProductStorage
 
 	for _, product := range products {
+		productsStorage = append(productsStorage, ProductStorageFromProduct(product))
+	}
 
-		var expiration string
-		if product.Expiration != nil {
-			timeStr := product.Expiration.Format("02/01/2006")
-			expiration = timeStr
-		} else {
-			expiration = ""
-		}
+	return productsStorage
 
-		productStorage := ProductStorage{
-			ID:          product.ID,
-			Name:        product.Name,
-			Quantity:    product.Quantity,
-			CodeValue:   product.CodeValue,
-			Expiration:  expiration,
-			IsPublished: product.IsPublished,
-			Price:       product.Price,
-		}
+}
 
-		productsStorage = append(productsStorage, productStorage)
+// nativePricesFromMap asigna a cada precio la moneda de su clave. Un mapa vacío equivale a no
+// tener precios en otras monedas.
+func nativePricesFromMap(prices map[string]Money) map[string]Money {
 
+	if len(prices) == 0 {
+		return nil
 	}
 
-	return productsStorage
+	nativePrices := make(map[string]Money, len(prices))
+	for currency, price := range prices {
+		price.Currency = currency
+		nativePrices[currency] = price
+	}
+
+	return nativePrices
+
+}
+
+// categoryIDsFromSlice ordena las categorías y descarta las repetidas
+func categoryIDsFromSlice(ids []int) []int {
+
+	if len(ids) == 0 {
+		return nil
+	}
+
+	return slices.Compact(slices.Sorted(slices.Values(ids)))
+
+}
+
+// InCategory indica si el producto pertenece a alguna de las categorías indicadas
+func (product Product) InCategory(ids []int) bool {
+
+	return slices.ContainsFunc(product.CategoryIDs, func(id int) bool {
+		return slices.Contains(ids, id)
+	})
 
 }
@@ -0,0 +1,105 @@
+package domain
+
+import (
+	"PRACTICAS-GO-WEB/pkg/utils"
+	"strings"
+	"time"
+)
+
+// ProductFilter es una especificación de búsqueda de productos. Los criterios nulos no se
+// aplican y los definidos se combinan con AND.
+type ProductFilter struct {
+	// Text es una búsqueda de texto completo sobre el nombre; la resuelve el índice del
+	// repositorio y no se evalúa en Matches
+	Text *string
+	// PriceGte y PriceLte son inclusivos y se comparan de forma exacta en su moneda, con el
+	// precio nativo del producto en esa moneda o, si no lo tiene, con su precio convertido
+	PriceGte *Money
+	PriceLte *Money
+	// Rates es la tabla con la que se convierten los precios; la completa el servicio. Sin
+	// tabla, los productos sin precio nativo en la moneda del filtro no coinciden.
+	Rates *ExchangeRates
+	// Name busca el texto en cualquier parte del nombre, sin distinguir mayúsculas ni acentos
+	Name *string
+	// NamePrefix busca nombres que comiencen con el texto, sin distinguir mayúsculas ni acentos
+	NamePrefix  *string
+	IsPublished *bool
+	// ExpirationBefore y ExpirationAfter son inclusivos y se comparan con el vencimiento del
+	// primer lote con existencias, si lo hay; los productos sin fecha no coinciden
+	ExpirationBefore *time.Time
+	ExpirationAfter  *time.Time
+	QuantityGte      *int
+	QuantityLte      *int
+	CodeValuePrefix  *string
+	// CategoryIDs busca los productos que pertenecen a alguna de las categorías
+	CategoryIDs []int
+	// WarehouseID busca los productos con existencias en el depósito
+	WarehouseID *int
+}
+
+// Matches indica si el producto cumple todos los criterios del filtro
+func (filter ProductFilter) Matches(product Product) bool {
+
+	if filter.PriceGte != nil {
+		price, _, err := product.PriceIn(filter.PriceGte.Currency, filter.Rates)
+		if err != nil || price.Amount < filter.PriceGte.Amount {
+			return false
+		}
+	}
+
+	if filter.PriceLte != nil {
+		price, _, err := product.PriceIn(filter.PriceLte.Currency, filter.Rates)
+		if err != nil || price.Amount > filter.PriceLte.Amount {
+			return false
+		}
+	}
+
+	if filter.Name != nil || filter.NamePrefix != nil {
+		name := utils.FoldString(product.Name)
+		if filter.Name != nil && !strings.Contains(name, utils.FoldString(*filter.Name)) {
+			return false
+		}
+		if filter.NamePrefix != nil && !strings.HasPrefix(name, utils.FoldString(*filter.NamePrefix)) {
+			return false
+		}
+	}
+
+	if filter.IsPublished != nil && product.IsPublished != *filter.IsPublished {
+		return false
+	}
+
+	if filter.ExpirationBefore != nil || filter.ExpirationAfter != nil {
+		expiration := product.EffectiveExpiration()
+		if expiration == nil {
+			return false
+		}
+		if filter.ExpirationBefore != nil && expiration.After(*filter.ExpirationBefore) {
+			return false
+		}
+		if filter.ExpirationAfter != nil && expiration.Before(*filter.ExpirationAfter) {
+			return false
+		}
+	}
+
+	if filter.QuantityGte != nil && product.Quantity < *filter.QuantityGte {
+		return false
+	}
+
+	if filter.QuantityLte != nil && product.Quantity > *filter.QuantityLte {
+		return false
+	}
+
+	if filter.CategoryIDs != nil && !product.InCategory(filter.CategoryIDs) {
+		return false
+	}
+
+	if filter.WarehouseID != nil && product.Stock[*filter.WarehouseID] == 0 {
+		return false
+	}
+
+	if filter.CodeValuePrefix != nil && !strings.HasPrefix(strings.ToUpper(product.CodeValue), strings.ToUpper(*filter.CodeValuePrefix)) {
+		return false
+	}
+
+	return true
+}
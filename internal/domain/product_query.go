@@ -0,0 +1,67 @@
+package domain
+
+import (
+	"PRACTICAS-GO-WEB/internal/query"
+	"cmp"
+	"slices"
+	"strings"
+)
+
+// ProductFields son los nombres de los campos de un producto que se pueden ordenar y seleccionar
+var ProductFields = []string{"id", "name", "quantity", "code_value", "expiration_date", "is_published", "price", "currency", "version"}
+
+// ProductSelectableFields son los campos que se pueden seleccionar en los listados, incluidos
+// los que no se pueden ordenar
+var ProductSelectableFields = append(slices.Clone(ProductFields), "price_origin", "native_prices", "category_ids", "stock", "lots", "reserved", "available")
+
+// ProductTrashFields son los campos que se pueden ordenar y seleccionar en la papelera
+var ProductTrashFields = append(slices.Clone(ProductFields), "deleted_at")
+
+// ProductComparators compara productos por cada uno de los campos ordenables
+var ProductComparators = map[string]query.Comparator[Product]{
+	"id":         func(a, b Product) int { return cmp.Compare(a.ID, b.ID) },
+	"name":       func(a, b Product) int { return strings.Compare(strings.ToLower(a.Name), strings.ToLower(b.Name)) },
+	"quantity":   func(a, b Product) int { return cmp.Compare(a.Quantity, b.Quantity) },
+	"code_value": func(a, b Product) int { return strings.Compare(a.CodeValue, b.CodeValue) },
+	"expiration_date": func(a, b Product) int {
+		// Los productos sin fecha de expiración quedan al final
+		aExpiration, bExpiration := a.EffectiveExpiration(), b.EffectiveExpiration()
+		switch {
+		case aExpiration == nil && bExpiration == nil:
+			return 0
+		case aExpiration == nil:
+			return 1
+		case bExpiration == nil:
+			return -1
+		}
+		return aExpiration.Compare(*bExpiration)
+	},
+	"is_published": func(a, b Product) int {
+		switch {
+		case a.IsPublished == b.IsPublished:
+			return 0
+		case a.IsPublished:
+			return 1
+		}
+		return -1
+	},
+	"price":    func(a, b Product) int { return a.Price.Compare(b.Price) },
+	"currency": func(a, b Product) int { return strings.Compare(a.Price.Currency, b.Price.Currency) },
+	"version":  func(a, b Product) int { return cmp.Compare(a.Version, b.Version) },
+	"deleted_at": func(a, b Product) int {
+		switch {
+		case a.DeletedAt == nil && b.DeletedAt == nil:
+			return 0
+		case a.DeletedAt == nil:
+			return 1
+		case b.DeletedAt == nil:
+			return -1
+		}
+		return a.DeletedAt.Compare(*b.DeletedAt)
+	},
+}
+
+// ProductID devuelve el ID del producto, para paginar por cursor
+func ProductID(product Product) int {
+	return product.ID
+}
@@ -0,0 +1,148 @@
+package domain
+
+import (
+	"strings"
+	"time"
+)
+
+// ReservationStatus es el estado de una reserva de stock
+type ReservationStatus string
+
+const (
+	// ReservationActive retiene la existencia hasta que la reserva se confirma, se libera o vence
+	ReservationActive ReservationStatus = "active"
+	// ReservationConfirmed es una reserva convertida en venta
+	ReservationConfirmed ReservationStatus = "confirmed"
+	// ReservationReleased es una reserva liberada antes de vencer
+	ReservationReleased ReservationStatus = "released"
+	// ReservationExpired es una reserva que venció sin confirmarse
+	ReservationExpired ReservationStatus = "expired"
+)
+
+// MaxReservationTTL es el plazo máximo de una reserva
+const MaxReservationTTL = 24 * time.Hour
+
+// Reservation retiene existencias de un producto durante un plazo, por ejemplo mientras se
+// completa una compra. La existencia reservada no está disponible para otras ventas ni
+// reservas, pero sigue siendo parte de la existencia física del producto. WarehouseID en cero
+// indica la existencia sin depósito asignado. MovementID es la venta registrada al confirmar.
+type Reservation struct {
+	ID          int               `json:"id"`
+	ProductID   int               `json:"product_id"`
+	Quantity    int               `json:"quantity"`
+	WarehouseID int               `json:"warehouse_id,omitempty"`
+	Status      ReservationStatus `json:"status"`
+	Reference   string            `json:"reference,omitempty"`
+	Actor       string            `json:"actor"`
+	CreatedAt   time.Time         `json:"created_at"`
+	ExpiresAt   time.Time         `json:"expires_at"`
+	ClosedAt    *time.Time        `json:"closed_at,omitempty"`
+	MovementID  int               `json:"movement_id,omitempty"`
+}
+
+// ReservationRequest reserva existencias de un producto. TTL es el plazo de la reserva, por
+// ejemplo 10m; si no se indica se usa el plazo por defecto del servidor.
+type ReservationRequest struct {
+	Quantity    *int    `json:"quantity"`
+	WarehouseID *int    `json:"warehouse_id"`
+	TTL         *string `json:"ttl"`
+	Reference   *string `json:"reference"`
+}
+
+// ReservationFilter son los criterios de búsqueda de las reservas; los nil no se aplican
+type ReservationFilter struct {
+	ProductID *int
+	Status    *ReservationStatus
+}
+
+// Validate verifica la solicitud y devuelve la reserva y su plazo, que es defaultTTL si no
+// se indica otro
+func (reservationRequest ReservationRequest) Validate(defaultTTL time.Duration) (Reservation, time.Duration, error) {
+
+	var validation ValidationError
+	var reservation Reservation
+
+	if reservationRequest.Quantity == nil || *reservationRequest.Quantity <= 0 {
+		validation.Add("quantity", "La cantidad a reservar debe ser mayor que cero")
+	} else {
+		reservation.Quantity = *reservationRequest.Quantity
+	}
+
+	if reservationRequest.WarehouseID != nil {
+		if *reservationRequest.WarehouseID <= 0 {
+			validation.Add("warehouse_id", "El depósito debe ser un ID válido")
+		} else {
+			reservation.WarehouseID = *reservationRequest.WarehouseID
+		}
+	}
+
+	ttl := defaultTTL
+	if reservationRequest.TTL != nil {
+		parsed, err := time.ParseDuration(*reservationRequest.TTL)
+		switch {
+		case err != nil:
+			validation.Add("ttl", "El plazo de la reserva debe ser una duración, por ejemplo 10m")
+		case parsed <= 0 || parsed > MaxReservationTTL:
+			validation.Add("ttl", "El plazo de la reserva debe ser positivo y de hasta 24h")
+		default:
+			ttl = parsed
+		}
+	}
+
+	if reservationRequest.Reference != nil {
+		reservation.Reference = strings.TrimSpace(*reservationRequest.Reference)
+	}
+
+	return reservation, ttl, validation.Err()
+
+}
+
+// IsHeld indica si la reserva retiene existencias en el instante now
+func (reservation Reservation) IsHeld(now time.Time) bool {
+	return reservation.Status == ReservationActive && now.Before(reservation.ExpiresAt)
+}
+
+// At devuelve la reserva como se ve en el instante now: una reserva activa cuyo plazo terminó
+// está vencida aunque todavía no se haya cerrado
+func (reservation Reservation) At(now time.Time) Reservation {
+
+	if reservation.Status == ReservationActive && !reservation.IsHeld(now) {
+		reservation.Status = ReservationExpired
+	}
+
+	return reservation
+
+}
+
+// Close cierra la reserva con el estado indicado en el instante now
+func (reservation *Reservation) Close(status ReservationStatus, now time.Time) {
+
+	reservation.Status = status
+	reservation.ClosedAt = &now
+
+}
+
+// Matches indica si la reserva cumple todos los criterios del filtro
+func (filter ReservationFilter) Matches(reservation Reservation) bool {
+
+	if filter.ProductID != nil && reservation.ProductID != *filter.ProductID {
+		return false
+	}
+
+	if filter.Status != nil && reservation.Status != *filter.Status {
+		return false
+	}
+
+	return true
+
+}
+
+// ReservationID devuelve el ID de la reserva, para paginar por cursor
+func ReservationID(reservation Reservation) int {
+	return reservation.ID
+}
+
+// Available es la existencia del producto que no está reservada
+func (product Product) Available() int {
+	return product.Quantity - product.Reserved
+}
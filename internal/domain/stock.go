@@ -0,0 +1,179 @@
+package domain
+
+import (
+	"maps"
+	"slices"
+)
+
+// StockLevelResponse es la existencia de un producto en un depósito
+type StockLevelResponse struct {
+	WarehouseID   int    `json:"warehouse_id"`
+	WarehouseName string `json:"warehouse_name"`
+	Quantity      int    `json:"quantity"`
+}
+
+// ProductStockResponse son las existencias de un producto por depósito y por lote y su total.
+// Unassigned es la existencia que no está asignada a ningún depósito.
+type ProductStockResponse struct {
+	ID         int                  `json:"id"`
+	Quantity   int                  `json:"quantity"`
+	Reserved   int                  `json:"reserved"`
+	Available  int                  `json:"available"`
+	Unassigned int                  `json:"unassigned"`
+	Version    int                  `json:"version"`
+	Stock      []StockLevelResponse `json:"stock"`
+	Lots       []LotResponse        `json:"lots,omitempty"`
+}
+
+// WarehouseStockResponse es la existencia de un producto en el depósito consultado
+type WarehouseStockResponse struct {
+	ProductID int    `json:"product_id"`
+	CodeValue string `json:"code_value"`
+	Name      string `json:"name"`
+	Quantity  int    `json:"quantity"`
+}
+
+// StockRequest fija la existencia de un producto en un depósito
+type StockRequest struct {
+	Quantity *int `json:"quantity"`
+}
+
+// TransferRequest mueve existencias de un producto entre dos depósitos
+type TransferRequest struct {
+	FromWarehouseID *int `json:"from_warehouse_id"`
+	ToWarehouseID   *int `json:"to_warehouse_id"`
+	Quantity        *int `json:"quantity"`
+}
+
+// Validate verifica la solicitud y devuelve la existencia pedida
+func (stockRequest StockRequest) Validate() (int, error) {
+
+	var validation ValidationError
+
+	switch {
+	case stockRequest.Quantity == nil:
+		validation.Add("quantity", "La cantidad es un campo requerido")
+	case *stockRequest.Quantity < 0:
+		validation.Add("quantity", "La cantidad no puede ser negativa")
+	default:
+		return *stockRequest.Quantity, nil
+	}
+
+	return 0, validation.Err()
+
+}
+
+// Validate verifica la solicitud de transferencia
+func (transferRequest TransferRequest) Validate() error {
+
+	var validation ValidationError
+
+	if transferRequest.FromWarehouseID == nil {
+		validation.Add("from_warehouse_id", "El depósito de origen es un campo requerido")
+	}
+
+	if transferRequest.ToWarehouseID == nil {
+		validation.Add("to_warehouse_id", "El depósito de destino es un campo requerido")
+	}
+
+	if transferRequest.FromWarehouseID != nil && transferRequest.ToWarehouseID != nil && *transferRequest.FromWarehouseID == *transferRequest.ToWarehouseID {
+		validation.Add("to_warehouse_id", "El depósito de destino debe ser distinto del de origen")
+	}
+
+	if transferRequest.Quantity == nil || *transferRequest.Quantity <= 0 {
+		validation.Add("quantity", "La cantidad a transferir debe ser mayor que cero")
+	}
+
+	return validation.Err()
+
+}
+
+// HasStock indica si el producto tiene existencias asignadas a algún depósito
+func (product Product) HasStock() bool {
+	return len(product.Stock) > 0
+}
+
+// InheritStock conserva en el producto la cantidad, las existencias por depósito, los lotes y
+// la existencia reservada de su versión anterior. La cantidad surge del libro de stock, por lo
+// que solo se modifica registrando movimientos.
+func (product *Product) InheritStock(previous Product) error {
+
+	if product.Quantity != previous.Quantity {
+		var validation ValidationError
+		validation.Add("quantity", "La cantidad del producto se modifica registrando movimientos de stock")
+		return validation.Err()
+	}
+
+	product.Stock = maps.Clone(previous.Stock)
+	product.Lots = slices.Clone(previous.Lots)
+	product.Reserved = previous.Reserved
+
+	return nil
+
+}
+
+// ProductStockResponseFromProduct devuelve las existencias del producto ordenadas por
+// depósito, con el nombre de cada depósito según names
+func ProductStockResponseFromProduct(product Product, names map[int]string) ProductStockResponse {
+
+	stock := make([]StockLevelResponse, 0, len(product.Stock))
+	for _, warehouseID := range slices.Sorted(maps.Keys(product.Stock)) {
+		stock = append(stock, StockLevelResponse{
+			WarehouseID:   warehouseID,
+			WarehouseName: names[warehouseID],
+			Quantity:      product.Stock[warehouseID],
+		})
+	}
+
+	return ProductStockResponse{
+		ID:         product.ID,
+		Quantity:   product.Quantity,
+		Reserved:   product.Reserved,
+		Available:  product.Available(),
+		Unassigned: product.Quantity - totalStock(product.Stock),
+		Version:    product.Version,
+		Stock:      stock,
+		Lots:       LotResponsesFromLots(product.Lots),
+	}
+
+}
+
+// WarehouseStockResponseFromProduct devuelve la existencia del producto en el depósito
+func WarehouseStockResponseFromProduct(product Product, warehouseID int) WarehouseStockResponse {
+
+	return WarehouseStockResponse{
+		ProductID: product.ID,
+		CodeValue: product.CodeValue,
+		Name:      product.Name,
+		Quantity:  product.Stock[warehouseID],
+	}
+
+}
+
+// stockFromMap descarta los depósitos sin existencias. Un mapa vacío equivale a no llevar
+// existencias por depósito.
+func stockFromMap(stock map[int]int) map[int]int {
+
+	stock = maps.Clone(stock)
+	maps.DeleteFunc(stock, func(_ int, quantity int) bool {
+		return quantity == 0
+	})
+
+	if len(stock) == 0 {
+		return nil
+	}
+
+	return stock
+
+}
+
+func totalStock(stock map[int]int) int {
+
+	total := 0
+	for _, quantity := range stock {
+		total += quantity
+	}
+
+	return total
+
+}
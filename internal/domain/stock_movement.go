@@ -0,0 +1,285 @@
+package domain
+
+import (
+	"maps"
+	"slices"
+	"strings"
+	"time"
+)
+
+// MovementType es el tipo de un movimiento de stock
+type MovementType string
+
+const (
+	// MovementPurchaseReceipt es el ingreso de mercadería comprada
+	MovementPurchaseReceipt MovementType = "purchase_receipt"
+	// MovementSale es el egreso de mercadería vendida
+	MovementSale MovementType = "sale"
+	// MovementAdjustment corrige la existencia en la cantidad indicada, positiva o negativa
+	MovementAdjustment MovementType = "adjustment"
+	// MovementReturn es el reingreso de mercadería devuelta por un cliente
+	MovementReturn MovementType = "return"
+	// MovementWriteOff es la baja de mercadería dañada, vencida o perdida
+	MovementWriteOff MovementType = "write_off"
+	// MovementTransfer mueve existencias entre dos depósitos; no cambia la existencia total
+	MovementTransfer MovementType = "transfer"
+)
+
+// OpeningBalanceReason es el motivo de los ajustes con los que el libro de movimientos
+// registra la existencia previa de un producto
+const OpeningBalanceReason = "Saldo inicial"
+
+// StockMovement es un movimiento del libro de stock de un producto. Quantity es la variación
+// de la existencia: positiva en los ingresos y negativa en los egresos; en una transferencia
+// es la cantidad que sale de WarehouseID y entra en ToWarehouseID. WarehouseID en cero indica
+// la existencia sin depósito asignado. Lots es la parte del movimiento que corresponde a cada
+// lote; la existencia que no se asigna a un lote es la existencia sin lote. Balance es la
+// existencia total del producto después del movimiento.
+type StockMovement struct {
+	ID            int           `json:"id"`
+	ProductID     int           `json:"product_id"`
+	Type          MovementType  `json:"type"`
+	Quantity      int           `json:"quantity"`
+	WarehouseID   int           `json:"warehouse_id,omitempty"`
+	ToWarehouseID int           `json:"to_warehouse_id,omitempty"`
+	Reason        string        `json:"reason,omitempty"`
+	Reference     string        `json:"reference,omitempty"`
+	Lots          []LotMovement `json:"lots,omitempty"`
+	Timestamp     time.Time     `json:"timestamp"`
+	Actor         string        `json:"actor"`
+	RequestID     string        `json:"request_id,omitempty"`
+	Balance       int           `json:"balance"`
+}
+
+// StockMovementRequest registra un movimiento. La cantidad es positiva salvo en los ajustes,
+// donde el signo indica si la existencia aumenta o disminuye. Un ingreso con LotCode ingresa
+// al lote, que se crea con el vencimiento y la fecha de recepción indicados si no existe. Un
+// egreso con LotCode sale de ese lote y, sin él, de los lotes que vencen primero.
+type StockMovementRequest struct {
+	Type         *string `json:"type"`
+	Quantity     *int    `json:"quantity"`
+	WarehouseID  *int    `json:"warehouse_id"`
+	Reason       *string `json:"reason"`
+	Reference    *string `json:"reference"`
+	LotCode      *string `json:"lot_code"`
+	Expiration   *string `json:"expiration_date"`
+	ReceivedDate *string `json:"received_date"`
+}
+
+// StockMovementFilter son los criterios de búsqueda del libro de stock; los nil no se aplican
+type StockMovementFilter struct {
+	ProductID   *int
+	Type        *MovementType
+	WarehouseID *int
+	// From y To limitan el instante del movimiento, ambos inclusive
+	From *time.Time
+	To   *time.Time
+}
+
+// movementSigns es el signo con el que cada tipo que se registra por la API afecta la existencia
+var movementSigns = map[MovementType]int{
+	MovementPurchaseReceipt: 1,
+	MovementSale:            -1,
+	MovementAdjustment:      1,
+	MovementReturn:          1,
+	MovementWriteOff:        -1,
+}
+
+// Validate verifica la solicitud y devuelve el movimiento con la cantidad con signo. Las
+// transferencias no se registran como movimientos sueltos sino con su propia operación.
+func (movementRequest StockMovementRequest) Validate() (StockMovement, error) {
+
+	var validation ValidationError
+	var movement StockMovement
+
+	sign := 0
+	if movementRequest.Type == nil || *movementRequest.Type == "" {
+		validation.Add("type", "El tipo de movimiento es un campo requerido")
+	} else if sign = movementSigns[MovementType(*movementRequest.Type)]; sign == 0 {
+		validation.Add("type", "El tipo de movimiento debe ser purchase_receipt, sale, adjustment, return o write_off")
+	} else {
+		movement.Type = MovementType(*movementRequest.Type)
+	}
+
+	switch {
+	case movementRequest.Quantity == nil || *movementRequest.Quantity == 0:
+		validation.Add("quantity", "La cantidad del movimiento es un campo requerido y no puede ser cero")
+	case *movementRequest.Quantity < 0 && movement.Type != MovementAdjustment:
+		validation.Add("quantity", "La cantidad del movimiento debe ser positiva; solo los ajustes admiten cantidades negativas")
+	default:
+		movement.Quantity = sign * *movementRequest.Quantity
+	}
+
+	if movementRequest.WarehouseID != nil {
+		if *movementRequest.WarehouseID <= 0 {
+			validation.Add("warehouse_id", "El depósito debe ser un ID válido")
+		} else {
+			movement.WarehouseID = *movementRequest.WarehouseID
+		}
+	}
+
+	if movementRequest.Reason != nil {
+		movement.Reason = strings.TrimSpace(*movementRequest.Reason)
+	}
+	if movement.Reason == "" && (movement.Type == MovementAdjustment || movement.Type == MovementWriteOff) {
+		validation.Add("reason", "El motivo es un campo requerido en los ajustes y las bajas")
+	}
+
+	if movementRequest.Reference != nil {
+		movement.Reference = strings.TrimSpace(*movementRequest.Reference)
+	}
+
+	validation.Merge(movementRequest.validateLot(&movement))
+
+	return movement, validation.Err()
+
+}
+
+// validateLot verifica los campos del lote y agrega al movimiento la parte que le corresponde.
+// El vencimiento y la fecha de recepción solo se indican al ingresar mercadería a un lote.
+func (movementRequest StockMovementRequest) validateLot(movement *StockMovement) error {
+
+	var validation ValidationError
+
+	var lotMovement LotMovement
+	if movementRequest.LotCode != nil {
+		lotMovement.Code = strings.TrimSpace(*movementRequest.LotCode)
+		if lotMovement.Code == "" {
+			validation.Add("lot_code", "El código del lote no puede estar vacío")
+		}
+	}
+
+	receipt := movementRequest.LotCode != nil && movement.Quantity >= 0
+	lotMovement.Expiration = parseLotDate(&validation, "expiration_date", movementRequest.Expiration, receipt)
+	lotMovement.ReceivedDate = parseLotDate(&validation, "received_date", movementRequest.ReceivedDate, receipt)
+
+	if lotMovement.Code != "" && movement.Quantity != 0 {
+		lotMovement.Quantity = movement.Quantity
+		movement.Lots = []LotMovement{lotMovement}
+	}
+
+	return validation.Err()
+
+}
+
+// parseLotDate interpreta una fecha del lote con formato dd/mm/aaaa, que solo se admite en el
+// ingreso de mercadería a un lote
+func parseLotDate(validation *ValidationError, field string, value *string, receipt bool) *time.Time {
+
+	if value == nil {
+		return nil
+	}
+
+	if !receipt {
+		validation.Add(field, "La fecha solo se indica al ingresar mercadería a un lote")
+		return nil
+	}
+
+	date, err := time.Parse("02/01/2006", *value)
+	if err != nil {
+		validation.Add(field, "La fecha no posee un formato válido")
+		return nil
+	}
+
+	return &date
+
+}
+
+// Apply aplica el movimiento sobre las existencias por depósito, con la existencia sin
+// depósito asignado en la clave cero
+func (movement StockMovement) Apply(balances map[int]int) {
+
+	if movement.Type == MovementTransfer {
+		balances[movement.WarehouseID] -= movement.Quantity
+		balances[movement.ToWarehouseID] += movement.Quantity
+		return
+	}
+
+	balances[movement.WarehouseID] += movement.Quantity
+
+}
+
+// Matches indica si el movimiento cumple todos los criterios del filtro
+func (filter StockMovementFilter) Matches(movement StockMovement) bool {
+
+	if filter.ProductID != nil && movement.ProductID != *filter.ProductID {
+		return false
+	}
+
+	if filter.Type != nil && movement.Type != *filter.Type {
+		return false
+	}
+
+	if filter.WarehouseID != nil && movement.WarehouseID != *filter.WarehouseID && movement.ToWarehouseID != *filter.WarehouseID {
+		return false
+	}
+
+	if filter.From != nil && movement.Timestamp.Before(*filter.From) {
+		return false
+	}
+
+	if filter.To != nil && movement.Timestamp.After(*filter.To) {
+		return false
+	}
+
+	return true
+
+}
+
+// StockMovementID devuelve el ID del movimiento, para paginar por cursor
+func StockMovementID(movement StockMovement) int {
+	return movement.ID
+}
+
+// SetBalances fija la cantidad y las existencias por depósito del producto según los saldos
+// del libro de stock, con la existencia sin depósito asignado en la clave cero
+func (product *Product) SetBalances(balances map[int]int) {
+
+	product.Quantity = totalStock(balances)
+
+	stock := maps.Clone(balances)
+	delete(stock, 0)
+	product.Stock = stockFromMap(stock)
+
+}
+
+// Balances devuelve la cantidad y las existencias por depósito del producto con el formato
+// de los saldos del libro de stock
+func (product Product) Balances() map[int]int {
+
+	balances := maps.Clone(product.Stock)
+	if balances == nil {
+		balances = make(map[int]int)
+	}
+
+	if unassigned := product.Quantity - totalStock(product.Stock); unassigned != 0 {
+		balances[0] = unassigned
+	}
+
+	return balances
+
+}
+
+// OpeningMovements devuelve los ajustes que registran en el libro de stock la existencia
+// previa del producto, uno por depósito
+func OpeningMovements(product Product) []StockMovement {
+
+	balances := product.Balances()
+
+	var movements []StockMovement
+	for _, warehouseID := range slices.Sorted(maps.Keys(balances)) {
+		if balances[warehouseID] == 0 {
+			continue
+		}
+		movements = append(movements, StockMovement{
+			ProductID:   product.ID,
+			Type:        MovementAdjustment,
+			Quantity:    balances[warehouseID],
+			WarehouseID: warehouseID,
+			Reason:      OpeningBalanceReason,
+		})
+	}
+
+	return movements
+
+}
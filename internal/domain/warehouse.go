@@ -0,0 +1,104 @@
+package domain
+
+import (
+	"PRACTICAS-GO-WEB/internal/query"
+	"cmp"
+	"strings"
+)
+
+// Warehouse es un depósito en el que se guardan existencias de los productos
+type Warehouse struct {
+	ID   int
+	Name string
+}
+
+type WarehouseStorage struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+type WarehouseResponse struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+type WarehouseRequest struct {
+	Name *string `json:"name"`
+}
+
+// WarehouseFields son los nombres de los campos de un depósito que se pueden ordenar y seleccionar
+var WarehouseFields = []string{"id", "name"}
+
+// WarehouseComparators compara depósitos por cada uno de los campos ordenables
+var WarehouseComparators = map[string]query.Comparator[Warehouse]{
+	"id":   func(a, b Warehouse) int { return cmp.Compare(a.ID, b.ID) },
+	"name": func(a, b Warehouse) int { return strings.Compare(strings.ToLower(a.Name), strings.ToLower(b.Name)) },
+}
+
+// WarehouseID devuelve el ID del depósito, para paginar por cursor
+func WarehouseID(warehouse Warehouse) int {
+	return warehouse.ID
+}
+
+// Validate verifica la solicitud de alta o reemplazo de un depósito
+func (warehouseRequest WarehouseRequest) Validate() error {
+
+	var validation ValidationError
+
+	if warehouseRequest.Name == nil || strings.TrimSpace(*warehouseRequest.Name) == "" {
+		validation.Add("name", "El nombre del depósito es un campo requerido")
+	}
+
+	return validation.Err()
+
+}
+
+func WarehouseFromWarehouseRequest(warehouseRequest WarehouseRequest) Warehouse {
+
+	var warehouse Warehouse
+	if warehouseRequest.Name != nil {
+		warehouse.Name = strings.TrimSpace(*warehouseRequest.Name)
+	}
+
+	return warehouse
+
+}
+
+func WarehouseResponseFromWarehouse(warehouse Warehouse) WarehouseResponse {
+
+	return WarehouseResponse{ID: warehouse.ID, Name: warehouse.Name}
+
+}
+
+func WarehouseResponsesFromWarehouses(warehouses []Warehouse) []WarehouseResponse {
+
+	responses := make([]WarehouseResponse, len(warehouses))
+	for i, warehouse := range warehouses {
+		responses[i] = WarehouseResponseFromWarehouse(warehouse)
+	}
+
+	return responses
+
+}
+
+func WarehousesFromWarehousesStorage(warehousesStorage []WarehouseStorage) []Warehouse {
+
+	var warehouses []Warehouse
+	for _, warehouseStorage := range warehousesStorage {
+		warehouses = append(warehouses, Warehouse{ID: warehouseStorage.ID, Name: warehouseStorage.Name})
+	}
+
+	return warehouses
+
+}
+
+func WarehousesStorageFromWarehouses(warehouses []Warehouse) []WarehouseStorage {
+
+	warehousesStorage := make([]WarehouseStorage, len(warehouses))
+	for i, warehouse := range warehouses {
+		warehousesStorage[i] = WarehouseStorage{ID: warehouse.ID, Name: warehouse.Name}
+	}
+
+	return warehousesStorage
+
+}
@@ -0,0 +1,141 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"PRACTICAS-GO-WEB/internal/domain"
+	"PRACTICAS-GO-WEB/internal/query"
+	"PRACTICAS-GO-WEB/internal/service"
+	"PRACTICAS-GO-WEB/pkg/web"
+)
+
+type auditHandler struct {
+	service service.AuditService
+}
+
+type AuditHandler interface {
+	HandlerGetProductHistory(w http.ResponseWriter, r *http.Request)
+	HandlerGetAudit(w http.ResponseWriter, r *http.Request)
+}
+
+// función para crear un nuevo controlador de la auditoría
+func NewAuditHandler(service service.AuditService) AuditHandler {
+
+	return &auditHandler{service: service}
+
+}
+
+func (ah *auditHandler) HandlerGetProductHistory(w http.ResponseWriter, r *http.Request) {
+
+	// Obtener el ID de los parámetros de la URL
+	id, err := validateHeaderID(w, r)
+	if err != nil {
+		return
+	}
+
+	// Obtener la paginación pedida; las entradas se devuelven en orden cronológico
+	q, err := query.Parse(r.URL.Query(), nil, nil)
+	if err != nil {
+		web.ErrorFromError(w, r, err)
+		return
+	}
+
+	page, err := ah.service.GetProductHistory(id, q)
+	if err != nil {
+		web.ErrorFromError(w, r, err)
+		return
+	}
+
+	writePage(w, r, q, page, "product history found")
+
+}
+
+func (ah *auditHandler) HandlerGetAudit(w http.ResponseWriter, r *http.Request) {
+
+	// Obtener los criterios de búsqueda de los parámetros de la URL
+	filter, err := parseAuditFilter(r.URL.Query())
+	if err != nil {
+		web.ErrorFromError(w, r, err)
+		return
+	}
+
+	// Obtener la paginación pedida; las entradas se devuelven en orden cronológico
+	q, err := query.Parse(r.URL.Query(), nil, nil)
+	if err != nil {
+		web.ErrorFromError(w, r, err)
+		return
+	}
+
+	page, err := ah.service.FindAuditEntries(filter, q)
+	if err != nil {
+		web.ErrorFromError(w, r, err)
+		return
+	}
+
+	writePage(w, r, q, page, "audit entries found")
+
+}
+
+// parseAuditFilter obtiene el filtro de la auditoría de los parámetros de la URL. from y to
+// aceptan un instante RFC 3339 o una fecha; una fecha en to incluye el día completo.
+func parseAuditFilter(values url.Values) (domain.AuditFilter, error) {
+
+	var filter domain.AuditFilter
+
+	filter.Actor = parseStringParam(values, "actor")
+
+	var err error
+	filter.From, filter.To, err = parseInstantRange(values)
+
+	return filter, err
+
+}
+
+// parseInstantRange obtiene el intervalo de los parámetros from y to de la URL. Ambos aceptan
+// un instante RFC 3339 o una fecha; una fecha en to incluye el día completo.
+func parseInstantRange(values url.Values) (*time.Time, *time.Time, error) {
+
+	var from, to *time.Time
+
+	if fromStr := values.Get("from"); fromStr != "" {
+		instant, _, err := parseInstant(fromStr)
+		if err != nil {
+			return nil, nil, query.NewParamError("from", "el valor de from %s", err.Error())
+		}
+		from = &instant
+	}
+
+	if toStr := values.Get("to"); toStr != "" {
+		instant, dateOnly, err := parseInstant(toStr)
+		if err != nil {
+			return nil, nil, query.NewParamError("to", "el valor de to %s", err.Error())
+		}
+		if dateOnly {
+			instant = instant.Add(24*time.Hour - time.Nanosecond)
+		}
+		to = &instant
+	}
+
+	return from, to, nil
+
+}
+
+// parseInstant interpreta un instante RFC 3339 o una fecha dd/mm/aaaa o aaaa-mm-dd, e indica
+// si era una fecha
+func parseInstant(value string) (time.Time, bool, error) {
+
+	if instant, err := time.Parse(time.RFC3339, value); err == nil {
+		return instant, false, nil
+	}
+
+	for _, layout := range []string{"02/01/2006", "2006-01-02"} {
+		if date, err := time.Parse(layout, value); err == nil {
+			return date, true, nil
+		}
+	}
+
+	return time.Time{}, false, fmt.Errorf("debe ser un instante RFC 3339 o una fecha con formato dd/mm/aaaa")
+}
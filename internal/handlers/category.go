@@ -0,0 +1,187 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"PRACTICAS-GO-WEB/internal/domain"
+	"PRACTICAS-GO-WEB/internal/query"
+	"PRACTICAS-GO-WEB/internal/service"
+	"PRACTICAS-GO-WEB/pkg/web"
+)
+
+type categoryHandler struct {
+	service service.CategoryService
+}
+
+type CategoryHandler interface {
+	HandlerGetCategories(w http.ResponseWriter, r *http.Request)
+	HandlerGetCategoryTree(w http.ResponseWriter, r *http.Request)
+	HandlerGetCategoryByID(w http.ResponseWriter, r *http.Request)
+	HandlerGetCategoryProducts(w http.ResponseWriter, r *http.Request)
+	HandlerCreateCategory(w http.ResponseWriter, r *http.Request)
+	HandlerUpdateCategory(w http.ResponseWriter, r *http.Request)
+	HandlerDeleteCategory(w http.ResponseWriter, r *http.Request)
+}
+
+// función para crear un nuevo controlador de categorías
+func NewCategoryHandler(service service.CategoryService) CategoryHandler {
+
+	return &categoryHandler{service: service}
+
+}
+
+func (ch *categoryHandler) HandlerGetCategories(w http.ResponseWriter, r *http.Request) {
+
+	// Obtener la paginación, el orden y los campos pedidos
+	q, err := query.Parse(r.URL.Query(), domain.CategoryFields, domain.CategoryFields)
+	if err != nil {
+		web.ErrorFromError(w, r, err)
+		return
+	}
+
+	page, err := ch.service.GetCategories(q)
+	if err != nil {
+		web.ErrorFromError(w, r, err)
+		return
+	}
+
+	writePage(w, r, q, page, "categories found")
+
+}
+
+func (ch *categoryHandler) HandlerGetCategoryTree(w http.ResponseWriter, r *http.Request) {
+
+	tree, err := ch.service.GetCategoryTree()
+	if err != nil {
+		web.ErrorFromError(w, r, err)
+		return
+	}
+
+	web.Success(w, http.StatusOK, "category tree found", tree)
+
+}
+
+func (ch *categoryHandler) HandlerGetCategoryByID(w http.ResponseWriter, r *http.Request) {
+
+	// Obtener el ID de los parámetros de la URL
+	id, err := validateHeaderID(w, r)
+	if err != nil {
+		return
+	}
+
+	category, err := ch.service.GetCategoryByID(id)
+	if err != nil {
+		web.ErrorFromError(w, r, err)
+		return
+	}
+
+	web.Success(w, http.StatusOK, "category found", category)
+
+}
+
+// HandlerGetCategoryProducts devuelve los productos de la categoría y de sus subcategorías,
+// con la misma paginación, orden, selección de campos y moneda que el listado de productos
+func (ch *categoryHandler) HandlerGetCategoryProducts(w http.ResponseWriter, r *http.Request) {
+
+	// Obtener el ID de los parámetros de la URL
+	id, err := validateHeaderID(w, r)
+	if err != nil {
+		return
+	}
+
+	q, err := query.Parse(r.URL.Query(), domain.ProductFields, domain.ProductSelectableFields)
+	if err != nil {
+		web.ErrorFromError(w, r, err)
+		return
+	}
+
+	currency, err := parseCurrencyParam(r.URL.Query())
+	if err != nil {
+		web.ErrorFromError(w, r, err)
+		return
+	}
+
+	page, err := ch.service.GetCategoryProducts(id, q, currency)
+	if err != nil {
+		web.ErrorFromError(w, r, err)
+		return
+	}
+
+	writePage(w, r, q, page, "products found")
+
+}
+
+func (ch *categoryHandler) HandlerCreateCategory(w http.ResponseWriter, r *http.Request) {
+
+	// Leer el cuerpo de la solicitud
+	var categoryRequest domain.CategoryRequest
+	if err := json.NewDecoder(r.Body).Decode(&categoryRequest); err != nil {
+		web.Problem(w, r, http.StatusBadRequest, "Error al leer el cuerpo de la solicitud")
+		return
+	}
+
+	categoryCreated, err := ch.service.PostCategory(categoryRequest)
+	if err != nil {
+		web.ErrorFromError(w, r, fmt.Errorf("Error al registrar la nueva categoría: %w", err))
+		return
+	}
+
+	web.Success(w, http.StatusCreated, "category created", categoryCreated)
+
+}
+
+func (ch *categoryHandler) HandlerUpdateCategory(w http.ResponseWriter, r *http.Request) {
+
+	// Obtener el ID de los parámetros de la URL
+	id, err := validateHeaderID(w, r)
+	if err != nil {
+		return
+	}
+
+	// Leer el cuerpo de la solicitud
+	var categoryRequest domain.CategoryRequest
+	if err := json.NewDecoder(r.Body).Decode(&categoryRequest); err != nil {
+		web.Problem(w, r, http.StatusBadRequest, fmt.Sprintf("Error al leer el cuerpo de la solicitud: %s", err.Error()))
+		return
+	}
+
+	categoryUpdated, err := ch.service.PutCategory(id, categoryRequest)
+	if err != nil {
+		web.ErrorFromError(w, r, fmt.Errorf("Error al actualizar la categoría: %w", err))
+		return
+	}
+
+	web.Success(w, http.StatusOK, "category updated", categoryUpdated)
+
+}
+
+func (ch *categoryHandler) HandlerDeleteCategory(w http.ResponseWriter, r *http.Request) {
+
+	// Obtener el ID de los parámetros de la URL
+	id, err := validateHeaderID(w, r)
+	if err != nil {
+		return
+	}
+
+	// Con force=true se elimina aunque tenga subcategorías o productos asignados
+	force := false
+	if forceStr := r.URL.Query().Get("force"); forceStr != "" {
+		force, err = strconv.ParseBool(forceStr)
+		if err != nil {
+			web.ErrorFromError(w, r, query.NewParamError("force", "el valor de force debe ser true o false"))
+			return
+		}
+	}
+
+	if err := ch.service.DeleteCategory(r.Context(), id, force); err != nil {
+		web.ErrorFromError(w, r, fmt.Errorf("Error al eliminar la categoría: %w", err))
+		return
+	}
+
+	// Una respuesta 204 no puede tener cuerpo
+	w.WriteHeader(http.StatusNoContent)
+
+}
@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"PRACTICAS-GO-WEB/internal/domain"
+)
+
+// versionETag devuelve el ETag fuerte que identifica la versión de un recurso
+func versionETag(version int) string {
+	return fmt.Sprintf(`"v%d"`, version)
+}
+
+// versionFromETag obtiene la versión de un ETag fuerte. Los ETag débiles no sirven para
+// una comparación fuerte, por lo que no se reconocen.
+func versionFromETag(tag string) (int, bool) {
+
+	tag = strings.TrimSpace(tag)
+	if !strings.HasPrefix(tag, `"v`) || !strings.HasSuffix(tag, `"`) || len(tag) < 4 {
+		return 0, false
+	}
+
+	version, err := strconv.Atoi(tag[2 : len(tag)-1])
+	if err != nil || version < 1 {
+		return 0, false
+	}
+
+	return version, true
+}
+
+// ifMatchVersions obtiene las versiones aceptadas por el encabezado If-Match. Sin encabezado
+// o con "*" no se restringe la versión; con la configuración requireIfMatch, la ausencia del
+// encabezado es un error. Un encabezado sin ETags reconocibles no coincide con ninguna versión.
+func ifMatchVersions(r *http.Request, requireIfMatch bool) ([]int, error) {
+
+	header := strings.TrimSpace(r.Header.Get("If-Match"))
+	if header == "" {
+		if requireIfMatch {
+			return nil, domain.NewError(domain.ErrPreconditionRequired, "La operación requiere el encabezado If-Match con el ETag del producto")
+		}
+		return nil, nil
+	}
+
+	if header == "*" {
+		return nil, nil
+	}
+
+	var versions []int
+	for _, tag := range strings.Split(header, ",") {
+		if version, ok := versionFromETag(tag); ok {
+			versions = append(versions, version)
+		}
+	}
+
+	// La versión 0 no existe, por lo que la comparación falla con 412
+	if len(versions) == 0 {
+		versions = []int{0}
+	}
+
+	return versions, nil
+}
+
+// noneMatch indica si el encabezado If-None-Match no incluye el ETag de la versión. La
+// comparación es débil, como indica la RFC 9110 para GET.
+func noneMatch(r *http.Request, version int) bool {
+
+	header := strings.TrimSpace(r.Header.Get("If-None-Match"))
+	if header == "" {
+		return true
+	}
+
+	if header == "*" {
+		return false
+	}
+
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+		if current, ok := versionFromETag(tag); ok && current == version {
+			return false
+		}
+	}
+
+	return true
+}
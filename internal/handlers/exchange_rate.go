@@ -0,0 +1,55 @@
+package handlers
+
+import (
+	"net/http"
+
+	"PRACTICAS-GO-WEB/internal/service"
+	"PRACTICAS-GO-WEB/pkg/web"
+)
+
+type exchangeRateHandler struct {
+	service service.ExchangeRateService
+}
+
+type ExchangeRateHandler interface {
+	HandlerGetExchangeRates(w http.ResponseWriter, r *http.Request)
+	HandlerReloadExchangeRates(w http.ResponseWriter, r *http.Request)
+}
+
+// función para crear un nuevo controlador de la tabla de cotizaciones
+func NewExchangeRateHandler(service service.ExchangeRateService) ExchangeRateHandler {
+
+	return &exchangeRateHandler{service: service}
+
+}
+
+func (eh *exchangeRateHandler) HandlerGetExchangeRates(w http.ResponseWriter, r *http.Request) {
+
+	rates, err := eh.service.GetExchangeRates()
+	if err != nil {
+		web.ErrorFromError(w, r, err)
+		return
+	}
+
+	web.Success(w, http.StatusOK, "exchange rates found", rates)
+
+}
+
+// HandlerReloadExchangeRates vuelve a leer el archivo de cotizaciones sin esperar a la
+// recarga periódica. Si el archivo no es válido se mantiene la tabla vigente.
+func (eh *exchangeRateHandler) HandlerReloadExchangeRates(w http.ResponseWriter, r *http.Request) {
+
+	rates, changed, err := eh.service.ReloadExchangeRates()
+	if err != nil {
+		web.ErrorFromError(w, r, err)
+		return
+	}
+
+	message := "exchange rates unchanged"
+	if changed {
+		message = "exchange rates reloaded"
+	}
+
+	web.Success(w, http.StatusOK, message, rates)
+
+}
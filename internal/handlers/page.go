@@ -0,0 +1,59 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"PRACTICAS-GO-WEB/internal/query"
+	"PRACTICAS-GO-WEB/pkg/web"
+)
+
+// pageMeta son los metadatos de paginación que acompañan a una página de resultados
+type pageMeta struct {
+	Total      int    `json:"total"`
+	Count      int    `json:"count"`
+	Offset     int    `json:"offset"`
+	Limit      int    `json:"limit,omitempty"`
+	NextCursor string `json:"next_cursor,omitempty"`
+	PrevCursor string `json:"prev_cursor,omitempty"`
+}
+
+// writePage responde con una página de resultados, sus metadatos y los enlaces next y prev.
+// Si la solicitud usó un cursor, los enlaces usan cursores; si no, offsets.
+func writePage[T any](w http.ResponseWriter, r *http.Request, q query.Query, page query.Page[T], message string) {
+
+	var links []web.Link
+
+	if q.Cursor != "" {
+		if page.NextCursor != "" {
+			links = append(links, web.Link{URL: web.URLWithQuery(r, map[string]string{"cursor": page.NextCursor}), Rel: "next"})
+		}
+		if page.PrevCursor != "" {
+			links = append(links, web.Link{URL: web.URLWithQuery(r, map[string]string{"cursor": page.PrevCursor}), Rel: "prev"})
+		}
+	} else {
+		if page.NextOffset != -1 {
+			links = append(links, web.Link{URL: web.URLWithQuery(r, map[string]string{"offset": strconv.Itoa(page.NextOffset)}), Rel: "next"})
+		}
+		if page.PrevOffset != -1 {
+			links = append(links, web.Link{URL: web.URLWithQuery(r, map[string]string{"offset": strconv.Itoa(page.PrevOffset)}), Rel: "prev"})
+		}
+	}
+
+	web.SetLinks(w, links...)
+
+	items := page.Items
+	if items == nil {
+		items = []T{}
+	}
+
+	web.SuccessWithMeta(w, http.StatusOK, message, items, pageMeta{
+		Total:      page.Total,
+		Count:      len(page.Items),
+		Offset:     page.Offset,
+		Limit:      page.Limit,
+		NextCursor: page.NextCursor,
+		PrevCursor: page.PrevCursor,
+	})
+
+}
@@ -4,12 +4,18 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
+	"math"
 	"net/http"
-	"os"
+	"net/url"
+	"slices"
 	"strconv"
+	"strings"
 	"time"
 
 	"PRACTICAS-GO-WEB/internal/domain"
+	"PRACTICAS-GO-WEB/internal/patch"
+	"PRACTICAS-GO-WEB/internal/query"
 	"PRACTICAS-GO-WEB/internal/service"
 	"PRACTICAS-GO-WEB/pkg/web"
 
@@ -17,26 +23,84 @@ import (
 )
 
 type productHandler struct {
-	service            service.ProductService
-	tokenAuthorization string
+	service service.ProductService
+	// requireIfMatch exige el encabezado If-Match en las modificaciones y bajas
+	requireIfMatch bool
 }
 
 type ProductHandler interface {
 	HandlerPing(w http.ResponseWriter, r *http.Request)
 	HandlerGetAllProduct(w http.ResponseWriter, r *http.Request)
 	HandlerGetProductByID(w http.ResponseWriter, r *http.Request)
-	HandlerSearchProductByPrice(w http.ResponseWriter, r *http.Request)
+	HandlerSearchProducts(w http.ResponseWriter, r *http.Request)
 	HandlerCreateProduct(w http.ResponseWriter, r *http.Request)
 	HandlerUpdateProduct(w http.ResponseWriter, r *http.Request)
 	HandlerUpdatePartialProduct(w http.ResponseWriter, r *http.Request)
 	HandlerDeleteProduct(w http.ResponseWriter, r *http.Request)
+	HandlerGetTrash(w http.ResponseWriter, r *http.Request)
+	HandlerRestoreProduct(w http.ResponseWriter, r *http.Request)
+	HandlerGetProductPrices(w http.ResponseWriter, r *http.Request)
+	HandlerSchedulePrice(w http.ResponseWriter, r *http.Request)
+	HandlerGetProductStock(w http.ResponseWriter, r *http.Request)
+	HandlerSetStock(w http.ResponseWriter, r *http.Request)
+	HandlerTransferStock(w http.ResponseWriter, r *http.Request)
+	HandlerRecordStockMovement(w http.ResponseWriter, r *http.Request)
+	HandlerGetStockMovements(w http.ResponseWriter, r *http.Request)
+	HandlerGetExpiringProducts(w http.ResponseWriter, r *http.Request)
+	HandlerGetExpiredProducts(w http.ResponseWriter, r *http.Request)
+	HandlerReserveStock(w http.ResponseWriter, r *http.Request)
+	HandlerGetReservations(w http.ResponseWriter, r *http.Request)
+	HandlerGetReservation(w http.ResponseWriter, r *http.Request)
+	HandlerConfirmReservation(w http.ResponseWriter, r *http.Request)
+	HandlerReleaseReservation(w http.ResponseWriter, r *http.Request)
 }
 
-// función para crear un nuevo controlador de productos
-func NewProductHandler(service service.ProductService) ProductHandler {
+// defaultExpiringWithin es el plazo de los productos por vencer si no se indica otro
+const defaultExpiringWithin = 7 * 24 * time.Hour
+
+// Códigos de estado HTTP de cada categoría de error del dominio
+func init() {
+	web.RegisterErrorStatus(domain.ErrNotFound, http.StatusNotFound)
+	web.RegisterErrorStatus(domain.ErrDuplicateCodeValue, http.StatusConflict)
+	web.RegisterErrorStatus(domain.ErrValidation, http.StatusUnprocessableEntity)
+	web.RegisterErrorStatus(domain.ErrStorage, http.StatusInternalServerError)
+	web.RegisterErrorStatus(domain.ErrVersionMismatch, http.StatusPreconditionFailed)
+	web.RegisterErrorStatus(domain.ErrPreconditionRequired, http.StatusPreconditionRequired)
+	web.RegisterErrorStatus(domain.ErrUnsupportedCurrency, http.StatusBadRequest)
+	web.RegisterErrorStatus(domain.ErrConflict, http.StatusConflict)
+	web.RegisterErrorStatus(query.ErrInvalidQuery, http.StatusBadRequest)
+	web.RegisterErrorStatus(patch.ErrInvalidPatch, http.StatusBadRequest)
+	web.RegisterErrorStatus(patch.ErrConflict, http.StatusConflict)
+	web.RegisterErrorStatus(patch.ErrUnsupportedMediaType, http.StatusUnsupportedMediaType)
+
+	web.RegisterFieldErrors(func(err error) []web.FieldError {
+		var validation *domain.ValidationError
+		if !errors.As(err, &validation) {
+			return nil
+		}
+
+		fieldErrors := make([]web.FieldError, len(validation.Fields))
+		for i, field := range validation.Fields {
+			fieldErrors[i] = web.FieldError{Field: field.Field, Reason: field.Reason}
+		}
+		return fieldErrors
+	})
 
-	token := os.Getenv("Token")
-	return &productHandler{service: service, tokenAuthorization: token}
+	web.RegisterFieldErrors(func(err error) []web.FieldError {
+		var paramError *query.ParamError
+		if !errors.As(err, &paramError) {
+			return nil
+		}
+
+		return []web.FieldError{{Field: paramError.Param, Reason: paramError.Reason}}
+	})
+}
+
+// función para crear un nuevo controlador de productos. Con requireIfMatch, PUT, PATCH y
+// DELETE responden 428 si la solicitud no indica el ETag esperado en If-Match.
+func NewProductHandler(service service.ProductService, requireIfMatch bool) ProductHandler {
+
+	return &productHandler{service: service, requireIfMatch: requireIfMatch}
 
 }
 
@@ -52,28 +116,80 @@ func (ph *productHandler) HandlerPing(w http.ResponseWriter, r *http.Request) {
 
 func (ph *productHandler) HandlerGetAllProduct(w http.ResponseWriter, r *http.Request) {
 
-	// Obtener todos los productos del servicio
-	products, err := ph.service.GetProducts()
+	// Obtener la paginación, el orden y los campos pedidos
+	q, err := query.Parse(r.URL.Query(), domain.ProductFields, domain.ProductSelectableFields)
+	if err != nil {
+		web.ErrorFromError(w, r, err)
+		return
+	}
+
+	// Obtener la moneda en la que se informan los precios
+	currency, err := parseCurrencyParam(r.URL.Query())
+	if err != nil {
+		web.ErrorFromError(w, r, err)
+		return
+	}
+
+	// Obtener la página de productos del servicio
+	page, err := ph.service.GetProducts(q, currency)
 	if err != nil {
-		web.Error(w, http.StatusInternalServerError, err.Error())
+		web.ErrorFromError(w, r, err)
 		return
 	}
 
-	web.Success(w, http.StatusOK, "products found", products)
+	writePage(w, r, q, page, "products found")
 
 }
 
 func (ph *productHandler) HandlerGetProductByID(w http.ResponseWriter, r *http.Request) {
 
 	// Obtener el ID de los parámetros de la URL
-	id, err := ph.validateHeaderID(w, r)
+	id, err := validateHeaderID(w, r)
+	if err != nil {
+		return
+	}
+
+	// Con at se devuelve el producto con el precio vigente en esa fecha
+	if atStr := r.URL.Query().Get("at"); atStr != "" {
+		at, _, err := parseInstant(atStr)
+		if err != nil {
+			web.ErrorFromError(w, r, query.NewParamError("at", "el valor de at %s", err.Error()))
+			return
+		}
+
+		product, err := ph.service.GetProductAt(id, at)
+		if err != nil {
+			web.ErrorFromError(w, r, err)
+			return
+		}
+
+		web.Success(w, http.StatusOK, "product found", product)
+		return
+	}
+
+	currency, err := parseCurrencyParam(r.URL.Query())
 	if err != nil {
+		web.ErrorFromError(w, r, err)
 		return
 	}
 
-	product, err := ph.service.GetProductByID(id)
+	product, err := ph.service.GetProductByID(id, currency)
 	if err != nil {
-		web.Error(w, http.StatusInternalServerError, err.Error())
+		web.ErrorFromError(w, r, err)
+		return
+	}
+
+	// Un precio convertido depende de la tabla de cotizaciones y no solo de la versión
+	if currency != "" {
+		web.Success(w, http.StatusOK, "product found", product)
+		return
+	}
+
+	// Si el cliente ya tiene esta versión no se reenvía el producto
+	w.Header().Set("ETag", versionETag(product.Version))
+	w.Header().Set("Accept-Patch", patch.AcceptPatch)
+	if !noneMatch(r, product.Version) {
+		w.WriteHeader(http.StatusNotModified)
 		return
 	}
 
@@ -81,76 +197,145 @@ func (ph *productHandler) HandlerGetProductByID(w http.ResponseWriter, r *http.R
 
 }
 
-func (ph *productHandler) HandlerSearchProductByPrice(w http.ResponseWriter, r *http.Request) {
+func (ph *productHandler) HandlerSearchProducts(w http.ResponseWriter, r *http.Request) {
 
-	// Obtener el ID de los parámetros de la URL
-	var priceGtStr string = r.URL.Query().Get("priceGt")
-	if priceGtStr == "" {
-		web.Error(w, http.StatusBadRequest, "El valor de priceGt es requerido")
+	// Obtener la moneda pedida; los filtros de precio se expresan en ella
+	currency, err := parseCurrencyParam(r.URL.Query())
+	if err != nil {
+		web.ErrorFromError(w, r, err)
+		return
+	}
+
+	// Obtener los criterios de búsqueda de los parámetros de la URL
+	filter, err := parseProductFilter(r.URL.Query(), currency)
+	if err != nil {
+		web.ErrorFromError(w, r, err)
 		return
 	}
 
-	// Convertir el priceGt a float64
-	priceGt, err := strconv.ParseFloat(priceGtStr, 64)
+	// Obtener la paginación, el orden y los campos pedidos; la búsqueda de texto agrega la relevancia
+	q, err := query.Parse(r.URL.Query(), domain.ProductFields, append(slices.Clone(domain.ProductSelectableFields), "score"))
 	if err != nil {
-		web.Error(w, http.StatusBadRequest, "El valor de priceGt debe ser un numero decimal")
+		web.ErrorFromError(w, r, err)
 		return
 	}
 
-	// Buscar los productos en el slice
-	filteredProducts, err := ph.service.SearchProductByPrice(priceGt)
+	// Buscar los productos; sin resultados se responde con una lista vacía
+	page, err := ph.service.SearchProducts(filter, q, currency)
+	if err != nil {
+		web.ErrorFromError(w, r, err)
+		return
+	}
 
-	web.Success(w, http.StatusOK, "products found", filteredProducts)
+	writePage(w, r, q, page, "products found")
 
 }
 
-func (ph *productHandler) HandlerCreateProduct(w http.ResponseWriter, r *http.Request) {
+// HandlerGetExpiringProducts devuelve los productos que vencen en el plazo indicado en within,
+// por ejemplo 7d; admite los mismos filtros que la búsqueda
+func (ph *productHandler) HandlerGetExpiringProducts(w http.ResponseWriter, r *http.Request) {
+
+	// Obtener el plazo pedido; por defecto, una semana
+	within, err := parseDurationParam(r.URL.Query(), "within")
+	if err != nil {
+		web.ErrorFromError(w, r, err)
+		return
+	}
+	if within == nil {
+		week := defaultExpiringWithin
+		within = &week
+	}
+
+	ph.writeExpirationReport(w, r, func(filter domain.ProductFilter, q query.Query, currency string) (query.Page[any], error) {
+		return ph.service.GetExpiringProducts(filter, *within, q, currency)
+	}, "expiring products found")
+
+}
+
+// HandlerGetExpiredProducts devuelve los productos vencidos; admite los mismos filtros que la
+// búsqueda
+func (ph *productHandler) HandlerGetExpiredProducts(w http.ResponseWriter, r *http.Request) {
 
-	token := r.Header.Get("Token")
-	if token != ph.tokenAuthorization {
-		web.Error(w, http.StatusUnauthorized, "Token de autentificación inválido")
+	ph.writeExpirationReport(w, r, ph.service.GetExpiredProducts, "expired products found")
+
+}
+
+// writeExpirationReport responde con la página de productos que devuelve find, con los
+// filtros, la moneda y la paginación de la solicitud
+func (ph *productHandler) writeExpirationReport(w http.ResponseWriter, r *http.Request, find func(filter domain.ProductFilter, q query.Query, currency string) (query.Page[any], error), message string) {
+
+	// Obtener la moneda pedida; los filtros de precio se expresan en ella
+	currency, err := parseCurrencyParam(r.URL.Query())
+	if err != nil {
+		web.ErrorFromError(w, r, err)
+		return
+	}
+
+	// Obtener los criterios de búsqueda de los parámetros de la URL
+	filter, err := parseProductFilter(r.URL.Query(), currency)
+	if err != nil {
+		web.ErrorFromError(w, r, err)
+		return
+	}
+
+	// Obtener la paginación, el orden y los campos pedidos
+	q, err := query.Parse(r.URL.Query(), domain.ProductFields, domain.ProductSelectableFields)
+	if err != nil {
+		web.ErrorFromError(w, r, err)
 		return
 	}
 
+	page, err := find(filter, q, currency)
+	if err != nil {
+		web.ErrorFromError(w, r, err)
+		return
+	}
+
+	writePage(w, r, q, page, message)
+
+}
+
+func (ph *productHandler) HandlerCreateProduct(w http.ResponseWriter, r *http.Request) {
+
 	// Leer el cuerpo de la solicitud
 	var productRequest domain.ProductRequest
 	err := json.NewDecoder(r.Body).Decode(&productRequest)
 	if err != nil {
-		web.Error(w, http.StatusBadRequest, "Error al leer el cuerpo de la solicitud")
+		web.Problem(w, r, http.StatusBadRequest, "Error al leer el cuerpo de la solicitud")
 		return
 	}
 
 	// Validar los campos del producto de la solicitud
-	err = ph.validateFullRequest(productRequest)
+	err = productRequest.ValidateNew()
 	if err != nil {
-		errStr := fmt.Sprintf("Error al validar los datos del producto: %s", err.Error())
-		web.Error(w, http.StatusBadRequest, errStr)
+		web.ErrorFromError(w, r, fmt.Errorf("Error al validar los datos del producto: %w", err))
 		return
 	}
 
 	// Validar el producto
-	productCreated, err := ph.service.PostProduct(productRequest)
+	productCreated, err := ph.service.PostProduct(r.Context(), productRequest)
 	if err != nil {
-		errStr := fmt.Sprintf("Error al registrar el nuevo producto: %s", err.Error())
-		web.Error(w, http.StatusInternalServerError, errStr)
+		web.ErrorFromError(w, r, fmt.Errorf("Error al registrar el nuevo producto: %w", err))
 		return
 	}
 
+	w.Header().Set("ETag", versionETag(productCreated.Version))
 	web.Success(w, http.StatusCreated, "product created", productCreated)
 
 }
 
 func (ph *productHandler) HandlerUpdateProduct(w http.ResponseWriter, r *http.Request) {
 
-	token := r.Header.Get("Token")
-	if token != ph.tokenAuthorization {
-		web.Error(w, http.StatusUnauthorized, "Token de autentificación inválido")
+	// Obtener el ID de los parámetros de la URL
+	id, err := validateHeaderID(w, r)
+	if err != nil {
 		return
 	}
 
-	// Obtener el ID de los parámetros de la URL
-	id, err := ph.validateHeaderID(w, r)
+	// Obtener las versiones esperadas del producto
+	ifMatch, err := ifMatchVersions(r, ph.requireIfMatch)
 	if err != nil {
+		web.ErrorFromError(w, r, err)
 		return
 	}
 
@@ -159,136 +344,714 @@ func (ph *productHandler) HandlerUpdateProduct(w http.ResponseWriter, r *http.Re
 	err = json.NewDecoder(r.Body).Decode(&productRequest)
 	if err != nil {
 		errStr := fmt.Sprintf("Error al leer el cuerpo de la solicitud: %s", err.Error())
-		web.Error(w, http.StatusBadRequest, errStr)
+		web.Problem(w, r, http.StatusBadRequest, errStr)
 		return
 	}
 
 	// Validar los campos del producto de la solicitud
-	err = ph.validateFullRequest(productRequest)
+	err = productRequest.ValidateFull()
 	if err != nil {
-		errStr := fmt.Sprintf("Error al validar los datos del producto: %s", err.Error())
-		web.Error(w, http.StatusBadRequest, errStr)
+		web.ErrorFromError(w, r, fmt.Errorf("Error al validar los datos del producto: %w", err))
 		return
 	}
 
 	// Validar el producto
-	productUpdated, err := ph.service.PutProduct(id, productRequest)
+	productUpdated, err := ph.service.PutProduct(r.Context(), id, productRequest, ifMatch...)
 	if err != nil {
-		errStr := fmt.Sprintf("Error al actualizar el producto: %s", err.Error())
-		web.Error(w, http.StatusBadRequest, errStr)
+		web.ErrorFromError(w, r, fmt.Errorf("Error al actualizar el producto: %w", err))
 		return
 	}
 
+	w.Header().Set("ETag", versionETag(productUpdated.Version))
 	web.Success(w, http.StatusOK, "product updated", productUpdated)
 
 }
 
 func (ph *productHandler) HandlerUpdatePartialProduct(w http.ResponseWriter, r *http.Request) {
 
-	token := r.Header.Get("Token")
-	if token != ph.tokenAuthorization {
-		web.Error(w, http.StatusUnauthorized, "Token de autentificación inválido")
+	// Obtener el ID de los parámetros de la URL
+	id, err := validateHeaderID(w, r)
+	if err != nil {
 		return
 	}
 
-	// Obtener el ID de los parámetros de la URL
-	id, err := ph.validateHeaderID(w, r)
+	// Obtener las versiones esperadas del producto
+	ifMatch, err := ifMatchVersions(r, ph.requireIfMatch)
 	if err != nil {
+		web.ErrorFromError(w, r, err)
 		return
 	}
 
 	// Leer el cuerpo de la solicitud
-	var productRequest domain.ProductRequest
-	err = json.NewDecoder(r.Body).Decode(&productRequest)
+	body, err := io.ReadAll(r.Body)
 	if err != nil {
-		web.Error(w, http.StatusBadRequest, "Error al leer el cuerpo de la solicitud")
+		web.Problem(w, r, http.StatusBadRequest, "Error al leer el cuerpo de la solicitud")
 		return
 	}
 
-	// Validar el producto
-	productUpdated, err := ph.service.PatchProduct(id, productRequest)
+	// El formato del parche depende del tipo de contenido
+	productPatch, err := patch.Decode(r.Header.Get("Content-Type"), body)
 	if err != nil {
-		errStr := fmt.Sprintf("Error al actualizar el producto: %s", err.Error())
-		web.Error(w, http.StatusBadRequest, errStr)
+		if errors.Is(err, patch.ErrUnsupportedMediaType) {
+			w.Header().Set("Accept-Patch", patch.AcceptPatch)
+		}
+		web.ErrorFromError(w, r, err)
 		return
 	}
 
+	// Aplicar el parche y validar el producto resultante
+	productUpdated, err := ph.service.PatchProduct(r.Context(), id, productPatch, ifMatch...)
+	if err != nil {
+		web.ErrorFromError(w, r, fmt.Errorf("Error al actualizar el producto: %w", err))
+		return
+	}
+
+	w.Header().Set("ETag", versionETag(productUpdated.Version))
 	web.Success(w, http.StatusOK, "product updated", productUpdated)
 
 }
 
 func (ph *productHandler) HandlerDeleteProduct(w http.ResponseWriter, r *http.Request) {
 
-	token := r.Header.Get("Token")
-	if token != ph.tokenAuthorization {
-		web.Error(w, http.StatusUnauthorized, "Token de autentificación inválido")
+	// Obtener el ID de los parámetros de la URL
+	id, err := validateHeaderID(w, r)
+	if err != nil {
+		return
+	}
+
+	// Con purge=true el producto se elimina de forma definitiva; si no, va a la papelera
+	purge := false
+	if purgeStr := r.URL.Query().Get("purge"); purgeStr != "" {
+		purge, err = strconv.ParseBool(purgeStr)
+		if err != nil {
+			web.ErrorFromError(w, r, query.NewParamError("purge", "el valor de purge debe ser true o false"))
+			return
+		}
+	}
+
+	// Obtener las versiones esperadas del producto
+	ifMatch, err := ifMatchVersions(r, ph.requireIfMatch)
+	if err != nil {
+		web.ErrorFromError(w, r, err)
 		return
 	}
 
+	if purge {
+		err = ph.service.PurgeProduct(r.Context(), id, ifMatch...)
+	} else {
+		err = ph.service.DeleteProduct(r.Context(), id, ifMatch...)
+	}
+	if err != nil {
+		web.ErrorFromError(w, r, fmt.Errorf("Error al eliminar el producto: %w", err))
+		return
+	}
+
+	// Una respuesta 204 no puede tener cuerpo
+	w.WriteHeader(http.StatusNoContent)
+
+}
+
+func (ph *productHandler) HandlerGetTrash(w http.ResponseWriter, r *http.Request) {
+
+	// Obtener la paginación, el orden y los campos pedidos
+	q, err := query.Parse(r.URL.Query(), domain.ProductTrashFields, domain.ProductTrashFields)
+	if err != nil {
+		web.ErrorFromError(w, r, err)
+		return
+	}
+
+	// Obtener la página de productos en la papelera
+	page, err := ph.service.GetTrash(q)
+	if err != nil {
+		web.ErrorFromError(w, r, err)
+		return
+	}
+
+	writePage(w, r, q, page, "deleted products found")
+
+}
+
+func (ph *productHandler) HandlerRestoreProduct(w http.ResponseWriter, r *http.Request) {
+
 	// Obtener el ID de los parámetros de la URL
-	id, err := ph.validateHeaderID(w, r)
+	id, err := validateHeaderID(w, r)
 	if err != nil {
 		return
 	}
 
-	err = ph.service.DeleteProduct(id)
+	// Obtener las versiones esperadas del producto
+	ifMatch, err := ifMatchVersions(r, ph.requireIfMatch)
 	if err != nil {
-		errStr := fmt.Sprintf("Error al eliminar el producto: %s", err.Error())
-		web.Error(w, http.StatusInternalServerError, errStr)
+		web.ErrorFromError(w, r, err)
 		return
 	}
 
-	web.Success(w, http.StatusNoContent, "product deleted", nil)
+	productRestored, err := ph.service.RestoreProduct(r.Context(), id, ifMatch...)
+	if err != nil {
+		web.ErrorFromError(w, r, fmt.Errorf("Error al recuperar el producto: %w", err))
+		return
+	}
+
+	w.Header().Set("ETag", versionETag(productRestored.Version))
+	web.Success(w, http.StatusOK, "product restored", productRestored)
 
 }
 
-func (ph *productHandler) validateFullRequest(productRequest domain.ProductRequest) error {
+func (ph *productHandler) HandlerGetProductPrices(w http.ResponseWriter, r *http.Request) {
 
-	if productRequest.Name == nil {
-		return errors.New("El nombre del producto es un campo requerido")
+	// Obtener el ID de los parámetros de la URL
+	id, err := validateHeaderID(w, r)
+	if err != nil {
+		return
 	}
 
-	if productRequest.Quantity == nil {
-		return errors.New("El stock del producto es un campo requerido")
+	prices, err := ph.service.GetProductPrices(id)
+	if err != nil {
+		web.ErrorFromError(w, r, err)
+		return
 	}
 
-	if productRequest.CodeValue == nil {
-		return errors.New("El codigo del producto es un campo requerido")
+	w.Header().Set("ETag", versionETag(prices.Version))
+	web.Success(w, http.StatusOK, "product prices found", prices)
+
+}
+
+func (ph *productHandler) HandlerSchedulePrice(w http.ResponseWriter, r *http.Request) {
+
+	// Obtener el ID de los parámetros de la URL
+	id, err := validateHeaderID(w, r)
+	if err != nil {
+		return
 	}
 
-	if productRequest.Expiration != nil {
-		if _, err := time.Parse("02/01/2006", *productRequest.Expiration); err != nil {
-			return errors.New("La fecha de expiración no posee un formato válido")
-		}
+	// Obtener las versiones esperadas del producto
+	ifMatch, err := ifMatchVersions(r, ph.requireIfMatch)
+	if err != nil {
+		web.ErrorFromError(w, r, err)
+		return
 	}
 
-	if productRequest.IsPublished == nil {
-		return errors.New("El estado de publicación del producto es un campo requerido")
+	// Leer el cuerpo de la solicitud
+	var priceRequest domain.PriceRequest
+	if err := json.NewDecoder(r.Body).Decode(&priceRequest); err != nil {
+		web.Problem(w, r, http.StatusBadRequest, "Error al leer el cuerpo de la solicitud")
+		return
 	}
 
-	if productRequest.Price == nil {
-		return errors.New("El precio del producto es un campo requerido")
+	prices, err := ph.service.SchedulePrice(r.Context(), id, priceRequest, ifMatch...)
+	if err != nil {
+		web.ErrorFromError(w, r, fmt.Errorf("Error al programar el precio del producto: %w", err))
+		return
 	}
 
-	return nil
+	w.Header().Set("ETag", versionETag(prices.Version))
+	web.Success(w, http.StatusCreated, "product price scheduled", prices)
 
 }
 
-func (ph *productHandler) validateHeaderID(w http.ResponseWriter, r *http.Request) (int, error) {
+func (ph *productHandler) HandlerGetProductStock(w http.ResponseWriter, r *http.Request) {
+
+	// Obtener el ID de los parámetros de la URL
+	id, err := validateHeaderID(w, r)
+	if err != nil {
+		return
+	}
+
+	stock, err := ph.service.GetProductStock(id)
+	if err != nil {
+		web.ErrorFromError(w, r, err)
+		return
+	}
+
+	w.Header().Set("ETag", versionETag(stock.Version))
+	web.Success(w, http.StatusOK, "product stock found", stock)
+
+}
+
+// HandlerSetStock fija la existencia del producto en el depósito indicado en la URL
+func (ph *productHandler) HandlerSetStock(w http.ResponseWriter, r *http.Request) {
+
+	// Obtener el ID del producto y el del depósito de los parámetros de la URL
+	id, err := validateHeaderID(w, r)
+	if err != nil {
+		return
+	}
+
+	warehouseID, err := strconv.Atoi(chi.URLParam(r, "warehouseId"))
+	if err != nil {
+		web.Problem(w, r, http.StatusBadRequest, "El ID del depósito debe ser un número entero")
+		return
+	}
+
+	// Obtener las versiones esperadas del producto
+	ifMatch, err := ifMatchVersions(r, ph.requireIfMatch)
+	if err != nil {
+		web.ErrorFromError(w, r, err)
+		return
+	}
+
+	// Leer el cuerpo de la solicitud
+	var stockRequest domain.StockRequest
+	if err := json.NewDecoder(r.Body).Decode(&stockRequest); err != nil {
+		web.Problem(w, r, http.StatusBadRequest, "Error al leer el cuerpo de la solicitud")
+		return
+	}
+
+	stock, err := ph.service.SetStock(r.Context(), id, warehouseID, stockRequest, ifMatch...)
+	if err != nil {
+		web.ErrorFromError(w, r, fmt.Errorf("Error al actualizar la existencia del producto: %w", err))
+		return
+	}
+
+	w.Header().Set("ETag", versionETag(stock.Version))
+	web.Success(w, http.StatusOK, "product stock updated", stock)
+
+}
+
+func (ph *productHandler) HandlerTransferStock(w http.ResponseWriter, r *http.Request) {
+
+	// Obtener el ID de los parámetros de la URL
+	id, err := validateHeaderID(w, r)
+	if err != nil {
+		return
+	}
+
+	// Obtener las versiones esperadas del producto
+	ifMatch, err := ifMatchVersions(r, ph.requireIfMatch)
+	if err != nil {
+		web.ErrorFromError(w, r, err)
+		return
+	}
+
+	// Leer el cuerpo de la solicitud
+	var transferRequest domain.TransferRequest
+	if err := json.NewDecoder(r.Body).Decode(&transferRequest); err != nil {
+		web.Problem(w, r, http.StatusBadRequest, "Error al leer el cuerpo de la solicitud")
+		return
+	}
+
+	stock, err := ph.service.TransferStock(r.Context(), id, transferRequest, ifMatch...)
+	if err != nil {
+		web.ErrorFromError(w, r, fmt.Errorf("Error al transferir las existencias del producto: %w", err))
+		return
+	}
+
+	w.Header().Set("ETag", versionETag(stock.Version))
+	web.Success(w, http.StatusOK, "product stock transferred", stock)
+
+}
+
+// HandlerRecordStockMovement registra un movimiento en el libro de stock del producto
+func (ph *productHandler) HandlerRecordStockMovement(w http.ResponseWriter, r *http.Request) {
+
+	// Obtener el ID de los parámetros de la URL
+	id, err := validateHeaderID(w, r)
+	if err != nil {
+		return
+	}
+
+	// Obtener las versiones esperadas del producto
+	ifMatch, err := ifMatchVersions(r, ph.requireIfMatch)
+	if err != nil {
+		web.ErrorFromError(w, r, err)
+		return
+	}
+
+	// Leer el cuerpo de la solicitud
+	var movementRequest domain.StockMovementRequest
+	if err := json.NewDecoder(r.Body).Decode(&movementRequest); err != nil {
+		web.Problem(w, r, http.StatusBadRequest, "Error al leer el cuerpo de la solicitud")
+		return
+	}
+
+	movement, err := ph.service.RecordStockMovement(r.Context(), id, movementRequest, ifMatch...)
+	if err != nil {
+		web.ErrorFromError(w, r, fmt.Errorf("Error al registrar el movimiento de stock: %w", err))
+		return
+	}
+
+	web.Success(w, http.StatusCreated, "stock movement recorded", movement)
+
+}
+
+// HandlerGetStockMovements devuelve el libro de stock del producto, filtrado por tipo,
+// depósito y fecha
+func (ph *productHandler) HandlerGetStockMovements(w http.ResponseWriter, r *http.Request) {
+
+	// Obtener el ID de los parámetros de la URL
+	id, err := validateHeaderID(w, r)
+	if err != nil {
+		return
+	}
+
+	// Obtener los criterios de búsqueda de los parámetros de la URL
+	filter, err := parseStockMovementFilter(r.URL.Query())
+	if err != nil {
+		web.ErrorFromError(w, r, err)
+		return
+	}
+
+	// Obtener la paginación pedida; los movimientos se devuelven en orden cronológico
+	q, err := query.Parse(r.URL.Query(), nil, nil)
+	if err != nil {
+		web.ErrorFromError(w, r, err)
+		return
+	}
+
+	page, err := ph.service.GetStockMovements(id, filter, q)
+	if err != nil {
+		web.ErrorFromError(w, r, err)
+		return
+	}
+
+	writePage(w, r, q, page, "stock movements found")
+
+}
+
+// parseStockMovementFilter obtiene el filtro del libro de stock de los parámetros de la URL.
+// from y to aceptan un instante RFC 3339 o una fecha; una fecha en to incluye el día completo.
+func parseStockMovementFilter(values url.Values) (domain.StockMovementFilter, error) {
+
+	var filter domain.StockMovementFilter
+	var err error
+
+	if movementType := parseStringParam(values, "type"); movementType != nil {
+		filter.Type = (*domain.MovementType)(movementType)
+	}
+
+	if filter.WarehouseID, err = parseIntParam(values, "warehouseId"); err != nil {
+		return filter, err
+	}
+
+	filter.From, filter.To, err = parseInstantRange(values)
+
+	return filter, err
+
+}
+
+// HandlerReserveStock retiene existencias disponibles del producto hasta que la reserva se
+// confirma, se libera o vence
+func (ph *productHandler) HandlerReserveStock(w http.ResponseWriter, r *http.Request) {
+
+	// Obtener el ID de los parámetros de la URL
+	id, err := validateHeaderID(w, r)
+	if err != nil {
+		return
+	}
+
+	// Leer el cuerpo de la solicitud
+	var reservationRequest domain.ReservationRequest
+	if err := json.NewDecoder(r.Body).Decode(&reservationRequest); err != nil {
+		web.Problem(w, r, http.StatusBadRequest, "Error al leer el cuerpo de la solicitud")
+		return
+	}
+
+	reservation, err := ph.service.ReserveStock(r.Context(), id, reservationRequest)
+	if err != nil {
+		web.ErrorFromError(w, r, fmt.Errorf("Error al reservar stock: %w", err))
+		return
+	}
+
+	web.Success(w, http.StatusCreated, "stock reserved", reservation)
+
+}
+
+// HandlerGetReservations devuelve las reservas del producto, filtradas por estado
+func (ph *productHandler) HandlerGetReservations(w http.ResponseWriter, r *http.Request) {
+
+	// Obtener el ID de los parámetros de la URL
+	id, err := validateHeaderID(w, r)
+	if err != nil {
+		return
+	}
+
+	// Obtener los criterios de búsqueda de los parámetros de la URL
+	filter, err := parseReservationFilter(r.URL.Query())
+	if err != nil {
+		web.ErrorFromError(w, r, err)
+		return
+	}
+
+	// Obtener la paginación pedida; las reservas se devuelven en el orden en que se crearon
+	q, err := query.Parse(r.URL.Query(), nil, nil)
+	if err != nil {
+		web.ErrorFromError(w, r, err)
+		return
+	}
+
+	page, err := ph.service.GetReservations(id, filter, q)
+	if err != nil {
+		web.ErrorFromError(w, r, err)
+		return
+	}
+
+	writePage(w, r, q, page, "reservations found")
+
+}
+
+func (ph *productHandler) HandlerGetReservation(w http.ResponseWriter, r *http.Request) {
+
+	id, reservationID, err := validateReservationID(w, r)
+	if err != nil {
+		return
+	}
+
+	reservation, err := ph.service.GetReservation(id, reservationID)
+	if err != nil {
+		web.ErrorFromError(w, r, err)
+		return
+	}
+
+	web.Success(w, http.StatusOK, "reservation found", reservation)
+
+}
+
+// HandlerConfirmReservation convierte la reserva en una venta del producto
+func (ph *productHandler) HandlerConfirmReservation(w http.ResponseWriter, r *http.Request) {
+
+	id, reservationID, err := validateReservationID(w, r)
+	if err != nil {
+		return
+	}
+
+	reservation, err := ph.service.ConfirmReservation(r.Context(), id, reservationID)
+	if err != nil {
+		web.ErrorFromError(w, r, fmt.Errorf("Error al confirmar la reserva: %w", err))
+		return
+	}
+
+	web.Success(w, http.StatusOK, "reservation confirmed", reservation)
+
+}
+
+// HandlerReleaseReservation libera la reserva y devuelve sus existencias a las disponibles
+func (ph *productHandler) HandlerReleaseReservation(w http.ResponseWriter, r *http.Request) {
+
+	id, reservationID, err := validateReservationID(w, r)
+	if err != nil {
+		return
+	}
+
+	reservation, err := ph.service.ReleaseReservation(r.Context(), id, reservationID)
+	if err != nil {
+		web.ErrorFromError(w, r, fmt.Errorf("Error al liberar la reserva: %w", err))
+		return
+	}
+
+	web.Success(w, http.StatusOK, "reservation released", reservation)
+
+}
+
+// validateReservationID obtiene de la URL el ID del producto y el de su reserva
+func validateReservationID(w http.ResponseWriter, r *http.Request) (int, int, error) {
+
+	id, err := validateHeaderID(w, r)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	reservationID, err := strconv.Atoi(chi.URLParam(r, "reservationId"))
+	if err != nil {
+		web.Problem(w, r, http.StatusBadRequest, "El ID de la reserva debe ser un número entero")
+		return 0, 0, err
+	}
+
+	return id, reservationID, nil
+
+}
+
+// parseReservationFilter obtiene el filtro de las reservas de los parámetros de la URL
+func parseReservationFilter(values url.Values) (domain.ReservationFilter, error) {
+
+	var filter domain.ReservationFilter
+
+	status := parseStringParam(values, "status")
+	if status == nil {
+		return filter, nil
+	}
+
+	switch reservationStatus := domain.ReservationStatus(*status); reservationStatus {
+	case domain.ReservationActive, domain.ReservationConfirmed, domain.ReservationReleased, domain.ReservationExpired:
+		filter.Status = &reservationStatus
+	default:
+		return filter, query.NewParamError("status", "el estado de la reserva debe ser active, confirmed, released o expired")
+	}
+
+	return filter, nil
+
+}
+
+func validateHeaderID(w http.ResponseWriter, r *http.Request) (int, error) {
 
 	// Obtener el ID de los parámetros de la URL
 	var idStr string = chi.URLParam(r, "id")
 	if idStr == "" {
-		web.Error(w, http.StatusBadRequest, "El ID del producto es un campo requerido")
+		web.Problem(w, r, http.StatusBadRequest, "El ID del producto es un campo requerido")
 		return 0, errors.New("El ID es un campo requerido")
 	}
 
 	id, err := strconv.Atoi(idStr)
 	if err != nil {
-		web.Error(w, http.StatusBadRequest, "El ID debe ser un número entero")
+		web.Problem(w, r, http.StatusBadRequest, "El ID debe ser un número entero")
 		return 0, err
 	}
 
 	return id, nil
 
 }
+
+// parseProductFilter obtiene el filtro de búsqueda de los parámetros de la URL. priceGt se
+// conserva por compatibilidad y, como antes, incluye el precio indicado. Los precios se
+// expresan en currency o, si no se indicó, en la moneda por defecto.
+func parseProductFilter(values url.Values, currency string) (domain.ProductFilter, error) {
+
+	var filter domain.ProductFilter
+	var err error
+
+	if currency == "" {
+		currency = domain.DefaultCurrency
+	}
+
+	if filter.PriceGte, err = parseMoneyParam(values, "priceGte", currency); err != nil {
+		return filter, err
+	}
+	if filter.PriceGte == nil {
+		if filter.PriceGte, err = parseMoneyParam(values, "priceGt", currency); err != nil {
+			return filter, err
+		}
+	}
+	if filter.PriceLte, err = parseMoneyParam(values, "priceLte", currency); err != nil {
+		return filter, err
+	}
+
+	filter.Text = parseStringParam(values, "q")
+	filter.Name = parseStringParam(values, "name")
+	filter.NamePrefix = parseStringParam(values, "namePrefix")
+	filter.CodeValuePrefix = parseStringParam(values, "codeValuePrefix")
+
+	if isPublishedStr := values.Get("isPublished"); isPublishedStr != "" {
+		isPublished, err := strconv.ParseBool(isPublishedStr)
+		if err != nil {
+			return filter, query.NewParamError("isPublished", "el valor de isPublished debe ser true o false")
+		}
+		filter.IsPublished = &isPublished
+	}
+
+	if filter.ExpirationBefore, err = parseDateParam(values, "expirationBefore"); err != nil {
+		return filter, err
+	}
+	if filter.ExpirationAfter, err = parseDateParam(values, "expirationAfter"); err != nil {
+		return filter, err
+	}
+
+	if filter.QuantityGte, err = parseIntParam(values, "quantityGte"); err != nil {
+		return filter, err
+	}
+	if filter.QuantityLte, err = parseIntParam(values, "quantityLte"); err != nil {
+		return filter, err
+	}
+
+	return filter, nil
+
+}
+
+func parseStringParam(values url.Values, name string) *string {
+
+	value := values.Get(name)
+	if value == "" {
+		return nil
+	}
+
+	return &value
+}
+
+// parseCurrencyParam obtiene la moneda pedida en el parámetro currency, sin distinguir
+// mayúsculas; vacía si no se indicó
+func parseCurrencyParam(values url.Values) (string, error) {
+
+	currency := strings.ToUpper(values.Get("currency"))
+	if currency != "" && !domain.ValidCurrency(currency) {
+		return "", query.NewParamError("currency", "el valor de currency debe ser un código ISO 4217 de tres letras")
+	}
+
+	return currency, nil
+}
+
+// parseMoneyParam interpreta un importe exacto en la moneda indicada
+func parseMoneyParam(values url.Values, name string, currency string) (*domain.Money, error) {
+
+	valueStr := values.Get(name)
+	if valueStr == "" {
+		return nil, nil
+	}
+
+	value, err := domain.ParseMoney(valueStr, currency)
+	if err != nil {
+		return nil, query.NewParamError(name, "el valor de %s debe ser un importe con hasta %d decimales", name, domain.MoneyDecimals)
+	}
+
+	return &value, nil
+}
+
+func parseIntParam(values url.Values, name string) (*int, error) {
+
+	valueStr := values.Get(name)
+	if valueStr == "" {
+		return nil, nil
+	}
+
+	value, err := strconv.Atoi(valueStr)
+	if err != nil {
+		return nil, query.NewParamError(name, "el valor de %s debe ser un número entero", name)
+	}
+
+	return &value, nil
+}
+
+// maxDurationDays es la mayor cantidad de días que cabe en un time.Duration
+const maxDurationDays = math.MaxInt64 / int64(24*time.Hour)
+
+// parseDurationParam obtiene una duración positiva en días, como 7d, o en el formato de Go,
+// como 36h
+func parseDurationParam(values url.Values, name string) (*time.Duration, error) {
+
+	valueStr := values.Get(name)
+	if valueStr == "" {
+		return nil, nil
+	}
+
+	value, err := time.ParseDuration(valueStr)
+	if daysStr, isDays := strings.CutSuffix(valueStr, "d"); isDays {
+		var days int64
+		days, err = strconv.ParseInt(daysStr, 10, 64)
+		if err == nil && days > maxDurationDays {
+			return nil, query.NewParamError(name, "el valor de %s debe ser de hasta %d días", name, maxDurationDays)
+		}
+		// Los días negativos se rechazan abajo; acotarlos evita que la multiplicación desborde
+		value = time.Duration(max(days, 0)) * 24 * time.Hour
+	}
+	if err != nil {
+		return nil, query.NewParamError(name, "el valor de %s debe ser una duración, por ejemplo 7d o 12h", name)
+	}
+
+	if value <= 0 {
+		return nil, query.NewParamError(name, "el valor de %s debe ser una duración positiva", name)
+	}
+
+	return &value, nil
+}
+
+func parseDateParam(values url.Values, name string) (*time.Time, error) {
+
+	valueStr := values.Get(name)
+	if valueStr == "" {
+		return nil, nil
+	}
+
+	value, err := time.Parse("02/01/2006", valueStr)
+	if err != nil {
+		return nil, query.NewParamError(name, "el valor de %s debe ser una fecha con formato dd/mm/aaaa", name)
+	}
+
+	return &value, nil
+}
@@ -0,0 +1,161 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"PRACTICAS-GO-WEB/internal/domain"
+	"PRACTICAS-GO-WEB/internal/query"
+	"PRACTICAS-GO-WEB/internal/service"
+	"PRACTICAS-GO-WEB/pkg/web"
+)
+
+type warehouseHandler struct {
+	service service.WarehouseService
+}
+
+type WarehouseHandler interface {
+	HandlerGetWarehouses(w http.ResponseWriter, r *http.Request)
+	HandlerGetWarehouseByID(w http.ResponseWriter, r *http.Request)
+	HandlerGetWarehouseStock(w http.ResponseWriter, r *http.Request)
+	HandlerCreateWarehouse(w http.ResponseWriter, r *http.Request)
+	HandlerUpdateWarehouse(w http.ResponseWriter, r *http.Request)
+	HandlerDeleteWarehouse(w http.ResponseWriter, r *http.Request)
+}
+
+// warehouseStockFields son los campos por los que se ordenan las existencias de un depósito
+var warehouseStockFields = []string{"id", "name", "code_value"}
+
+// función para crear un nuevo controlador de depósitos
+func NewWarehouseHandler(service service.WarehouseService) WarehouseHandler {
+
+	return &warehouseHandler{service: service}
+
+}
+
+func (wh *warehouseHandler) HandlerGetWarehouses(w http.ResponseWriter, r *http.Request) {
+
+	// Obtener la paginación, el orden y los campos pedidos
+	q, err := query.Parse(r.URL.Query(), domain.WarehouseFields, domain.WarehouseFields)
+	if err != nil {
+		web.ErrorFromError(w, r, err)
+		return
+	}
+
+	page, err := wh.service.GetWarehouses(q)
+	if err != nil {
+		web.ErrorFromError(w, r, err)
+		return
+	}
+
+	writePage(w, r, q, page, "warehouses found")
+
+}
+
+func (wh *warehouseHandler) HandlerGetWarehouseByID(w http.ResponseWriter, r *http.Request) {
+
+	// Obtener el ID de los parámetros de la URL
+	id, err := validateHeaderID(w, r)
+	if err != nil {
+		return
+	}
+
+	warehouse, err := wh.service.GetWarehouseByID(id)
+	if err != nil {
+		web.ErrorFromError(w, r, err)
+		return
+	}
+
+	web.Success(w, http.StatusOK, "warehouse found", warehouse)
+
+}
+
+// HandlerGetWarehouseStock devuelve los productos con existencias en el depósito y la
+// cantidad de cada uno en ese depósito
+func (wh *warehouseHandler) HandlerGetWarehouseStock(w http.ResponseWriter, r *http.Request) {
+
+	// Obtener el ID de los parámetros de la URL
+	id, err := validateHeaderID(w, r)
+	if err != nil {
+		return
+	}
+
+	// Obtener la paginación y el orden pedidos; el orden es el de los productos
+	q, err := query.Parse(r.URL.Query(), warehouseStockFields, nil)
+	if err != nil {
+		web.ErrorFromError(w, r, err)
+		return
+	}
+
+	page, err := wh.service.GetWarehouseStock(id, q)
+	if err != nil {
+		web.ErrorFromError(w, r, err)
+		return
+	}
+
+	writePage(w, r, q, page, "warehouse stock found")
+
+}
+
+func (wh *warehouseHandler) HandlerCreateWarehouse(w http.ResponseWriter, r *http.Request) {
+
+	// Leer el cuerpo de la solicitud
+	var warehouseRequest domain.WarehouseRequest
+	if err := json.NewDecoder(r.Body).Decode(&warehouseRequest); err != nil {
+		web.Problem(w, r, http.StatusBadRequest, "Error al leer el cuerpo de la solicitud")
+		return
+	}
+
+	warehouseCreated, err := wh.service.PostWarehouse(warehouseRequest)
+	if err != nil {
+		web.ErrorFromError(w, r, fmt.Errorf("Error al registrar el nuevo depósito: %w", err))
+		return
+	}
+
+	web.Success(w, http.StatusCreated, "warehouse created", warehouseCreated)
+
+}
+
+func (wh *warehouseHandler) HandlerUpdateWarehouse(w http.ResponseWriter, r *http.Request) {
+
+	// Obtener el ID de los parámetros de la URL
+	id, err := validateHeaderID(w, r)
+	if err != nil {
+		return
+	}
+
+	// Leer el cuerpo de la solicitud
+	var warehouseRequest domain.WarehouseRequest
+	if err := json.NewDecoder(r.Body).Decode(&warehouseRequest); err != nil {
+		web.Problem(w, r, http.StatusBadRequest, fmt.Sprintf("Error al leer el cuerpo de la solicitud: %s", err.Error()))
+		return
+	}
+
+	warehouseUpdated, err := wh.service.PutWarehouse(id, warehouseRequest)
+	if err != nil {
+		web.ErrorFromError(w, r, fmt.Errorf("Error al actualizar el depósito: %w", err))
+		return
+	}
+
+	web.Success(w, http.StatusOK, "warehouse updated", warehouseUpdated)
+
+}
+
+func (wh *warehouseHandler) HandlerDeleteWarehouse(w http.ResponseWriter, r *http.Request) {
+
+	// Obtener el ID de los parámetros de la URL
+	id, err := validateHeaderID(w, r)
+	if err != nil {
+		return
+	}
+
+	if err := wh.service.DeleteWarehouse(id); err != nil {
+		web.ErrorFromError(w, r, fmt.Errorf("Error al eliminar el depósito: %w", err))
+		return
+	}
+
+	// Una respuesta 204 no puede tener cuerpo
+	w.WriteHeader(http.StatusNoContent)
+
+}
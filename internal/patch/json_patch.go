@@ -0,0 +1,398 @@
+package patch
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+const (
+	OperationAdd     = "add"
+	OperationRemove  = "remove"
+	OperationReplace = "replace"
+	OperationMove    = "move"
+	OperationCopy    = "copy"
+	OperationTest    = "test"
+)
+
+// Operation es una operación de un JSON Patch. Value es nil si la operación no lo incluye.
+type Operation struct {
+	Op    string          `json:"op"`
+	Path  *string         `json:"path"`
+	From  *string         `json:"from,omitempty"`
+	Value json.RawMessage `json:"value,omitempty"`
+}
+
+// JSONPatch es un JSON Patch (RFC 6902): una secuencia de operaciones que se aplican en orden
+type JSONPatch struct {
+	operations []jsonPatchOperation
+}
+
+// jsonPatchOperation es una operación validada, con sus rutas ya interpretadas
+type jsonPatchOperation struct {
+	op    string
+	path  []string
+	from  []string
+	value any
+}
+
+// DecodeJSONPatch interpreta el cuerpo de un JSON Patch y valida cada operación
+func DecodeJSONPatch(body []byte) (*JSONPatch, error) {
+
+	var operations []Operation
+	if err := json.Unmarshal(body, &operations); err != nil {
+		return nil, fmt.Errorf("%w: el JSON Patch debe ser un array de operaciones: %s", ErrInvalidPatch, err.Error())
+	}
+
+	jsonPatch := &JSONPatch{operations: make([]jsonPatchOperation, len(operations))}
+
+	for i, operation := range operations {
+		parsed, err := parseOperation(operation)
+		if err != nil {
+			return nil, fmt.Errorf("%w: la operación %d: %s", ErrInvalidPatch, i, err.Error())
+		}
+		jsonPatch.operations[i] = parsed
+	}
+
+	return jsonPatch, nil
+}
+
+func parseOperation(operation Operation) (jsonPatchOperation, error) {
+
+	parsed := jsonPatchOperation{op: operation.Op}
+
+	switch operation.Op {
+	case OperationAdd, OperationRemove, OperationReplace, OperationMove, OperationCopy, OperationTest:
+	case "":
+		return parsed, fmt.Errorf("el campo op es requerido")
+	default:
+		return parsed, fmt.Errorf("la operación %q no es válida", operation.Op)
+	}
+
+	if operation.Path == nil {
+		return parsed, fmt.Errorf("el campo path es requerido")
+	}
+	path, err := parsePointer(*operation.Path)
+	if err != nil {
+		return parsed, err
+	}
+	parsed.path = path
+
+	switch operation.Op {
+	case OperationMove, OperationCopy:
+		if operation.From == nil {
+			return parsed, fmt.Errorf("el campo from es requerido en la operación %s", operation.Op)
+		}
+		from, err := parsePointer(*operation.From)
+		if err != nil {
+			return parsed, err
+		}
+		if operation.Op == OperationMove && len(from) < len(path) && slices.Equal(from, path[:len(from)]) {
+			return parsed, fmt.Errorf("no se puede mover %q dentro de sí mismo", *operation.From)
+		}
+		parsed.from = from
+	case OperationAdd, OperationReplace, OperationTest:
+		if operation.Value == nil {
+			return parsed, fmt.Errorf("el campo value es requerido en la operación %s", operation.Op)
+		}
+		value, err := decodeValue(operation.Value)
+		if err != nil {
+			return parsed, fmt.Errorf("el campo value no es válido: %s", err.Error())
+		}
+		parsed.value = value
+	}
+
+	return parsed, nil
+}
+
+// parsePointer interpreta un JSON Pointer (RFC 6901). La ruta vacía es el documento completo.
+func parsePointer(pointer string) ([]string, error) {
+
+	if pointer == "" {
+		return []string{}, nil
+	}
+
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, fmt.Errorf("la ruta %q debe comenzar con /", pointer)
+	}
+
+	tokens := strings.Split(pointer[1:], "/")
+	for i, token := range tokens {
+		// ~ solo puede aparecer en las secuencias de escape ~0 y ~1
+		for j := 0; j < len(token); j++ {
+			if token[j] == '~' && (j+1 == len(token) || (token[j+1] != '0' && token[j+1] != '1')) {
+				return nil, fmt.Errorf("la ruta %q contiene un escape inválido", pointer)
+			}
+		}
+		tokens[i] = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+	}
+
+	return tokens, nil
+}
+
+// formatPointer arma el JSON Pointer de una ruta para los mensajes de error
+func formatPointer(tokens []string) string {
+
+	var pointer strings.Builder
+	for _, token := range tokens {
+		pointer.WriteString("/")
+		pointer.WriteString(strings.ReplaceAll(strings.ReplaceAll(token, "~", "~0"), "/", "~1"))
+	}
+
+	return pointer.String()
+}
+
+func (jp *JSONPatch) Apply(document []byte) ([]byte, error) {
+
+	target, err := decodeValue(document)
+	if err != nil {
+		return nil, fmt.Errorf("Error al leer el documento a modificar: %w", err)
+	}
+
+	// Las operaciones se aplican sobre una copia propia del documento: si alguna falla,
+	// se descarta el resultado completo
+	for i, operation := range jp.operations {
+		target, err = operation.apply(target)
+		if err != nil {
+			return nil, fmt.Errorf("%w: la operación %d (%s): %s", ErrConflict, i, operation.op, err.Error())
+		}
+	}
+
+	return json.Marshal(target)
+}
+
+func (operation jsonPatchOperation) apply(document any) (any, error) {
+
+	switch operation.op {
+	case OperationAdd:
+		return addValue(document, operation.path, deepCopy(operation.value))
+	case OperationRemove:
+		document, _, err := removeValue(document, operation.path)
+		return document, err
+	case OperationReplace:
+		if _, err := getValue(document, operation.path); err != nil {
+			return nil, err
+		}
+		return setValue(document, operation.path, deepCopy(operation.value))
+	case OperationMove:
+		if slices.Equal(operation.from, operation.path) {
+			_, err := getValue(document, operation.from)
+			return document, err
+		}
+		document, value, err := removeValue(document, operation.from)
+		if err != nil {
+			return nil, err
+		}
+		return addValue(document, operation.path, value)
+	case OperationCopy:
+		value, err := getValue(document, operation.from)
+		if err != nil {
+			return nil, err
+		}
+		return addValue(document, operation.path, deepCopy(value))
+	case OperationTest:
+		value, err := getValue(document, operation.path)
+		if err != nil {
+			return nil, err
+		}
+		if !equalValues(value, operation.value) {
+			return nil, fmt.Errorf("el valor de %q no coincide con el esperado", formatPointer(operation.path))
+		}
+		return document, nil
+	}
+
+	return nil, fmt.Errorf("la operación %q no es válida", operation.op)
+}
+
+// getValue devuelve el valor ubicado en la ruta
+func getValue(document any, path []string) (any, error) {
+
+	current := document
+	for i, token := range path {
+		switch container := current.(type) {
+		case map[string]any:
+			value, exists := container[token]
+			if !exists {
+				return nil, fmt.Errorf("la ruta %q no existe", formatPointer(path[:i+1]))
+			}
+			current = value
+		case []any:
+			index, err := arrayIndex(token, len(container)-1)
+			if err != nil {
+				return nil, fmt.Errorf("la ruta %q no existe: %s", formatPointer(path[:i+1]), err.Error())
+			}
+			current = container[index]
+		default:
+			return nil, fmt.Errorf("la ruta %q no existe", formatPointer(path[:i+1]))
+		}
+	}
+
+	return current, nil
+}
+
+// setValue reemplaza el valor de la ruta, que debe existir o ser un nuevo miembro de un objeto
+func setValue(document any, path []string, value any) (any, error) {
+
+	if len(path) == 0 {
+		return value, nil
+	}
+
+	parent, err := getValue(document, path[:len(path)-1])
+	if err != nil {
+		return nil, err
+	}
+
+	last := path[len(path)-1]
+	switch container := parent.(type) {
+	case map[string]any:
+		container[last] = value
+	case []any:
+		index, err := arrayIndex(last, len(container)-1)
+		if err != nil {
+			return nil, fmt.Errorf("la ruta %q no existe: %s", formatPointer(path), err.Error())
+		}
+		container[index] = value
+	default:
+		return nil, fmt.Errorf("la ruta %q no existe", formatPointer(path[:len(path)-1]))
+	}
+
+	return document, nil
+}
+
+// addValue agrega un miembro a un objeto o inserta un elemento en un array ("-" lo agrega al final)
+func addValue(document any, path []string, value any) (any, error) {
+
+	if len(path) == 0 {
+		return value, nil
+	}
+
+	parent, err := getValue(document, path[:len(path)-1])
+	if err != nil {
+		return nil, err
+	}
+
+	last := path[len(path)-1]
+	array, ok := parent.([]any)
+	if !ok {
+		return setValue(document, path, value)
+	}
+
+	index := len(array)
+	if last != "-" {
+		if index, err = arrayIndex(last, len(array)); err != nil {
+			return nil, fmt.Errorf("la ruta %q no es válida: %s", formatPointer(path), err.Error())
+		}
+	}
+
+	// El array crece, por lo que se reemplaza en su contenedor
+	return setValue(document, path[:len(path)-1], slices.Insert(slices.Clone(array), index, value))
+}
+
+// removeValue elimina el valor de la ruta y lo devuelve
+func removeValue(document any, path []string) (any, any, error) {
+
+	if len(path) == 0 {
+		return nil, nil, fmt.Errorf("no se puede eliminar el documento completo")
+	}
+
+	value, err := getValue(document, path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	parent, _ := getValue(document, path[:len(path)-1])
+	last := path[len(path)-1]
+
+	switch container := parent.(type) {
+	case map[string]any:
+		delete(container, last)
+		return document, value, nil
+	case []any:
+		index, _ := arrayIndex(last, len(container)-1)
+		document, err = setValue(document, path[:len(path)-1], slices.Delete(slices.Clone(container), index, index+1))
+		return document, value, err
+	}
+
+	return nil, nil, fmt.Errorf("la ruta %q no existe", formatPointer(path))
+}
+
+// arrayIndex interpreta el índice de un array, que no puede superar maxIndex
+func arrayIndex(token string, maxIndex int) (int, error) {
+
+	if token == "" || (len(token) > 1 && token[0] == '0') || strings.TrimLeft(token, "0123456789") != "" {
+		return 0, fmt.Errorf("%q no es un índice de array válido", token)
+	}
+
+	index, err := strconv.Atoi(token)
+	if err != nil || index > maxIndex {
+		return 0, fmt.Errorf("el índice %s está fuera de rango", token)
+	}
+
+	return index, nil
+}
+
+// deepCopy copia objetos y arrays para que ninguna operación comparta memoria con otra
+func deepCopy(value any) any {
+
+	switch typed := value.(type) {
+	case map[string]any:
+		copied := make(map[string]any, len(typed))
+		for name, member := range typed {
+			copied[name] = deepCopy(member)
+		}
+		return copied
+	case []any:
+		copied := make([]any, len(typed))
+		for i, element := range typed {
+			copied[i] = deepCopy(element)
+		}
+		return copied
+	default:
+		return value
+	}
+
+}
+
+// equalValues compara dos valores JSON; los números se comparan por su valor, por lo que 1 y 1.0 son iguales
+func equalValues(a, b any) bool {
+
+	switch typedA := a.(type) {
+	case map[string]any:
+		typedB, ok := b.(map[string]any)
+		if !ok || len(typedA) != len(typedB) {
+			return false
+		}
+		for name, member := range typedA {
+			other, exists := typedB[name]
+			if !exists || !equalValues(member, other) {
+				return false
+			}
+		}
+		return true
+	case []any:
+		typedB, ok := b.([]any)
+		if !ok || len(typedA) != len(typedB) {
+			return false
+		}
+		for i := range typedA {
+			if !equalValues(typedA[i], typedB[i]) {
+				return false
+			}
+		}
+		return true
+	case json.Number:
+		typedB, ok := b.(json.Number)
+		if !ok {
+			return false
+		}
+		ratA, okA := new(big.Rat).SetString(typedA.String())
+		ratB, okB := new(big.Rat).SetString(typedB.String())
+		return okA && okB && ratA.Cmp(ratB) == 0
+	default:
+		return a == b
+	}
+
+}
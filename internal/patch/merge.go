@@ -0,0 +1,58 @@
+package patch
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// MergePatch es un JSON Merge Patch (RFC 7396): los miembros del parche reemplazan a los
+// del documento, los objetos se combinan recursivamente y null elimina el miembro.
+type MergePatch struct {
+	value any
+}
+
+// DecodeMergePatch interpreta el cuerpo de un JSON Merge Patch
+func DecodeMergePatch(body []byte) (*MergePatch, error) {
+
+	value, err := decodeValue(body)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidPatch, err.Error())
+	}
+
+	return &MergePatch{value: value}, nil
+}
+
+func (mp *MergePatch) Apply(document []byte) ([]byte, error) {
+
+	target, err := decodeValue(document)
+	if err != nil {
+		return nil, fmt.Errorf("Error al leer el documento a modificar: %w", err)
+	}
+
+	return json.Marshal(mergeValue(target, mp.value))
+}
+
+// mergeValue aplica el algoritmo MergePatch de la RFC 7396. El destino puede modificarse,
+// por lo que debe ser una copia propia.
+func mergeValue(target any, patch any) any {
+
+	patchObject, ok := patch.(map[string]any)
+	if !ok {
+		return patch
+	}
+
+	targetObject, ok := target.(map[string]any)
+	if !ok {
+		targetObject = make(map[string]any, len(patchObject))
+	}
+
+	for name, value := range patchObject {
+		if value == nil {
+			delete(targetObject, name)
+			continue
+		}
+		targetObject[name] = mergeValue(targetObject[name], value)
+	}
+
+	return targetObject
+}
@@ -0,0 +1,80 @@
+package patch
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+)
+
+var (
+	// ErrInvalidPatch indica que el documento de parche está mal formado
+	ErrInvalidPatch = errors.New("Documento de parche inválido")
+	// ErrConflict indica que el parche no puede aplicarse al estado actual del recurso,
+	// por ejemplo porque una ruta no existe o una operación test no se cumple
+	ErrConflict = errors.New("El parche no puede aplicarse al recurso")
+	// ErrUnsupportedMediaType indica que el tipo de contenido no corresponde a un formato de parche
+	ErrUnsupportedMediaType = errors.New("Formato de parche no soportado")
+)
+
+const (
+	// MediaTypeMergePatch es el tipo de contenido de un JSON Merge Patch (RFC 7396)
+	MediaTypeMergePatch = "application/merge-patch+json"
+	// MediaTypeJSONPatch es el tipo de contenido de un JSON Patch (RFC 6902)
+	MediaTypeJSONPatch = "application/json-patch+json"
+	// MediaTypeJSON se interpreta como un JSON Merge Patch
+	MediaTypeJSON = "application/json"
+)
+
+// AcceptPatch es el valor del encabezado Accept-Patch con los formatos soportados
+const AcceptPatch = MediaTypeMergePatch + ", " + MediaTypeJSONPatch
+
+// Patch es un documento de parche que transforma un documento JSON en otro. Apply no
+// modifica el documento recibido: si alguna operación falla no se aplica ninguna.
+type Patch interface {
+	Apply(document []byte) ([]byte, error)
+}
+
+// Decode interpreta el cuerpo de la solicitud según su tipo de contenido. Sin tipo de
+// contenido, o con application/json, el cuerpo se interpreta como un JSON Merge Patch.
+func Decode(contentType string, body []byte) (Patch, error) {
+
+	mediaType := MediaTypeJSON
+	if contentType != "" {
+		var err error
+		mediaType, _, err = mime.ParseMediaType(contentType)
+		if err != nil {
+			return nil, fmt.Errorf("%w: el tipo de contenido %q no es válido", ErrUnsupportedMediaType, contentType)
+		}
+	}
+
+	switch mediaType {
+	case MediaTypeMergePatch, MediaTypeJSON:
+		return DecodeMergePatch(body)
+	case MediaTypeJSONPatch:
+		return DecodeJSONPatch(body)
+	default:
+		return nil, fmt.Errorf("%w: el tipo de contenido %q no es un formato de parche, se admite %s", ErrUnsupportedMediaType, mediaType, AcceptPatch)
+	}
+
+}
+
+// decodeValue deserializa un valor JSON conservando los números tal como fueron escritos
+func decodeValue(content []byte) (any, error) {
+
+	decoder := json.NewDecoder(bytes.NewReader(content))
+	decoder.UseNumber()
+
+	var value any
+	if err := decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+
+	// No se admite contenido luego del primer valor
+	if decoder.More() {
+		return nil, errors.New("hay contenido luego del documento JSON")
+	}
+
+	return value, nil
+}
@@ -0,0 +1,314 @@
+package query
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// ErrInvalidQuery indica que los parámetros de la consulta no son válidos
+var ErrInvalidQuery = errors.New("Consulta inválida")
+
+// ParamError es un parámetro inválido de la consulta; se compara con errors.Is como
+// ErrInvalidQuery e identifica el parámetro para informarlo como error de campo
+type ParamError struct {
+	Param  string
+	Reason string
+}
+
+func (e *ParamError) Error() string {
+	return fmt.Sprintf("%s: %s", ErrInvalidQuery.Error(), e.Reason)
+}
+
+func (e *ParamError) Unwrap() error {
+	return ErrInvalidQuery
+}
+
+// NewParamError crea el error del parámetro indicado con el motivo formateado
+func NewParamError(param string, format string, args ...any) error {
+	return &ParamError{Param: param, Reason: fmt.Sprintf(format, args...)}
+}
+
+// MaxLimit es la cantidad máxima de elementos que se devuelven por página
+const MaxLimit = 1000
+
+// SortKey es un criterio de ordenamiento; el prefijo "-" en la consulta lo hace descendente
+type SortKey struct {
+	Field      string
+	Descending bool
+}
+
+// Query describe qué página de una colección se pide, en qué orden y con qué campos.
+// Limit igual a 0 devuelve todos los elementos desde la posición pedida.
+type Query struct {
+	Limit  int
+	Offset int
+	Cursor string
+	Sort   []SortKey
+	Fields []string
+}
+
+// Page es una página del resultado de una consulta
+type Page[T any] struct {
+	Items  []T
+	Total  int
+	Offset int
+	Limit  int
+	// NextCursor y PrevCursor están vacíos cuando no hay página siguiente o anterior
+	NextCursor string
+	PrevCursor string
+	// NextOffset y PrevOffset son -1 cuando no hay página siguiente o anterior
+	NextOffset int
+	PrevOffset int
+}
+
+// cursor es el contenido de un cursor opaco: la posición se expresa con el ID del elemento
+// de referencia, de modo que las altas y bajas anteriores no desplazan la página
+type cursor struct {
+	After  *int   `json:"a,omitempty"`
+	Before *int   `json:"b,omitempty"`
+	Sort   string `json:"s,omitempty"`
+}
+
+// Comparator compara dos elementos según un campo
+type Comparator[T any] func(a, b T) int
+
+// Parse obtiene la consulta de los parámetros limit, offset, cursor, sort y fields.
+// sortable y selectable son los nombres de campo admitidos para ordenar y seleccionar.
+func Parse(values url.Values, sortable []string, selectable []string) (Query, error) {
+
+	var q Query
+
+	if limitStr := values.Get("limit"); limitStr != "" {
+		limit, err := strconv.Atoi(limitStr)
+		if err != nil || limit < 1 || limit > MaxLimit {
+			return Query{}, NewParamError("limit", "el valor de limit debe ser un número entre 1 y %d", MaxLimit)
+		}
+		q.Limit = limit
+	}
+
+	if offsetStr := values.Get("offset"); offsetStr != "" {
+		offset, err := strconv.Atoi(offsetStr)
+		if err != nil || offset < 0 {
+			return Query{}, NewParamError("offset", "el valor de offset debe ser un número entero positivo")
+		}
+		q.Offset = offset
+	}
+
+	q.Cursor = values.Get("cursor")
+	if q.Cursor != "" && q.Offset != 0 {
+		return Query{}, NewParamError("cursor", "no se pueden combinar cursor y offset")
+	}
+
+	if sortStr := values.Get("sort"); sortStr != "" {
+		for _, field := range strings.Split(sortStr, ",") {
+			field = strings.TrimSpace(field)
+			key := SortKey{Field: strings.TrimPrefix(field, "-"), Descending: strings.HasPrefix(field, "-")}
+			if !slices.Contains(sortable, key.Field) {
+				return Query{}, NewParamError("sort", "no se puede ordenar por el campo %q", key.Field)
+			}
+			if slices.ContainsFunc(q.Sort, func(k SortKey) bool { return k.Field == key.Field }) {
+				return Query{}, NewParamError("sort", "el campo %q está repetido en sort", key.Field)
+			}
+			q.Sort = append(q.Sort, key)
+		}
+	}
+
+	if fieldsStr := values.Get("fields"); fieldsStr != "" {
+		for _, field := range strings.Split(fieldsStr, ",") {
+			field = strings.TrimSpace(field)
+			if !slices.Contains(selectable, field) {
+				return Query{}, NewParamError("fields", "el campo %q no existe", field)
+			}
+			if !slices.Contains(q.Fields, field) {
+				q.Fields = append(q.Fields, field)
+			}
+		}
+	}
+
+	return q, nil
+}
+
+// SortString devuelve el ordenamiento en el formato del parámetro sort
+func (q Query) SortString() string {
+
+	fields := make([]string, len(q.Sort))
+	for i, key := range q.Sort {
+		fields[i] = key.Field
+		if key.Descending {
+			fields[i] = "-" + key.Field
+		}
+	}
+
+	return strings.Join(fields, ",")
+}
+
+// SortFunc ordena los elementos según los criterios de la consulta. Los empates se
+// resuelven por ID para que el orden sea estable entre páginas.
+func SortFunc[T any](items []T, keys []SortKey, comparators map[string]Comparator[T], idOf func(T) int) {
+
+	slices.SortStableFunc(items, func(a, b T) int {
+		for _, key := range keys {
+			compare := comparators[key.Field]
+			if compare == nil {
+				continue
+			}
+			result := compare(a, b)
+			if key.Descending {
+				result = -result
+			}
+			if result != 0 {
+				return result
+			}
+		}
+		if len(keys) == 0 {
+			return 0
+		}
+		return idOf(a) - idOf(b)
+	})
+
+}
+
+// Paginate devuelve la página pedida de items, que ya deben estar ordenados
+func Paginate[T any](items []T, q Query, idOf func(T) int) (Page[T], error) {
+
+	page := Page[T]{Total: len(items), Limit: q.Limit, NextOffset: -1, PrevOffset: -1}
+
+	start, end := q.Offset, len(items)
+
+	if q.Cursor != "" {
+		c, err := decodeCursor(q.Cursor)
+		if err != nil {
+			return Page[T]{}, err
+		}
+		if c.Sort != q.SortString() {
+			return Page[T]{}, NewParamError("cursor", "el cursor corresponde a otro ordenamiento")
+		}
+
+		reference := 0
+		if c.After != nil {
+			reference = *c.After
+		} else {
+			reference = *c.Before
+		}
+		position := slices.IndexFunc(items, func(item T) bool { return idOf(item) == reference })
+		if position == -1 {
+			return Page[T]{}, NewParamError("cursor", "el elemento de referencia del cursor ya no existe")
+		}
+
+		if c.After != nil {
+			start = position + 1
+		} else {
+			// La página anterior termina justo antes del elemento de referencia
+			end = position
+			start = 0
+			if q.Limit > 0 {
+				start = max(0, end-q.Limit)
+			}
+		}
+	}
+
+	start = min(start, len(items))
+	if q.Limit > 0 {
+		end = min(end, start+q.Limit)
+	}
+
+	page.Items = items[start:end]
+	page.Offset = start
+
+	if end < len(items) {
+		page.NextOffset = end
+		if len(page.Items) > 0 {
+			page.NextCursor = encodeCursor(cursor{After: ptr(idOf(items[end-1])), Sort: q.SortString()})
+		}
+	}
+
+	if start > 0 {
+		page.PrevOffset = 0
+		if q.Limit > 0 {
+			page.PrevOffset = max(0, start-q.Limit)
+		}
+		if start < len(items) {
+			page.PrevCursor = encodeCursor(cursor{Before: ptr(idOf(items[start])), Sort: q.SortString()})
+		}
+	}
+
+	return page, nil
+}
+
+// WithItems devuelve una página con los mismos datos de paginación y otros elementos
+func WithItems[T any, U any](page Page[T], items []U) Page[U] {
+	return Page[U]{
+		Items:      items,
+		Total:      page.Total,
+		Offset:     page.Offset,
+		Limit:      page.Limit,
+		NextCursor: page.NextCursor,
+		PrevCursor: page.PrevCursor,
+		NextOffset: page.NextOffset,
+		PrevOffset: page.PrevOffset,
+	}
+}
+
+// Project devuelve cada elemento como un objeto JSON que solo contiene los campos indicados.
+// Sin campos, los elementos se devuelven sin cambios.
+func Project[T any](items []T, fields []string) ([]any, error) {
+
+	projected := make([]any, len(items))
+
+	for i, item := range items {
+		if len(fields) == 0 {
+			projected[i] = item
+			continue
+		}
+
+		content, err := json.Marshal(item)
+		if err != nil {
+			return nil, err
+		}
+
+		var object map[string]json.RawMessage
+		if err := json.Unmarshal(content, &object); err != nil {
+			return nil, err
+		}
+
+		selected := make(map[string]json.RawMessage, len(fields))
+		for _, field := range fields {
+			if value, ok := object[field]; ok {
+				selected[field] = value
+			}
+		}
+		projected[i] = selected
+	}
+
+	return projected, nil
+}
+
+func encodeCursor(c cursor) string {
+	content, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(content)
+}
+
+func decodeCursor(value string) (cursor, error) {
+
+	content, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return cursor{}, NewParamError("cursor", "el cursor no es válido")
+	}
+
+	var c cursor
+	if err := json.Unmarshal(content, &c); err != nil || (c.After == nil) == (c.Before == nil) {
+		return cursor{}, NewParamError("cursor", "el cursor no es válido")
+	}
+
+	return c, nil
+}
+
+func ptr[T any](value T) *T {
+	return &value
+}
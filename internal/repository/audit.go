@@ -0,0 +1,84 @@
+package repository
+
+import (
+	"PRACTICAS-GO-WEB/internal/domain"
+	"PRACTICAS-GO-WEB/internal/query"
+	"PRACTICAS-GO-WEB/internal/storage"
+	"slices"
+	"sync"
+)
+
+type AuditRepository interface {
+	Append(entry domain.AuditEntry) (domain.AuditEntry, error)
+	Find(filter domain.AuditFilter, q query.Query) (query.Page[domain.AuditEntry], error)
+	LastProductID() int
+}
+
+// auditRepository conserva en memoria las entradas del registro de auditoría, que solo
+// admite agregar entradas. Es seguro para uso concurrente.
+type auditRepository struct {
+	mu      sync.RWMutex
+	storage storage.AppendLog
+	entries []domain.AuditEntry
+}
+
+func NewAuditRepository(storage storage.AppendLog) (*auditRepository, error) {
+
+	var entries []domain.AuditEntry
+	if err := storage.Read(&entries); err != nil {
+		return nil, domain.NewError(domain.ErrStorage, "Error al recuperar el registro de auditoría: %s", err.Error())
+	}
+
+	return &auditRepository{storage: storage, entries: entries}, nil
+}
+
+// Append asigna el ID a la entrada y la agrega al registro
+func (ar *auditRepository) Append(entry domain.AuditEntry) (domain.AuditEntry, error) {
+
+	ar.mu.Lock()
+	defer ar.mu.Unlock()
+
+	entry.ID = 1
+	if n := len(ar.entries); n > 0 {
+		entry.ID = ar.entries[n-1].ID + 1
+	}
+
+	if err := ar.storage.Append(entry); err != nil {
+		return domain.AuditEntry{}, domain.NewError(domain.ErrStorage, "Error al registrar la auditoría: %s", err.Error())
+	}
+
+	ar.entries = append(ar.entries, entry)
+
+	return entry, nil
+}
+
+// Find devuelve la página pedida de las entradas que cumplen el filtro, en orden cronológico
+func (ar *auditRepository) Find(filter domain.AuditFilter, q query.Query) (query.Page[domain.AuditEntry], error) {
+
+	ar.mu.RLock()
+	var entries []domain.AuditEntry
+	for _, entry := range ar.entries {
+		if filter.Matches(entry) {
+			entry.Changes = slices.Clone(entry.Changes)
+			entries = append(entries, entry)
+		}
+	}
+	ar.mu.RUnlock()
+
+	return query.Paginate(entries, q, domain.AuditEntryID)
+}
+
+// LastProductID devuelve el mayor ID de producto registrado en la auditoría, incluidos los de
+// productos ya purgados
+func (ar *auditRepository) LastProductID() int {
+
+	ar.mu.RLock()
+	defer ar.mu.RUnlock()
+
+	lastID := 0
+	for _, entry := range ar.entries {
+		lastID = max(lastID, entry.ProductID)
+	}
+
+	return lastID
+}
@@ -0,0 +1,265 @@
+package repository
+
+import (
+	"PRACTICAS-GO-WEB/internal/domain"
+	"PRACTICAS-GO-WEB/internal/query"
+	"PRACTICAS-GO-WEB/internal/storage"
+	"slices"
+	"strings"
+	"sync"
+)
+
+type CategoryRepository interface {
+	Get(id int) (domain.Category, error)
+	GetAll() ([]domain.Category, error)
+	Find(q query.Query) (query.Page[domain.Category], error)
+	Descendants(id int) ([]int, error)
+	Create(category domain.Category) (domain.Category, error)
+	Update(category domain.Category) (domain.Category, error)
+	Delete(id int, force bool) (domain.Category, error)
+}
+
+// categoryRepository conserva el árbol de categorías en memoria y lo reescribe completo en
+// cada modificación. Es seguro para uso concurrente.
+type categoryRepository struct {
+	mu         sync.RWMutex
+	storage    storage.Storage
+	categories []domain.Category
+	lastID     int
+}
+
+func NewCategoryRepository(storage storage.Storage) (*categoryRepository, error) {
+
+	var categoriesStorage []domain.CategoryStorage
+	if err := storage.Read(&categoriesStorage); err != nil {
+		return nil, domain.NewError(domain.ErrStorage, "Error al recuperar las categorías almacenadas: %s", err.Error())
+	}
+
+	categories := domain.CategoriesFromCategoriesStorage(categoriesStorage)
+
+	repository := &categoryRepository{storage: storage, categories: categories}
+	seen := make(map[int]bool, len(categories))
+	for _, category := range categories {
+		if seen[category.ID] {
+			return nil, domain.NewError(domain.ErrStorage, "Error al recuperar las categorías almacenadas: el ID %d está repetido", category.ID)
+		}
+		seen[category.ID] = true
+		repository.lastID = max(repository.lastID, category.ID)
+	}
+
+	return repository, nil
+}
+
+// index devuelve la posición de la categoría. Debe llamarse con el lock tomado.
+func (cr *categoryRepository) index(id int) (int, bool) {
+
+	index := slices.IndexFunc(cr.categories, func(category domain.Category) bool {
+		return category.ID == id
+	})
+
+	return index, index >= 0
+}
+
+func (cr *categoryRepository) notFound(id int) error {
+	return domain.NewError(domain.ErrNotFound, "No se encontró la categoría con el ID %d", id)
+}
+
+func (cr *categoryRepository) Get(id int) (domain.Category, error) {
+
+	cr.mu.RLock()
+	defer cr.mu.RUnlock()
+
+	index, exists := cr.index(id)
+	if !exists {
+		return domain.Category{}, cr.notFound(id)
+	}
+
+	return cr.categories[index].Clone(), nil
+}
+
+func (cr *categoryRepository) GetAll() ([]domain.Category, error) {
+
+	cr.mu.RLock()
+	defer cr.mu.RUnlock()
+
+	categories := make([]domain.Category, len(cr.categories))
+	for i, category := range cr.categories {
+		categories[i] = category.Clone()
+	}
+
+	return categories, nil
+}
+
+// Find devuelve la página pedida de categorías, ordenada según la consulta
+func (cr *categoryRepository) Find(q query.Query) (query.Page[domain.Category], error) {
+
+	categories, _ := cr.GetAll()
+
+	query.SortFunc(categories, q.Sort, domain.CategoryComparators, domain.CategoryID)
+
+	return query.Paginate(categories, q, domain.CategoryID)
+}
+
+// Descendants devuelve el ID de la categoría y los de todas sus subcategorías
+func (cr *categoryRepository) Descendants(id int) ([]int, error) {
+
+	cr.mu.RLock()
+	defer cr.mu.RUnlock()
+
+	if _, exists := cr.index(id); !exists {
+		return nil, cr.notFound(id)
+	}
+
+	ids := []int{id}
+	for next := 0; next < len(ids); next++ {
+		for _, category := range cr.categories {
+			if category.ParentID != nil && *category.ParentID == ids[next] {
+				ids = append(ids, category.ID)
+			}
+		}
+	}
+
+	return ids, nil
+}
+
+// checkParent verifica que la categoría padre exista, que no genere un ciclo y que no haya
+// otra categoría con el mismo nombre bajo el mismo padre. Debe llamarse con el lock tomado.
+func (cr *categoryRepository) checkParent(category domain.Category) error {
+
+	if category.ParentID != nil {
+		if _, exists := cr.index(*category.ParentID); !exists {
+			var validation domain.ValidationError
+			validation.Add("parent_id", "No existe la categoría padre indicada")
+			return validation.Err()
+		}
+
+		// Subir desde el padre hasta la raíz sin volver a pasar por la categoría
+		for parentID := category.ParentID; parentID != nil; {
+			if *parentID == category.ID {
+				var validation domain.ValidationError
+				validation.Add("parent_id", "La categoría no puede ser subcategoría de sí misma ni de sus subcategorías")
+				return validation.Err()
+			}
+			index, _ := cr.index(*parentID)
+			parentID = cr.categories[index].ParentID
+		}
+	}
+
+	for _, other := range cr.categories {
+		if other.ID != category.ID && sameParent(other.ParentID, category.ParentID) && strings.EqualFold(other.Name, category.Name) {
+			return domain.NewError(domain.ErrConflict, "Ya existe la categoría %s en el mismo nivel", category.Name)
+		}
+	}
+
+	return nil
+}
+
+func sameParent(a *int, b *int) bool {
+	return (a == nil && b == nil) || (a != nil && b != nil && *a == *b)
+}
+
+// saveAll persiste todas las categorías. Debe llamarse con el lock de escritura tomado.
+func (cr *categoryRepository) saveAll() error {
+
+	if err := cr.storage.Write(domain.CategoriesStorageFromCategories(cr.categories)); err != nil {
+		return domain.NewError(domain.ErrStorage, "Error al almacenar las categorías: %s", err.Error())
+	}
+
+	return nil
+}
+
+func (cr *categoryRepository) Create(category domain.Category) (domain.Category, error) {
+
+	cr.mu.Lock()
+	defer cr.mu.Unlock()
+
+	category = category.Clone()
+	category.ID = cr.lastID + 1
+
+	if err := cr.checkParent(category); err != nil {
+		return domain.Category{}, err
+	}
+
+	cr.categories = append(cr.categories, category)
+	if err := cr.saveAll(); err != nil {
+		// Revertir el alta en memoria para que no diverja de lo almacenado
+		cr.categories = cr.categories[:len(cr.categories)-1]
+		return domain.Category{}, err
+	}
+
+	cr.lastID = category.ID
+
+	return category.Clone(), nil
+}
+
+func (cr *categoryRepository) Update(category domain.Category) (domain.Category, error) {
+
+	cr.mu.Lock()
+	defer cr.mu.Unlock()
+
+	index, exists := cr.index(category.ID)
+	if !exists {
+		return domain.Category{}, cr.notFound(category.ID)
+	}
+
+	category = category.Clone()
+	if err := cr.checkParent(category); err != nil {
+		return domain.Category{}, err
+	}
+
+	previous := cr.categories[index]
+	cr.categories[index] = category
+	if err := cr.saveAll(); err != nil {
+		cr.categories[index] = previous
+		return domain.Category{}, err
+	}
+
+	return category.Clone(), nil
+}
+
+// Delete elimina la categoría. Sin force, una categoría con subcategorías no se elimina; con
+// force, las subcategorías pasan a depender del padre de la categoría eliminada.
+func (cr *categoryRepository) Delete(id int, force bool) (domain.Category, error) {
+
+	cr.mu.Lock()
+	defer cr.mu.Unlock()
+
+	index, exists := cr.index(id)
+	if !exists {
+		return domain.Category{}, cr.notFound(id)
+	}
+
+	deleted := cr.categories[index]
+	previous := slices.Clone(cr.categories)
+
+	categories := slices.Delete(slices.Clone(cr.categories), index, index+1)
+	for i, category := range categories {
+		if category.ParentID == nil || *category.ParentID != id {
+			continue
+		}
+		if !force {
+			return domain.Category{}, domain.NewError(domain.ErrConflict, "La categoría %d tiene subcategorías; use force para eliminarla igualmente", id)
+		}
+		category = category.Clone()
+		category.ParentID = deleted.Clone().ParentID
+		categories[i] = category
+	}
+
+	// Las subcategorías movidas no pueden repetir el nombre de otra del nuevo nivel
+	cr.categories = categories
+	for _, category := range categories {
+		if sameParent(category.ParentID, deleted.ParentID) {
+			if err := cr.checkParent(category); err != nil {
+				cr.categories = previous
+				return domain.Category{}, err
+			}
+		}
+	}
+
+	if err := cr.saveAll(); err != nil {
+		cr.categories = previous
+		return domain.Category{}, err
+	}
+
+	return deleted.Clone(), nil
+}
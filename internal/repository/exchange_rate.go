@@ -0,0 +1,71 @@
+package repository
+
+import (
+	"PRACTICAS-GO-WEB/internal/domain"
+	"PRACTICAS-GO-WEB/internal/storage"
+	"reflect"
+	"sync"
+	"time"
+)
+
+type ExchangeRateRepository interface {
+	Get() (domain.ExchangeRates, error)
+	Reload() (domain.ExchangeRates, bool, error)
+}
+
+// exchangeRateRepository conserva en memoria la tabla de cotizaciones leída del archivo. La
+// tabla se reemplaza completa al recargarla y nunca se modifica, por lo que puede compartirse
+// entre lecturas. Es seguro para uso concurrente.
+type exchangeRateRepository struct {
+	mu      sync.RWMutex
+	storage storage.Storage
+	rates   domain.ExchangeRates
+	// source es el contenido del archivo con el que se armó la tabla, para detectar cambios
+	source domain.ExchangeRatesStorage
+}
+
+func NewExchangeRateRepository(storage storage.Storage) (*exchangeRateRepository, error) {
+
+	repository := &exchangeRateRepository{storage: storage}
+	if _, _, err := repository.Reload(); err != nil {
+		return nil, err
+	}
+
+	return repository, nil
+}
+
+// Get devuelve la tabla de cotizaciones vigente
+func (er *exchangeRateRepository) Get() (domain.ExchangeRates, error) {
+
+	er.mu.RLock()
+	defer er.mu.RUnlock()
+
+	return er.rates, nil
+}
+
+// Reload vuelve a leer el archivo de cotizaciones e indica si la tabla cambió. Si el archivo
+// no es válido se conserva la tabla anterior.
+func (er *exchangeRateRepository) Reload() (domain.ExchangeRates, bool, error) {
+
+	var source domain.ExchangeRatesStorage
+	if err := er.storage.Read(&source); err != nil {
+		return domain.ExchangeRates{}, false, domain.NewError(domain.ErrStorage, "Error al recuperar las cotizaciones: %s", err.Error())
+	}
+
+	er.mu.Lock()
+	defer er.mu.Unlock()
+
+	if !er.rates.LoadedAt.IsZero() && reflect.DeepEqual(source, er.source) {
+		return er.rates, false, nil
+	}
+
+	rates, err := domain.ExchangeRatesFromStorage(source, time.Now())
+	if err != nil {
+		return domain.ExchangeRates{}, false, err
+	}
+
+	er.rates = rates
+	er.source = source
+
+	return rates, true, nil
+}
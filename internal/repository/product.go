@@ -2,27 +2,51 @@ package repository
 
 import (
 	"PRACTICAS-GO-WEB/internal/domain"
+	"PRACTICAS-GO-WEB/internal/query"
+	"PRACTICAS-GO-WEB/internal/search"
 	"PRACTICAS-GO-WEB/internal/storage"
-	"cmp"
-	"fmt"
+	"log"
 	"slices"
+	"strings"
+	"sync"
+	"time"
 )
 
 type ProductRepository interface {
 	GetNextID() (int, error)
+	ReserveIDs(lastID int)
 	LoadAll() error
 	SaveAll() error
 	Get(id int) (domain.Product, error)
+	GetIncludingDeleted(id int) (domain.Product, error)
+	GetByCodeValue(codeValue string) (domain.Product, error)
 	GetAll() ([]domain.Product, error)
+	Find(filter domain.ProductFilter, q query.Query) (query.Page[domain.Product], error)
+	FindText(filter domain.ProductFilter, q query.Query) (query.Page[domain.ScoredProduct], error)
+	FindDeleted(q query.Query) (query.Page[domain.Product], error)
 	Create(product domain.Product) (domain.Product, error)
-	Update(product domain.Product) (domain.Product, error)
-	Delete(id int) error
+	Update(product domain.Product, ifMatch ...int) (domain.Product, error)
+	Delete(id int, ifMatch ...int) (domain.Product, error)
+	Restore(id int, ifMatch ...int) (domain.Product, error)
+	Purge(id int, ifMatch ...int) error
+	PurgeDeletedBefore(before time.Time) ([]domain.Product, error)
 }
 
+// productRepository es seguro para uso concurrente: las lecturas toman el lock de
+// lectura y devuelven copias, las mutaciones toman el lock de escritura. Los productos en
+// la papelera se conservan junto al resto para que sus códigos sigan reservados, pero solo
+// se devuelven en FindDeleted y GetByCodeValue.
 type productRepository struct {
+	mu       sync.RWMutex
 	storage  storage.Storage
 	products []domain.Product
 	lastID   int
+	// byID relaciona el ID de cada producto con su posición en products
+	byID map[int]int
+	// byCodeValue relaciona el código de cada producto con su ID
+	byCodeValue map[string]int
+	// names es el índice de texto completo sobre los nombres de los productos fuera de la papelera
+	names *search.Index
 }
 
 func NewProductRepository(storage storage.Storage) (*productRepository, error) {
@@ -33,39 +57,187 @@ func NewProductRepository(storage storage.Storage) (*productRepository, error) {
 		return nil, err
 	}
 
-	repository.lastID, err = repository.GetNextID()
-	if err != nil {
-		return nil, err
-	}
-
 	return repository, nil
 }
 
+// GetNextID devuelve el ID que recibiría el próximo producto creado. El ID definitivo
+// se asigna en Create, de forma atómica con la inserción.
 func (pr *productRepository) GetNextID() (int, error) {
-	var product domain.Product = slices.MaxFunc(pr.products, func(p1, p2 domain.Product) int { return cmp.Compare(p1.ID, p2.ID) })
-	var max int = product.ID + 1
-	return max, nil
+
+	pr.mu.RLock()
+	defer pr.mu.RUnlock()
+
+	return pr.lastID + 1, nil
+}
+
+// ReserveIDs evita que los productos creados reciban un ID hasta lastID inclusive. Los IDs de
+// los productos purgados ya no figuran en el almacenamiento, pero sí en la auditoría y en el
+// libro de stock, y no deben volver a asignarse.
+func (pr *productRepository) ReserveIDs(lastID int) {
+
+	pr.mu.Lock()
+	defer pr.mu.Unlock()
+
+	pr.lastID = max(pr.lastID, lastID)
 }
 
 func (pr *productRepository) LoadAll() error {
 	var products []domain.ProductStorage
 
+	// La lectura se hace con el lock de escritura tomado para que una mutación concurrente no
+	// quede fuera del estado cargado
+	pr.mu.Lock()
+	defer pr.mu.Unlock()
+
 	err := pr.storage.Read(&products)
 	if err != nil {
-		return fmt.Errorf("Error al recuperar los datos almacenados: %s", err.Error())
+		return domain.NewError(domain.ErrStorage, "Error al recuperar los datos almacenados: %s", err.Error())
+	}
+
+	loaded := domain.ProductsFromProductsStorage(products)
+
+	// Los productos almacenados antes de registrar la moneda se migran una única vez. Sus
+	// precios eran números de punto flotante: los que tienen más decimales que los centavos se
+	// redondean al par y se informan; en el resto de los productos son un error.
+	legacy := 0
+	for _, product := range products {
+		rounded := product.RoundedPrices()
+		if len(rounded) > 0 && !product.IsLegacy() {
+			return domain.NewError(domain.ErrStorage, "Error al recuperar los datos almacenados: el producto %d tiene precios con más de %d decimales: %s", product.ID, domain.MoneyDecimals, strings.Join(rounded, ", "))
+		}
+		if len(rounded) > 0 {
+			log.Printf("Se redondearon al par los precios del producto %d: %s", product.ID, strings.Join(rounded, ", "))
+		}
+		if product.IsLegacy() {
+			legacy++
+		}
+	}
+
+	// Validar los índices antes de reemplazar el estado para no dejarlo a medio cargar
+	byID := make(map[int]int, len(loaded))
+	byCodeValue := make(map[string]int, len(loaded))
+	// lastID no retrocede al recargar, para no reasignar el ID de un producto eliminado
+	lastID := pr.lastID
+	for i, product := range loaded {
+		if _, exists := byID[product.ID]; exists {
+			return domain.NewError(domain.ErrStorage, "Error al recuperar los datos almacenados: el ID %d está repetido", product.ID)
+		}
+		if otherID, exists := byCodeValue[product.CodeValue]; exists {
+			return domain.NewError(domain.ErrDuplicateCodeValue, "Error al recuperar los datos almacenados: el codigo %s está repetido en los productos %d y %d", product.CodeValue, otherID, product.ID)
+		}
+		byID[product.ID] = i
+		byCodeValue[product.CodeValue] = product.ID
+		lastID = max(lastID, product.ID)
 	}
 
-	pr.products = domain.ProductsFromProductsStorage(products)
+	names := search.NewIndex()
+	for _, product := range loaded {
+		if !product.IsDeleted() {
+			names.Add(product.ID, product.Name)
+		}
+	}
+
+	pr.products = loaded
+	pr.byID = byID
+	pr.byCodeValue = byCodeValue
+	pr.names = names
+	pr.lastID = lastID
+
+	if legacy > 0 {
+		if err := pr.saveAll(); err != nil {
+			return domain.NewError(domain.ErrStorage, "Error al migrar los precios almacenados: %s", err.Error())
+		}
+		log.Printf("Se migraron los precios de %d productos a importes exactos en %s", legacy, domain.DefaultCurrency)
+	}
+
+	return nil
+}
+
+// reindexFrom recalcula las posiciones del índice por ID a partir de la posición indicada
+func (pr *productRepository) reindexFrom(start int) {
+	for i := start; i < len(pr.products); i++ {
+		pr.byID[pr.products[i].ID] = i
+	}
+}
+
+// reindex reconstruye los índices por ID y por código a partir de products
+func (pr *productRepository) reindex() {
+
+	pr.byID = make(map[int]int, len(pr.products))
+	pr.byCodeValue = make(map[string]int, len(pr.products))
+	for i, product := range pr.products {
+		pr.byID[product.ID] = i
+		pr.byCodeValue[product.CodeValue] = product.ID
+	}
+
+}
+
+// active devuelve la posición del producto si existe y no está en la papelera
+func (pr *productRepository) active(id int) (int, error) {
+
+	index, exists := pr.byID[id]
+	if !exists || pr.products[index].IsDeleted() {
+		return 0, domain.NewError(domain.ErrNotFound, "No se encontró el producto con el ID %d", id)
+	}
+
+	return index, nil
+}
+
+// checkVersion verifica que la versión actual del producto sea una de las esperadas
+func checkVersion(product domain.Product, ifMatch []int) error {
+
+	if len(ifMatch) == 0 || slices.Contains(ifMatch, product.Version) {
+		return nil
+	}
+
+	return domain.NewError(domain.ErrVersionMismatch, "El producto con el ID %d fue modificado, su versión actual es %d", product.ID, product.Version)
+}
+
+// checkCodeValue verifica que el código no esté asignado a otro producto, incluidos los de la papelera
+func (pr *productRepository) checkCodeValue(codeValue string, id int) error {
+
+	if otherID, exists := pr.byCodeValue[codeValue]; exists && otherID != id {
+		if pr.products[pr.byID[otherID]].IsDeleted() {
+			return domain.NewError(domain.ErrDuplicateCodeValue, "Ya existe un producto en la papelera con el codigo %s", codeValue)
+		}
+		return domain.NewError(domain.ErrDuplicateCodeValue, "Ya existe un producto registrado con el codigo %s", codeValue)
+	}
 
 	return nil
 }
 
 func (pr *productRepository) SaveAll() error {
 
+	// El lock de escritura evita que dos escrituras concurrentes terminen en desorden
+	pr.mu.Lock()
+	defer pr.mu.Unlock()
+
+	return pr.saveAll()
+}
+
+func (pr *productRepository) saveAll() error {
+
 	products := domain.ProductsStorageFromProducts(pr.products)
 	err := pr.storage.Write(products)
 	if err != nil {
-		return fmt.Errorf("Error al almacenar los datos: %s", err.Error())
+		return domain.NewError(domain.ErrStorage, "Error al almacenar los datos: %s", err.Error())
+	}
+
+	return nil
+}
+
+// persist registra una mutación individual cuando el almacenamiento es un journal;
+// en caso contrario reescribe el estado completo. Debe llamarse con el lock de escritura tomado.
+func (pr *productRepository) persist(op storage.Operation, product domain.Product) error {
+
+	journal, ok := pr.storage.(storage.Journal)
+	if !ok {
+		return pr.saveAll()
+	}
+
+	err := journal.Append(op, product.ID, domain.ProductStorageFromProduct(product))
+	if err != nil {
+		return domain.NewError(domain.ErrStorage, "Error al almacenar los datos: %s", err.Error())
 	}
 
 	return nil
@@ -73,67 +245,342 @@ func (pr *productRepository) SaveAll() error {
 
 func (pr *productRepository) Get(id int) (domain.Product, error) {
 
-	var products, err = pr.GetAll()
+	pr.mu.RLock()
+	defer pr.mu.RUnlock()
+
+	index, err := pr.active(id)
 	if err != nil {
 		return domain.Product{}, err
 	}
 
-	index := slices.IndexFunc(products, func(p domain.Product) bool { return p.ID == id })
-	if index == -1 {
-		return domain.Product{}, fmt.Errorf("No se encontró el producto con el ID %d", id)
+	return pr.products[index].Clone(), nil
+
+}
+
+// GetIncludingDeleted devuelve el producto con el ID indicado, aunque esté en la papelera
+func (pr *productRepository) GetIncludingDeleted(id int) (domain.Product, error) {
+
+	pr.mu.RLock()
+	defer pr.mu.RUnlock()
+
+	index, exists := pr.byID[id]
+	if !exists {
+		return domain.Product{}, domain.NewError(domain.ErrNotFound, "No se encontró el producto con el ID %d", id)
+	}
+
+	return pr.products[index].Clone(), nil
+
+}
+
+// GetByCodeValue devuelve el producto con el código indicado, aunque esté en la papelera
+func (pr *productRepository) GetByCodeValue(codeValue string) (domain.Product, error) {
+
+	pr.mu.RLock()
+	defer pr.mu.RUnlock()
+
+	id, exists := pr.byCodeValue[codeValue]
+	if !exists {
+		return domain.Product{}, domain.NewError(domain.ErrNotFound, "No se encontró el producto con el codigo %s", codeValue)
 	}
 
-	return products[index], nil
+	return pr.products[pr.byID[id]].Clone(), nil
 
 }
 
 func (pr *productRepository) GetAll() ([]domain.Product, error) {
-	return pr.products, nil
+
+	pr.mu.RLock()
+	defer pr.mu.RUnlock()
+
+	products := make([]domain.Product, 0, len(pr.products))
+	for _, product := range pr.products {
+		if !product.IsDeleted() {
+			products = append(products, product.Clone())
+		}
+	}
+
+	return products, nil
+}
+
+// Find devuelve la página pedida de los productos que cumplen el filtro, ordenada según la consulta
+func (pr *productRepository) Find(filter domain.ProductFilter, q query.Query) (query.Page[domain.Product], error) {
+
+	pr.mu.RLock()
+	products := make([]domain.Product, 0, len(pr.products))
+	for _, product := range pr.products {
+		if !product.IsDeleted() && filter.Matches(product) {
+			products = append(products, product.Clone())
+		}
+	}
+	pr.mu.RUnlock()
+
+	query.SortFunc(products, q.Sort, domain.ProductComparators, domain.ProductID)
+
+	return query.Paginate(products, q, domain.ProductID)
+}
+
+// FindDeleted devuelve la página pedida de los productos en la papelera, ordenada según la consulta
+func (pr *productRepository) FindDeleted(q query.Query) (query.Page[domain.Product], error) {
+
+	pr.mu.RLock()
+	var products []domain.Product
+	for _, product := range pr.products {
+		if product.IsDeleted() {
+			products = append(products, product.Clone())
+		}
+	}
+	pr.mu.RUnlock()
+
+	query.SortFunc(products, q.Sort, domain.ProductComparators, domain.ProductID)
+
+	return query.Paginate(products, q, domain.ProductID)
+}
+
+// FindText devuelve la página pedida de los productos cuyo nombre coincide con la búsqueda de
+// texto del filtro y cumplen el resto de sus criterios. Sin un orden explícito en la consulta,
+// los productos se ordenan por relevancia.
+func (pr *productRepository) FindText(filter domain.ProductFilter, q query.Query) (query.Page[domain.ScoredProduct], error) {
+
+	var text string
+	if filter.Text != nil {
+		text = *filter.Text
+	}
+
+	pr.mu.RLock()
+	hits := pr.names.Search(text)
+	products := make([]domain.ScoredProduct, 0, len(hits))
+	for _, hit := range hits {
+		index, exists := pr.byID[hit.ID]
+		if !exists || pr.products[index].IsDeleted() || !filter.Matches(pr.products[index]) {
+			continue
+		}
+		products = append(products, domain.ScoredProduct{Product: pr.products[index].Clone(), Score: hit.Score})
+	}
+	pr.mu.RUnlock()
+
+	comparators := make(map[string]query.Comparator[domain.ScoredProduct], len(domain.ProductComparators))
+	for field, compare := range domain.ProductComparators {
+		comparators[field] = func(a, b domain.ScoredProduct) int { return compare(a.Product, b.Product) }
+	}
+	scoredID := func(product domain.ScoredProduct) int { return product.Product.ID }
+
+	query.SortFunc(products, q.Sort, comparators, scoredID)
+
+	return query.Paginate(products, q, scoredID)
 }
 
 func (pr *productRepository) Create(product domain.Product) (domain.Product, error) {
 
-	id, err := pr.GetNextID()
-	if err != nil {
-		return domain.Product{}, fmt.Errorf("Ocurrió un error durante la creación del nuevo producto: %s", err.Error())
+	pr.mu.Lock()
+	defer pr.mu.Unlock()
+
+	if err := pr.checkCodeValue(product.CodeValue, 0); err != nil {
+		return domain.Product{}, err
 	}
+
+	// El ID se asigna con el lock tomado para que dos altas concurrentes no lo repitan
+	id := pr.lastID + 1
+	product = product.Clone()
 	product.ID = id
+	product.Version = 1
 
 	pr.products = append(pr.products, product)
-	if err := pr.SaveAll(); err != nil {
+	pr.byID[id] = len(pr.products) - 1
+	pr.byCodeValue[product.CodeValue] = id
+
+	if err := pr.persist(storage.OperationCreate, product); err != nil {
+		// Revertir el alta en memoria para que no diverja de lo almacenado
+		pr.products = pr.products[:len(pr.products)-1]
+		delete(pr.byID, id)
+		delete(pr.byCodeValue, product.CodeValue)
 		return domain.Product{}, err
 	}
 
+	pr.names.Add(id, product.Name)
+
 	pr.lastID = id
 
-	return product, nil
+	return product.Clone(), nil
 }
 
-func (pr *productRepository) Update(product domain.Product) (domain.Product, error) {
+// Update reemplaza el producto e incrementa su versión. Si se indican versiones en ifMatch,
+// la versión actual debe ser una de ellas.
+func (pr *productRepository) Update(product domain.Product, ifMatch ...int) (domain.Product, error) {
+
+	pr.mu.Lock()
+	defer pr.mu.Unlock()
+
+	index, err := pr.active(product.ID)
+	if err != nil {
+		return domain.Product{}, err
+	}
 
-	index := slices.IndexFunc(pr.products, func(p domain.Product) bool { return p.ID == product.ID })
+	previous := pr.products[index]
+	if err := checkVersion(previous, ifMatch); err != nil {
+		return domain.Product{}, err
+	}
 
+	if err := pr.checkCodeValue(product.CodeValue, product.ID); err != nil {
+		return domain.Product{}, err
+	}
+
+	product = product.Clone()
+	product.Version = previous.Version + 1
 	pr.products[index] = product
+	delete(pr.byCodeValue, previous.CodeValue)
+	pr.byCodeValue[product.CodeValue] = product.ID
+
+	if err := pr.persist(storage.OperationUpdate, product); err != nil {
+		// Revertir la modificación en memoria para que no diverja de lo almacenado
+		pr.products[index] = previous
+		delete(pr.byCodeValue, product.CodeValue)
+		pr.byCodeValue[previous.CodeValue] = previous.ID
+		return domain.Product{}, err
+	}
+
+	if product.Name != previous.Name {
+		pr.names.Add(product.ID, product.Name)
+	}
+
+	return product.Clone(), nil
+}
+
+// Delete envía el producto a la papelera e incrementa su versión. Si se indican versiones
+// en ifMatch, la versión actual debe ser una de ellas.
+func (pr *productRepository) Delete(id int, ifMatch ...int) (domain.Product, error) {
 
-	if err := pr.SaveAll(); err != nil {
+	pr.mu.Lock()
+	defer pr.mu.Unlock()
+
+	index, err := pr.active(id)
+	if err != nil {
+		return domain.Product{}, err
+	}
+
+	previous := pr.products[index]
+	if err := checkVersion(previous, ifMatch); err != nil {
+		return domain.Product{}, err
+	}
+
+	deletedAt := time.Now().UTC().Truncate(time.Second)
+	deleted := previous.Clone()
+	deleted.DeletedAt = &deletedAt
+	deleted.Version = previous.Version + 1
+	pr.products[index] = deleted
+
+	if err := pr.persist(storage.OperationUpdate, deleted); err != nil {
+		// Revertir la baja en memoria para que no diverja de lo almacenado
+		pr.products[index] = previous
+		return domain.Product{}, err
+	}
+
+	pr.names.Remove(id)
+
+	return deleted.Clone(), nil
+}
+
+// Restore recupera un producto de la papelera e incrementa su versión. Si se indican
+// versiones en ifMatch, la versión actual debe ser una de ellas.
+func (pr *productRepository) Restore(id int, ifMatch ...int) (domain.Product, error) {
+
+	pr.mu.Lock()
+	defer pr.mu.Unlock()
+
+	index, exists := pr.byID[id]
+	if !exists || !pr.products[index].IsDeleted() {
+		return domain.Product{}, domain.NewError(domain.ErrNotFound, "No se encontró el producto con el ID %d en la papelera", id)
+	}
+
+	previous := pr.products[index]
+	if err := checkVersion(previous, ifMatch); err != nil {
 		return domain.Product{}, err
 	}
 
-	return product, nil
+	restored := previous.Clone()
+	restored.DeletedAt = nil
+	restored.Version = previous.Version + 1
+	pr.products[index] = restored
+
+	if err := pr.persist(storage.OperationUpdate, restored); err != nil {
+		// Revertir la recuperación en memoria para que no diverja de lo almacenado
+		pr.products[index] = previous
+		return domain.Product{}, err
+	}
+
+	pr.names.Add(id, restored.Name)
+
+	return restored.Clone(), nil
 }
 
-func (pr *productRepository) Delete(id int) error {
+// Purge elimina el producto de forma definitiva, esté o no en la papelera. Si se indican
+// versiones en ifMatch, la versión actual debe ser una de ellas.
+func (pr *productRepository) Purge(id int, ifMatch ...int) error {
 
-	index := slices.IndexFunc(pr.products, func(p domain.Product) bool { return p.ID == id })
-	if index == -1 {
-		return fmt.Errorf("No se encontró el producto con el ID %d", id)
+	pr.mu.Lock()
+	defer pr.mu.Unlock()
+
+	index, exists := pr.byID[id]
+	if !exists {
+		return domain.NewError(domain.ErrNotFound, "No se encontró el producto con el ID %d", id)
 	}
 
-	pr.products = slices.Delete(pr.products, index, index+1)
+	deleted := pr.products[index]
+	if err := checkVersion(deleted, ifMatch); err != nil {
+		return err
+	}
 
-	if err := pr.SaveAll(); err != nil {
+	previous := pr.products
+	pr.products = slices.Delete(slices.Clone(pr.products), index, index+1)
+	delete(pr.byID, id)
+	delete(pr.byCodeValue, deleted.CodeValue)
+	pr.reindexFrom(index)
+
+	if err := pr.persist(storage.OperationDelete, deleted); err != nil {
+		// Revertir la baja en memoria para que no diverja de lo almacenado
+		pr.products = previous
+		pr.byID[id] = index
+		pr.byCodeValue[deleted.CodeValue] = id
+		pr.reindexFrom(index)
 		return err
 	}
 
+	pr.names.Remove(id)
+
 	return nil
 }
+
+// PurgeDeletedBefore elimina de forma definitiva los productos enviados a la papelera antes
+// del instante indicado y los devuelve. El resultado se guarda en una sola escritura del
+// estado completo, por lo que se eliminan todos o ninguno.
+func (pr *productRepository) PurgeDeletedBefore(before time.Time) ([]domain.Product, error) {
+
+	pr.mu.Lock()
+	defer pr.mu.Unlock()
+
+	previous := pr.products
+	kept := make([]domain.Product, 0, len(previous))
+	var purged []domain.Product
+	for _, product := range previous {
+		if product.IsDeleted() && product.DeletedAt.Before(before) {
+			purged = append(purged, product.Clone())
+			continue
+		}
+		kept = append(kept, product)
+	}
+
+	if len(purged) == 0 {
+		return nil, nil
+	}
+
+	pr.products = kept
+	pr.reindex()
+
+	if err := pr.saveAll(); err != nil {
+		// Revertir la purga en memoria para que no diverja de lo almacenado
+		pr.products = previous
+		pr.reindex()
+		return nil, err
+	}
+
+	return purged, nil
+}
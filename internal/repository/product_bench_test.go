@@ -0,0 +1,193 @@
+package repository
+
+import (
+	"PRACTICAS-GO-WEB/internal/domain"
+	"PRACTICAS-GO-WEB/internal/storage"
+
+	"fmt"
+	"slices"
+	"testing"
+)
+
+// benchmarkCatalogSize es la cantidad de productos del catálogo de los benchmarks
+const benchmarkCatalogSize = 200_000
+
+// discardJournal descarta las escrituras, para medir el repositorio sin el costo de persistir.
+// Como journal, cada modificación persiste solo el producto modificado.
+type discardJournal struct{}
+
+func (discardJournal) Read(emptyListEntity any) error                         { return nil }
+func (discardJournal) Write(emptyListEntity any) error                        { return nil }
+func (discardJournal) Append(op storage.Operation, id int, payload any) error { return nil }
+func (discardJournal) Compact() error                                         { return nil }
+
+// newBenchmarkRepository carga un catálogo de benchmarkCatalogSize productos. Las
+// escrituras posteriores se descartan.
+func newBenchmarkRepository(b *testing.B) *productRepository {
+
+	b.Helper()
+
+	products := make([]domain.Product, benchmarkCatalogSize)
+	for i := range products {
+		products[i] = testProduct(benchmarkCode(i + 1))
+		products[i].ID = i + 1
+	}
+
+	catalog := &memoryStorage{}
+	if err := catalog.Write(domain.ProductsStorageFromProducts(products)); err != nil {
+		b.Fatal(err)
+	}
+
+	repository, err := NewProductRepository(catalog)
+	if err != nil {
+		b.Fatal(err)
+	}
+	repository.storage = discardJournal{}
+
+	return repository
+}
+
+func benchmarkCode(id int) string {
+	return fmt.Sprintf("BENCH-%06d", id)
+}
+
+// benchmarkID recorre el catálogo salteado, desde la mitad, para no favorecer a los primeros
+// productos
+func benchmarkID(i int) int {
+	return (i*7919+benchmarkCatalogSize/2)%benchmarkCatalogSize + 1
+}
+
+// Las variantes linear* reproducen las operaciones del repositorio antes de indexar los
+// productos: buscan con slices.IndexFunc, pero validan y persisten igual que las indexadas,
+// de modo que las dos mediciones solo difieren en la búsqueda.
+
+func (pr *productRepository) linearGet(id int) (domain.Product, error) {
+
+	pr.mu.RLock()
+	defer pr.mu.RUnlock()
+
+	index := slices.IndexFunc(pr.products, func(p domain.Product) bool { return p.ID == id })
+	if index == -1 || pr.products[index].IsDeleted() {
+		return domain.Product{}, domain.NewError(domain.ErrNotFound, "No se encontró el producto con el ID %d", id)
+	}
+
+	return pr.products[index].Clone(), nil
+}
+
+func (pr *productRepository) linearGetByCodeValue(codeValue string) (domain.Product, error) {
+
+	pr.mu.RLock()
+	defer pr.mu.RUnlock()
+
+	index := slices.IndexFunc(pr.products, func(p domain.Product) bool { return p.CodeValue == codeValue })
+	if index == -1 {
+		return domain.Product{}, domain.NewError(domain.ErrNotFound, "No se encontró el producto con el codigo %s", codeValue)
+	}
+
+	return pr.products[index].Clone(), nil
+}
+
+func (pr *productRepository) linearUpdate(product domain.Product, ifMatch ...int) (domain.Product, error) {
+
+	pr.mu.Lock()
+	defer pr.mu.Unlock()
+
+	index := slices.IndexFunc(pr.products, func(p domain.Product) bool { return p.ID == product.ID })
+	if index == -1 || pr.products[index].IsDeleted() {
+		return domain.Product{}, domain.NewError(domain.ErrNotFound, "No se encontró el producto con el ID %d", product.ID)
+	}
+
+	previous := pr.products[index]
+	if err := checkVersion(previous, ifMatch); err != nil {
+		return domain.Product{}, err
+	}
+
+	if slices.ContainsFunc(pr.products, func(p domain.Product) bool { return p.CodeValue == product.CodeValue && p.ID != product.ID }) {
+		return domain.Product{}, domain.NewError(domain.ErrDuplicateCodeValue, "Ya existe un producto con el codigo %s", product.CodeValue)
+	}
+
+	product = product.Clone()
+	product.Version = previous.Version + 1
+	pr.products[index] = product
+
+	if err := pr.persist(storage.OperationUpdate, product); err != nil {
+		pr.products[index] = previous
+		return domain.Product{}, err
+	}
+
+	if product.Name != previous.Name {
+		pr.names.Add(product.ID, product.Name)
+	}
+
+	return product.Clone(), nil
+}
+
+func BenchmarkProductRepositoryGet(b *testing.B) {
+
+	repository := newBenchmarkRepository(b)
+
+	b.Run("indexed", func(b *testing.B) {
+		for i := 0; b.Loop(); i++ {
+			if _, err := repository.Get(benchmarkID(i)); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("linear", func(b *testing.B) {
+		for i := 0; b.Loop(); i++ {
+			if _, err := repository.linearGet(benchmarkID(i)); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+}
+
+func BenchmarkProductRepositoryGetByCodeValue(b *testing.B) {
+
+	repository := newBenchmarkRepository(b)
+
+	b.Run("indexed", func(b *testing.B) {
+		for i := 0; b.Loop(); i++ {
+			if _, err := repository.GetByCodeValue(benchmarkCode(benchmarkID(i))); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("linear", func(b *testing.B) {
+		for i := 0; b.Loop(); i++ {
+			if _, err := repository.linearGetByCodeValue(benchmarkCode(benchmarkID(i))); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+}
+
+func BenchmarkProductRepositoryUpdate(b *testing.B) {
+
+	repository := newBenchmarkRepository(b)
+
+	b.Run("indexed", func(b *testing.B) {
+		for i := 0; b.Loop(); i++ {
+			product := testProduct(benchmarkCode(benchmarkID(i)))
+			product.ID = benchmarkID(i)
+			if _, err := repository.Update(product); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("linear", func(b *testing.B) {
+		for i := 0; b.Loop(); i++ {
+			product := testProduct(benchmarkCode(benchmarkID(i)))
+			product.ID = benchmarkID(i)
+			if _, err := repository.linearUpdate(product); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+}
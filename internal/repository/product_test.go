@@ -0,0 +1,310 @@
+package repository
+
+import (
+	"PRACTICAS-GO-WEB/internal/domain"
+	"PRACTICAS-GO-WEB/internal/query"
+
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+// memoryStorage guarda en memoria el último estado escrito, serializado como JSON
+type memoryStorage struct {
+	mu   sync.Mutex
+	data []byte
+}
+
+func (ms *memoryStorage) Read(emptyListEntity any) error {
+
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	if ms.data == nil {
+		return nil
+	}
+
+	return json.Unmarshal(ms.data, emptyListEntity)
+}
+
+func (ms *memoryStorage) Write(emptyListEntity any) error {
+
+	data, err := json.Marshal(emptyListEntity)
+	if err != nil {
+		return err
+	}
+
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	ms.data = data
+
+	return nil
+}
+
+// testProduct devuelve un producto válido con el código indicado
+func testProduct(codeValue string) domain.Product {
+	return domain.Product{
+		Name:        "Producto " + codeValue,
+		Quantity:    10,
+		CodeValue:   codeValue,
+		IsPublished: true,
+		Price:       domain.NewMoney(1000, domain.DefaultCurrency),
+	}
+}
+
+// productIDs devuelve los IDs de los productos, en orden
+func productIDs(products []domain.Product) []int {
+
+	ids := make([]int, len(products))
+	for i, product := range products {
+		ids[i] = product.ID
+	}
+	slices.Sort(ids)
+
+	return ids
+}
+
+// TestProductRepositoryConcurrentAccess ejercita el repositorio desde muchas goroutines a la
+// vez: los escritores crean, modifican y eliminan productos mientras los lectores consultan y
+// otra goroutine guarda y recarga el estado. Se ejecuta con go test -race para detectar
+// accesos sin sincronizar.
+func TestProductRepositoryConcurrentAccess(t *testing.T) {
+
+	repository, err := NewProductRepository(&memoryStorage{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const workers = 8
+	const perWorker = 25
+
+	published := true
+	text := "producto modificado"
+
+	ids := make(chan int, workers*perWorker)
+	errs := make(chan error, workers*perWorker*8)
+	done := make(chan struct{})
+	var writers, others sync.WaitGroup
+
+	for worker := range workers {
+		writers.Add(1)
+		go func() {
+			defer writers.Done()
+
+			for i := range perWorker {
+				created, err := repository.Create(testProduct(fmt.Sprintf("W%d-%d", worker, i)))
+				if err != nil {
+					errs <- fmt.Errorf("Create: %w", err)
+					continue
+				}
+				ids <- created.ID
+
+				if _, err := repository.GetNextID(); err != nil {
+					errs <- fmt.Errorf("GetNextID: %w", err)
+				}
+
+				product, err := repository.Get(created.ID)
+				if err != nil {
+					errs <- fmt.Errorf("Get: %w", err)
+					continue
+				}
+
+				product.Name += " modificado"
+				if _, err := repository.Update(product); err != nil {
+					errs <- fmt.Errorf("Update: %w", err)
+				}
+
+				// De cada cinco productos, uno queda en la papelera, uno se recupera de ella y
+				// otro se elimina de forma definitiva
+				if i%5 > 2 {
+					continue
+				}
+				if _, err := repository.Delete(created.ID); err != nil {
+					errs <- fmt.Errorf("Delete: %w", err)
+					continue
+				}
+				switch i % 5 {
+				case 1:
+					if _, err := repository.Restore(created.ID); err != nil {
+						errs <- fmt.Errorf("Restore: %w", err)
+					}
+				case 2:
+					if err := repository.Purge(created.ID); err != nil {
+						errs <- fmt.Errorf("Purge: %w", err)
+					}
+				}
+			}
+		}()
+	}
+
+	// Lectores y persistencia completa, concurrentes con los escritores hasta que terminan
+	others.Add(2)
+	go func() {
+		defer others.Done()
+
+		for {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			if _, err := repository.GetAll(); err != nil {
+				errs <- fmt.Errorf("GetAll: %w", err)
+				return
+			}
+			// Los productos pueden no existir todavía o ya haberse eliminado
+			_, _ = repository.GetByCodeValue("W0-0")
+			_, _ = repository.GetIncludingDeleted(1)
+			if _, err := repository.Find(domain.ProductFilter{IsPublished: &published}, query.Query{Limit: 20, Sort: []query.SortKey{{Field: "name", Descending: true}}}); err != nil {
+				errs <- fmt.Errorf("Find: %w", err)
+				return
+			}
+			if _, err := repository.FindText(domain.ProductFilter{Text: &text}, query.Query{Limit: 20}); err != nil {
+				errs <- fmt.Errorf("FindText: %w", err)
+				return
+			}
+			if _, err := repository.FindDeleted(query.Query{Limit: 20}); err != nil {
+				errs <- fmt.Errorf("FindDeleted: %w", err)
+				return
+			}
+		}
+	}()
+	go func() {
+		defer others.Done()
+
+		for {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			if err := repository.SaveAll(); err != nil {
+				errs <- fmt.Errorf("SaveAll: %w", err)
+				return
+			}
+			if err := repository.LoadAll(); err != nil {
+				errs <- fmt.Errorf("LoadAll: %w", err)
+				return
+			}
+			// Reservar los IDs ya asignados no cambia el próximo ID
+			if nextID, err := repository.GetNextID(); err == nil {
+				repository.ReserveIDs(nextID - 1)
+			}
+			// Los productos de la papelera son recientes: la purga no encuentra ninguno
+			if _, err := repository.PurgeDeletedBefore(time.Now().Add(-time.Hour)); err != nil {
+				errs <- fmt.Errorf("PurgeDeletedBefore: %w", err)
+				return
+			}
+		}
+	}()
+
+	writers.Wait()
+	close(done)
+	others.Wait()
+	close(ids)
+	close(errs)
+
+	for err := range errs {
+		t.Error(err)
+	}
+
+	seen := make(map[int]bool, workers*perWorker)
+	for id := range ids {
+		if seen[id] {
+			t.Fatalf("el ID %d se asignó a más de un producto", id)
+		}
+		seen[id] = true
+	}
+	if len(seen) != workers*perWorker {
+		t.Fatalf("se crearon %d productos, se esperaban %d", len(seen), workers*perWorker)
+	}
+
+	products, err := repository.GetAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := workers * perWorker * 3 / 5; len(products) != want {
+		t.Fatalf("quedaron %d productos fuera de la papelera, se esperaban %d", len(products), want)
+	}
+	deleted, err := repository.FindDeleted(query.Query{Limit: workers * perWorker})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := workers * perWorker / 5; len(deleted.Items) != want {
+		t.Fatalf("quedaron %d productos en la papelera, se esperaban %d", len(deleted.Items), want)
+	}
+
+	// El estado almacenado coincide con el de memoria
+	reloaded, err := NewProductRepository(repository.storage)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reloadedProducts, err := reloaded.GetAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(productIDs(reloadedProducts), productIDs(products)) {
+		t.Fatalf("los productos almacenados no coinciden con los de memoria")
+	}
+
+}
+
+// TestLoadAllRoundsLegacyPrices verifica que la migración de los precios almacenados como
+// números de punto flotante los redondee al par en lugar de impedir la carga
+func TestLoadAllRoundsLegacyPrices(t *testing.T) {
+
+	storage := &memoryStorage{data: []byte(`[
+		{"id":1,"name":"A","quantity":1,"code_value":"A1","price":12.345},
+		{"id":2,"name":"B","quantity":1,"code_value":"B1","price":12.355},
+		{"id":3,"name":"C","quantity":1,"code_value":"C1","price":"-0.125"},
+		{"id":4,"name":"D","quantity":1,"code_value":"D1","price":7.5}
+	]`)}
+
+	repository, err := NewProductRepository(storage)
+	if err != nil {
+		t.Fatalf("la migración de precios con más de dos decimales falló: %v", err)
+	}
+
+	expected := map[int]string{1: "12.34", 2: "12.36", 3: "-0.12", 4: "7.50"}
+	for id, price := range expected {
+		product, err := repository.Get(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if product.Price.String() != price || product.Price.Currency != domain.DefaultCurrency {
+			t.Errorf("el precio del producto %d es %s %s, se esperaba %s %s", id, product.Price, product.Price.Currency, price, domain.DefaultCurrency)
+		}
+	}
+
+	// La migración se almacena y la próxima carga ya no redondea
+	var stored []domain.ProductStorage
+	if err := storage.Read(&stored); err != nil {
+		t.Fatal(err)
+	}
+	for _, product := range stored {
+		if product.IsLegacy() || len(product.RoundedPrices()) > 0 {
+			t.Errorf("el producto %d no quedó migrado: %+v", product.ID, product)
+		}
+	}
+
+}
+
+func TestLoadAllRejectsExtraDecimalsOutsideMigration(t *testing.T) {
+
+	storage := &memoryStorage{data: []byte(`[{"id":1,"name":"A","quantity":1,"code_value":"A1","price":12.345,"currency":"USD"}]`)}
+
+	if _, err := NewProductRepository(storage); !errors.Is(err, domain.ErrStorage) {
+		t.Fatalf("se esperaba un error de almacenamiento, se obtuvo %v", err)
+	}
+
+	// Los importes recibidos por la API nunca se redondean
+	if _, err := domain.ParseMoney("12.345", domain.DefaultCurrency); err == nil {
+		t.Fatal("ParseMoney aceptó un importe con más de dos decimales")
+	}
+
+}
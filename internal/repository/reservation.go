@@ -0,0 +1,173 @@
+package repository
+
+import (
+	"PRACTICAS-GO-WEB/internal/domain"
+	"PRACTICAS-GO-WEB/internal/query"
+	"PRACTICAS-GO-WEB/internal/storage"
+	"slices"
+	"sync"
+	"time"
+)
+
+type ReservationRepository interface {
+	Get(id int) (domain.Reservation, error)
+	Find(filter domain.ReservationFilter, q query.Query, now time.Time) (query.Page[domain.Reservation], error)
+	Create(reservation domain.Reservation) (domain.Reservation, error)
+	Update(reservation domain.Reservation) (domain.Reservation, error)
+	Reserved(productID int, now time.Time) map[int]int
+	Due(now time.Time) []domain.Reservation
+}
+
+// reservationRepository conserva las reservas en memoria y las reescribe completas en cada
+// modificación. Es seguro para uso concurrente.
+type reservationRepository struct {
+	mu           sync.RWMutex
+	storage      storage.Storage
+	reservations []domain.Reservation
+	lastID       int
+}
+
+func NewReservationRepository(storage storage.Storage) (*reservationRepository, error) {
+
+	var reservations []domain.Reservation
+	if err := storage.Read(&reservations); err != nil {
+		return nil, domain.NewError(domain.ErrStorage, "Error al recuperar las reservas almacenadas: %s", err.Error())
+	}
+
+	repository := &reservationRepository{storage: storage, reservations: reservations}
+	seen := make(map[int]bool, len(reservations))
+	for _, reservation := range reservations {
+		if seen[reservation.ID] {
+			return nil, domain.NewError(domain.ErrStorage, "Error al recuperar las reservas almacenadas: el ID %d está repetido", reservation.ID)
+		}
+		seen[reservation.ID] = true
+		repository.lastID = max(repository.lastID, reservation.ID)
+	}
+
+	return repository, nil
+}
+
+// index devuelve la posición de la reserva. Debe llamarse con el lock tomado.
+func (rr *reservationRepository) index(id int) (int, bool) {
+
+	index := slices.IndexFunc(rr.reservations, func(reservation domain.Reservation) bool {
+		return reservation.ID == id
+	})
+
+	return index, index >= 0
+}
+
+func (rr *reservationRepository) notFound(id int) error {
+	return domain.NewError(domain.ErrNotFound, "No se encontró la reserva con el ID %d", id)
+}
+
+func (rr *reservationRepository) Get(id int) (domain.Reservation, error) {
+
+	rr.mu.RLock()
+	defer rr.mu.RUnlock()
+
+	index, exists := rr.index(id)
+	if !exists {
+		return domain.Reservation{}, rr.notFound(id)
+	}
+
+	return rr.reservations[index], nil
+}
+
+// Find devuelve la página pedida de las reservas que cumplen el filtro, tal como se ven en el
+// instante now, en el orden en que se crearon
+func (rr *reservationRepository) Find(filter domain.ReservationFilter, q query.Query, now time.Time) (query.Page[domain.Reservation], error) {
+
+	rr.mu.RLock()
+	var reservations []domain.Reservation
+	for _, reservation := range rr.reservations {
+		if reservation = reservation.At(now); filter.Matches(reservation) {
+			reservations = append(reservations, reservation)
+		}
+	}
+	rr.mu.RUnlock()
+
+	return query.Paginate(reservations, q, domain.ReservationID)
+}
+
+// saveAll persiste todas las reservas. Debe llamarse con el lock de escritura tomado.
+func (rr *reservationRepository) saveAll() error {
+
+	if err := rr.storage.Write(rr.reservations); err != nil {
+		return domain.NewError(domain.ErrStorage, "Error al almacenar las reservas: %s", err.Error())
+	}
+
+	return nil
+}
+
+func (rr *reservationRepository) Create(reservation domain.Reservation) (domain.Reservation, error) {
+
+	rr.mu.Lock()
+	defer rr.mu.Unlock()
+
+	reservation.ID = rr.lastID + 1
+
+	rr.reservations = append(rr.reservations, reservation)
+	if err := rr.saveAll(); err != nil {
+		// Revertir el alta en memoria para que no diverja de lo almacenado
+		rr.reservations = rr.reservations[:len(rr.reservations)-1]
+		return domain.Reservation{}, err
+	}
+
+	rr.lastID = reservation.ID
+
+	return reservation, nil
+}
+
+func (rr *reservationRepository) Update(reservation domain.Reservation) (domain.Reservation, error) {
+
+	rr.mu.Lock()
+	defer rr.mu.Unlock()
+
+	index, exists := rr.index(reservation.ID)
+	if !exists {
+		return domain.Reservation{}, rr.notFound(reservation.ID)
+	}
+
+	previous := rr.reservations[index]
+	rr.reservations[index] = reservation
+	if err := rr.saveAll(); err != nil {
+		rr.reservations[index] = previous
+		return domain.Reservation{}, err
+	}
+
+	return reservation, nil
+}
+
+// Reserved devuelve la existencia del producto retenida en el instante now por depósito, con
+// la existencia sin depósito asignado en la clave cero
+func (rr *reservationRepository) Reserved(productID int, now time.Time) map[int]int {
+
+	rr.mu.RLock()
+	defer rr.mu.RUnlock()
+
+	reserved := make(map[int]int)
+	for _, reservation := range rr.reservations {
+		if reservation.ProductID == productID && reservation.IsHeld(now) {
+			reserved[reservation.WarehouseID] += reservation.Quantity
+		}
+	}
+
+	return reserved
+}
+
+// Due devuelve las reservas activas cuyo plazo terminó antes del instante now
+func (rr *reservationRepository) Due(now time.Time) []domain.Reservation {
+
+	rr.mu.RLock()
+	defer rr.mu.RUnlock()
+
+	var due []domain.Reservation
+	for _, reservation := range rr.reservations {
+		if reservation.Status == domain.ReservationActive && !reservation.IsHeld(now) {
+			due = append(due, reservation)
+		}
+	}
+
+	return due
+}
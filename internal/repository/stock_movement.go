@@ -0,0 +1,201 @@
+package repository
+
+import (
+	"PRACTICAS-GO-WEB/internal/domain"
+	"PRACTICAS-GO-WEB/internal/query"
+	"PRACTICAS-GO-WEB/internal/storage"
+	"maps"
+	"sync"
+)
+
+type StockMovementRepository interface {
+	Append(movement domain.StockMovement, guard bool) (domain.StockMovement, error)
+	Find(filter domain.StockMovementFilter, q query.Query) (query.Page[domain.StockMovement], error)
+	Balances(productID int) (map[int]int, bool)
+	Lots(productID int) map[string]domain.Lot
+	LastProductID() int
+}
+
+// stockMovementRepository conserva en memoria el libro de stock, que solo admite agregar
+// movimientos, y los saldos por producto y depósito que resultan de él. Es seguro para uso
+// concurrente.
+type stockMovementRepository struct {
+	mu        sync.RWMutex
+	storage   storage.AppendLog
+	movements []domain.StockMovement
+	// balances son los saldos de cada producto por depósito; la clave cero es la existencia
+	// sin depósito asignado
+	balances map[int]map[int]int
+	// lots son los lotes de cada producto por código, incluidos los agotados
+	lots map[int]map[string]domain.Lot
+}
+
+func NewStockMovementRepository(storage storage.AppendLog) (*stockMovementRepository, error) {
+
+	var movements []domain.StockMovement
+	if err := storage.Read(&movements); err != nil {
+		return nil, domain.NewError(domain.ErrStorage, "Error al recuperar el libro de stock: %s", err.Error())
+	}
+
+	repository := &stockMovementRepository{
+		storage:   storage,
+		movements: movements,
+		balances:  make(map[int]map[int]int),
+		lots:      make(map[int]map[string]domain.Lot),
+	}
+	for _, movement := range movements {
+		movement.Apply(repository.productBalances(movement.ProductID))
+		movement.ApplyLots(repository.productLots(movement.ProductID))
+	}
+
+	return repository, nil
+}
+
+// productBalances devuelve los saldos del producto, creándolos si no existen. Debe llamarse
+// con el lock de escritura tomado.
+func (mr *stockMovementRepository) productBalances(productID int) map[int]int {
+
+	balances, exists := mr.balances[productID]
+	if !exists {
+		balances = make(map[int]int)
+		mr.balances[productID] = balances
+	}
+
+	return balances
+}
+
+// productLots devuelve los lotes del producto, creándolos si no existen. Debe llamarse con el
+// lock de escritura tomado.
+func (mr *stockMovementRepository) productLots(productID int) map[string]domain.Lot {
+
+	lots, exists := mr.lots[productID]
+	if !exists {
+		lots = make(map[string]domain.Lot)
+		mr.lots[productID] = lots
+	}
+
+	return lots
+}
+
+// Append asigna el ID y el saldo resultante al movimiento y lo agrega al libro. Con guard, un
+// movimiento que deja en negativo la existencia de la que descuenta se rechaza. Un lote nunca
+// queda en negativo y solo se crea con su vencimiento.
+func (mr *stockMovementRepository) Append(movement domain.StockMovement, guard bool) (domain.StockMovement, error) {
+
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+
+	balances := maps.Clone(mr.balances[movement.ProductID])
+	if balances == nil {
+		balances = make(map[int]int)
+	}
+	movement.Apply(balances)
+
+	if guard {
+		decreased := movement.WarehouseID
+		if movement.Quantity > 0 && movement.Type != domain.MovementTransfer {
+			decreased = -1
+		}
+		if balance, exists := balances[decreased]; exists && balance < 0 {
+			if decreased == 0 {
+				return domain.StockMovement{}, domain.NewError(domain.ErrConflict, "La existencia del producto %d sin depósito asignado quedaría en %d unidades", movement.ProductID, balance)
+			}
+			return domain.StockMovement{}, domain.NewError(domain.ErrConflict, "La existencia del producto %d en el depósito %d quedaría en %d unidades", movement.ProductID, decreased, balance)
+		}
+	}
+
+	lots := maps.Clone(mr.lots[movement.ProductID])
+	if lots == nil {
+		lots = make(map[string]domain.Lot)
+	}
+	for _, lotMovement := range movement.Lots {
+		if _, exists := lots[lotMovement.Code]; !exists && lotMovement.Expiration == nil {
+			return domain.StockMovement{}, domain.NewError(domain.ErrConflict, "El lote %s del producto %d no existe", lotMovement.Code, movement.ProductID)
+		}
+	}
+	movement.ApplyLots(lots)
+	for _, lotMovement := range movement.Lots {
+		if lot := lots[lotMovement.Code]; lot.Quantity < 0 {
+			return domain.StockMovement{}, domain.NewError(domain.ErrConflict, "La existencia del lote %s del producto %d quedaría en %d unidades", lot.Code, movement.ProductID, lot.Quantity)
+		}
+	}
+
+	movement.ID = 1
+	if n := len(mr.movements); n > 0 {
+		movement.ID = mr.movements[n-1].ID + 1
+	}
+
+	movement.Balance = 0
+	for _, balance := range balances {
+		movement.Balance += balance
+	}
+
+	if err := mr.storage.Append(movement); err != nil {
+		return domain.StockMovement{}, domain.NewError(domain.ErrStorage, "Error al registrar el movimiento de stock: %s", err.Error())
+	}
+
+	mr.movements = append(mr.movements, movement)
+	mr.balances[movement.ProductID] = balances
+	mr.lots[movement.ProductID] = lots
+
+	return movement, nil
+}
+
+// Find devuelve la página pedida de los movimientos que cumplen el filtro, en orden cronológico
+func (mr *stockMovementRepository) Find(filter domain.StockMovementFilter, q query.Query) (query.Page[domain.StockMovement], error) {
+
+	mr.mu.RLock()
+	var movements []domain.StockMovement
+	for _, movement := range mr.movements {
+		if filter.Matches(movement) {
+			movements = append(movements, movement)
+		}
+	}
+	mr.mu.RUnlock()
+
+	return query.Paginate(movements, q, domain.StockMovementID)
+}
+
+// Balances devuelve los saldos del producto por depósito e indica si el producto tiene
+// movimientos en el libro
+func (mr *stockMovementRepository) Balances(productID int) (map[int]int, bool) {
+
+	mr.mu.RLock()
+	defer mr.mu.RUnlock()
+
+	balances, exists := mr.balances[productID]
+	if !exists {
+		return make(map[int]int), false
+	}
+
+	return maps.Clone(balances), true
+}
+
+// Lots devuelve los lotes del producto por código, incluidos los agotados
+func (mr *stockMovementRepository) Lots(productID int) map[string]domain.Lot {
+
+	mr.mu.RLock()
+	defer mr.mu.RUnlock()
+
+	lots := maps.Clone(mr.lots[productID])
+	if lots == nil {
+		lots = make(map[string]domain.Lot)
+	}
+
+	return lots
+}
+
+// LastProductID devuelve el mayor ID de producto con movimientos en el libro de stock,
+// incluidos los de productos ya purgados
+func (mr *stockMovementRepository) LastProductID() int {
+
+	mr.mu.RLock()
+	defer mr.mu.RUnlock()
+
+	lastID := 0
+	for productID := range mr.balances {
+		lastID = max(lastID, productID)
+	}
+
+	return lastID
+}
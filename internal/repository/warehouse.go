@@ -0,0 +1,186 @@
+package repository
+
+import (
+	"PRACTICAS-GO-WEB/internal/domain"
+	"PRACTICAS-GO-WEB/internal/query"
+	"PRACTICAS-GO-WEB/internal/storage"
+	"slices"
+	"strings"
+	"sync"
+)
+
+type WarehouseRepository interface {
+	Get(id int) (domain.Warehouse, error)
+	GetAll() ([]domain.Warehouse, error)
+	Find(q query.Query) (query.Page[domain.Warehouse], error)
+	Create(warehouse domain.Warehouse) (domain.Warehouse, error)
+	Update(warehouse domain.Warehouse) (domain.Warehouse, error)
+	Delete(id int) (domain.Warehouse, error)
+}
+
+// warehouseRepository conserva los depósitos en memoria y los reescribe completos en cada
+// modificación. Es seguro para uso concurrente.
+type warehouseRepository struct {
+	mu         sync.RWMutex
+	storage    storage.Storage
+	warehouses []domain.Warehouse
+	lastID     int
+}
+
+func NewWarehouseRepository(storage storage.Storage) (*warehouseRepository, error) {
+
+	var warehousesStorage []domain.WarehouseStorage
+	if err := storage.Read(&warehousesStorage); err != nil {
+		return nil, domain.NewError(domain.ErrStorage, "Error al recuperar los depósitos almacenados: %s", err.Error())
+	}
+
+	warehouses := domain.WarehousesFromWarehousesStorage(warehousesStorage)
+
+	repository := &warehouseRepository{storage: storage, warehouses: warehouses}
+	seen := make(map[int]bool, len(warehouses))
+	for _, warehouse := range warehouses {
+		if seen[warehouse.ID] {
+			return nil, domain.NewError(domain.ErrStorage, "Error al recuperar los depósitos almacenados: el ID %d está repetido", warehouse.ID)
+		}
+		seen[warehouse.ID] = true
+		repository.lastID = max(repository.lastID, warehouse.ID)
+	}
+
+	return repository, nil
+}
+
+// index devuelve la posición del depósito. Debe llamarse con el lock tomado.
+func (wr *warehouseRepository) index(id int) (int, bool) {
+
+	index := slices.IndexFunc(wr.warehouses, func(warehouse domain.Warehouse) bool {
+		return warehouse.ID == id
+	})
+
+	return index, index >= 0
+}
+
+func (wr *warehouseRepository) notFound(id int) error {
+	return domain.NewError(domain.ErrNotFound, "No se encontró el depósito con el ID %d", id)
+}
+
+func (wr *warehouseRepository) Get(id int) (domain.Warehouse, error) {
+
+	wr.mu.RLock()
+	defer wr.mu.RUnlock()
+
+	index, exists := wr.index(id)
+	if !exists {
+		return domain.Warehouse{}, wr.notFound(id)
+	}
+
+	return wr.warehouses[index], nil
+}
+
+func (wr *warehouseRepository) GetAll() ([]domain.Warehouse, error) {
+
+	wr.mu.RLock()
+	defer wr.mu.RUnlock()
+
+	return slices.Clone(wr.warehouses), nil
+}
+
+// Find devuelve la página pedida de depósitos, ordenada según la consulta
+func (wr *warehouseRepository) Find(q query.Query) (query.Page[domain.Warehouse], error) {
+
+	warehouses, _ := wr.GetAll()
+
+	query.SortFunc(warehouses, q.Sort, domain.WarehouseComparators, domain.WarehouseID)
+
+	return query.Paginate(warehouses, q, domain.WarehouseID)
+}
+
+// checkName verifica que no haya otro depósito con el mismo nombre. Debe llamarse con el lock
+// tomado.
+func (wr *warehouseRepository) checkName(warehouse domain.Warehouse) error {
+
+	for _, other := range wr.warehouses {
+		if other.ID != warehouse.ID && strings.EqualFold(other.Name, warehouse.Name) {
+			return domain.NewError(domain.ErrConflict, "Ya existe el depósito %s", warehouse.Name)
+		}
+	}
+
+	return nil
+}
+
+// saveAll persiste todos los depósitos. Debe llamarse con el lock de escritura tomado.
+func (wr *warehouseRepository) saveAll() error {
+
+	if err := wr.storage.Write(domain.WarehousesStorageFromWarehouses(wr.warehouses)); err != nil {
+		return domain.NewError(domain.ErrStorage, "Error al almacenar los depósitos: %s", err.Error())
+	}
+
+	return nil
+}
+
+func (wr *warehouseRepository) Create(warehouse domain.Warehouse) (domain.Warehouse, error) {
+
+	wr.mu.Lock()
+	defer wr.mu.Unlock()
+
+	warehouse.ID = wr.lastID + 1
+
+	if err := wr.checkName(warehouse); err != nil {
+		return domain.Warehouse{}, err
+	}
+
+	wr.warehouses = append(wr.warehouses, warehouse)
+	if err := wr.saveAll(); err != nil {
+		// Revertir el alta en memoria para que no diverja de lo almacenado
+		wr.warehouses = wr.warehouses[:len(wr.warehouses)-1]
+		return domain.Warehouse{}, err
+	}
+
+	wr.lastID = warehouse.ID
+
+	return warehouse, nil
+}
+
+func (wr *warehouseRepository) Update(warehouse domain.Warehouse) (domain.Warehouse, error) {
+
+	wr.mu.Lock()
+	defer wr.mu.Unlock()
+
+	index, exists := wr.index(warehouse.ID)
+	if !exists {
+		return domain.Warehouse{}, wr.notFound(warehouse.ID)
+	}
+
+	if err := wr.checkName(warehouse); err != nil {
+		return domain.Warehouse{}, err
+	}
+
+	previous := wr.warehouses[index]
+	wr.warehouses[index] = warehouse
+	if err := wr.saveAll(); err != nil {
+		wr.warehouses[index] = previous
+		return domain.Warehouse{}, err
+	}
+
+	return warehouse, nil
+}
+
+func (wr *warehouseRepository) Delete(id int) (domain.Warehouse, error) {
+
+	wr.mu.Lock()
+	defer wr.mu.Unlock()
+
+	index, exists := wr.index(id)
+	if !exists {
+		return domain.Warehouse{}, wr.notFound(id)
+	}
+
+	deleted := wr.warehouses[index]
+	previous := wr.warehouses
+	wr.warehouses = slices.Delete(slices.Clone(wr.warehouses), index, index+1)
+	if err := wr.saveAll(); err != nil {
+		wr.warehouses = previous
+		return domain.Warehouse{}, err
+	}
+
+	return deleted, nil
+}
@@ -0,0 +1,121 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// Job es una tarea periódica. El contexto se cancela cuando se detiene el scheduler.
+type Job func(ctx context.Context) error
+
+// Scheduler ejecuta tareas periódicas en segundo plano hasta que se lo detiene. Es seguro
+// para uso concurrente.
+type Scheduler struct {
+	mu      sync.Mutex
+	tasks   []task
+	ctx     context.Context
+	cancel  context.CancelFunc
+	wg      sync.WaitGroup
+	running bool
+}
+
+// task es una tarea registrada con su intervalo de ejecución
+type task struct {
+	name     string
+	interval time.Duration
+	job      Job
+}
+
+// función para crear un scheduler sin tareas
+func New() *Scheduler {
+
+	return &Scheduler{}
+
+}
+
+// Every registra una tarea que se ejecuta cada interval. La primera ejecución ocurre un
+// intervalo después de iniciado el scheduler. Si el scheduler ya está en marcha, la tarea
+// comienza de inmediato.
+func (s *Scheduler) Every(name string, interval time.Duration, job Job) error {
+
+	if interval <= 0 {
+		return fmt.Errorf("El intervalo de la tarea %s debe ser positivo: %s", name, interval)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t := task{name: name, interval: interval, job: job}
+	s.tasks = append(s.tasks, t)
+
+	if s.running {
+		s.launch(t)
+	}
+
+	return nil
+}
+
+// Start pone en marcha todas las tareas registradas
+func (s *Scheduler) Start() {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.running {
+		return
+	}
+
+	s.ctx, s.cancel = context.WithCancel(context.Background())
+	s.running = true
+
+	for _, t := range s.tasks {
+		s.launch(t)
+	}
+
+}
+
+// Stop detiene las tareas y espera a que terminen las ejecuciones en curso
+func (s *Scheduler) Stop() {
+
+	s.mu.Lock()
+	if !s.running {
+		s.mu.Unlock()
+		return
+	}
+	s.cancel()
+	s.running = false
+	s.mu.Unlock()
+
+	s.wg.Wait()
+
+}
+
+// launch ejecuta la tarea en su propia goroutine. Debe llamarse con el lock tomado.
+func (s *Scheduler) launch(t task) {
+
+	ctx := s.ctx
+	s.wg.Add(1)
+
+	go func() {
+		defer s.wg.Done()
+
+		ticker := time.NewTicker(t.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				// Un error se registra y la tarea vuelve a ejecutarse en el próximo intervalo
+				if err := t.job(ctx); err != nil {
+					log.Printf("Error en la tarea %s: %s", t.name, err)
+				}
+			}
+		}
+	}()
+
+}
@@ -0,0 +1,135 @@
+package search
+
+import (
+	"cmp"
+	"math"
+	"slices"
+	"sync"
+)
+
+// Parámetros de BM25
+const (
+	bm25K1 = 1.2
+	bm25B  = 0.75
+)
+
+// Hit es un documento que coincide con la búsqueda y su puntaje de relevancia
+type Hit struct {
+	ID    int
+	Score float64
+}
+
+// Index es un índice invertido en memoria con ranking BM25, seguro para uso concurrente
+type Index struct {
+	mu sync.RWMutex
+	// postings relaciona cada término con la frecuencia en cada documento que lo contiene
+	postings map[string]map[int]int
+	// terms guarda los términos de cada documento, para poder quitarlo del índice
+	terms    map[int][]string
+	totalLen int
+}
+
+func NewIndex() *Index {
+	return &Index{postings: make(map[string]map[int]int), terms: make(map[int][]string)}
+}
+
+// Add indexa el texto del documento, reemplazando el contenido indexado anteriormente
+func (index *Index) Add(id int, text string) {
+
+	index.mu.Lock()
+	defer index.mu.Unlock()
+
+	index.remove(id)
+
+	terms := Tokenize(text)
+	index.terms[id] = terms
+	index.totalLen += len(terms)
+
+	for _, term := range terms {
+		if index.postings[term] == nil {
+			index.postings[term] = make(map[int]int)
+		}
+		index.postings[term][id]++
+	}
+
+}
+
+// Remove quita el documento del índice
+func (index *Index) Remove(id int) {
+
+	index.mu.Lock()
+	defer index.mu.Unlock()
+
+	index.remove(id)
+
+}
+
+func (index *Index) remove(id int) {
+
+	terms, ok := index.terms[id]
+	if !ok {
+		return
+	}
+
+	for _, term := range terms {
+		delete(index.postings[term], id)
+		if len(index.postings[term]) == 0 {
+			delete(index.postings, term)
+		}
+	}
+
+	index.totalLen -= len(terms)
+	delete(index.terms, id)
+
+}
+
+// Search devuelve los documentos que contienen al menos un término del texto, ordenados
+// de mayor a menor relevancia según BM25
+func (index *Index) Search(text string) []Hit {
+
+	index.mu.RLock()
+	defer index.mu.RUnlock()
+
+	documents := len(index.terms)
+	if documents == 0 {
+		return nil
+	}
+	averageLen := float64(index.totalLen) / float64(documents)
+
+	scores := make(map[int]float64)
+	seen := make(map[string]bool)
+
+	for _, term := range Tokenize(text) {
+		if seen[term] {
+			continue
+		}
+		seen[term] = true
+
+		postings := index.postings[term]
+		if len(postings) == 0 {
+			continue
+		}
+
+		idf := math.Log(1 + (float64(documents)-float64(len(postings))+0.5)/(float64(len(postings))+0.5))
+
+		for id, frequency := range postings {
+			tf := float64(frequency)
+			norm := 1 - bm25B + bm25B*float64(len(index.terms[id]))/averageLen
+			scores[id] += idf * tf * (bm25K1 + 1) / (tf + bm25K1*norm)
+		}
+	}
+
+	hits := make([]Hit, 0, len(scores))
+	for id, score := range scores {
+		hits = append(hits, Hit{ID: id, Score: score})
+	}
+
+	slices.SortFunc(hits, func(a, b Hit) int {
+		if result := cmp.Compare(b.Score, a.Score); result != 0 {
+			return result
+		}
+		return cmp.Compare(a.ID, b.ID)
+	})
+
+	return hits
+}
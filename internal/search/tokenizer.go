@@ -0,0 +1,65 @@
+package search
+
+import (
+	"PRACTICAS-GO-WEB/pkg/utils"
+	"strings"
+	"unicode"
+)
+
+// stopWords son palabras demasiado frecuentes en español e inglés para aportar a la relevancia
+var stopWords = map[string]bool{
+	"a": true, "de": true, "del": true, "el": true, "en": true, "la": true, "las": true, "los": true,
+	"con": true, "sin": true, "por": true, "para": true, "un": true, "una": true, "y": true, "o": true,
+	"an": true, "and": true, "in": true, "of": true, "or": true, "the": true, "with": true,
+}
+
+// Tokenize divide el texto en términos normalizados: sin mayúsculas ni acentos, sin
+// palabras vacías y con los plurales reducidos a una raíz común
+func Tokenize(text string) []string {
+
+	words := strings.FieldsFunc(utils.FoldString(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	terms := make([]string, 0, len(words))
+	for _, word := range words {
+		if stopWords[word] {
+			continue
+		}
+		terms = append(terms, stem(word))
+	}
+
+	return terms
+}
+
+// stem es una reducción mínima de plurales en español e inglés. No busca obtener la palabra
+// correcta sino que el singular y el plural den el mismo término:
+// "vinos" y "vino" -> "vin", "berries" y "berry" -> "berri", "nueces" y "nuez" -> "nuez".
+func stem(word string) string {
+
+	if len([]rune(word)) <= 3 {
+		return word
+	}
+
+	switch {
+	case strings.HasSuffix(word, "ces"):
+		word = strings.TrimSuffix(word, "ces") + "z"
+	case strings.HasSuffix(word, "ies"):
+		word = strings.TrimSuffix(word, "es")
+	case strings.HasSuffix(word, "s") && !strings.HasSuffix(word, "ss") && !strings.HasSuffix(word, "us"):
+		word = strings.TrimSuffix(word, "s")
+	}
+
+	if strings.HasSuffix(word, "y") {
+		return strings.TrimSuffix(word, "y") + "i"
+	}
+
+	// Las vocales finales se quitan para unir "tomato"/"tomatoes" y "vino"/"vinos"
+	for _, vowel := range []string{"e", "o"} {
+		if len([]rune(word)) > 3 && strings.HasSuffix(word, vowel) {
+			word = strings.TrimSuffix(word, vowel)
+		}
+	}
+
+	return word
+}
@@ -0,0 +1,43 @@
+package service
+
+import (
+	"PRACTICAS-GO-WEB/internal/domain"
+	"PRACTICAS-GO-WEB/internal/query"
+	"PRACTICAS-GO-WEB/internal/repository"
+
+	"errors"
+)
+
+type AuditService interface {
+	GetProductHistory(productID int, q query.Query) (query.Page[domain.AuditEntry], error)
+	FindAuditEntries(filter domain.AuditFilter, q query.Query) (query.Page[domain.AuditEntry], error)
+}
+
+type auditService struct {
+	auditRepository repository.AuditRepository
+}
+
+func NewAuditService(auditRepository repository.AuditRepository) (*auditService, error) {
+
+	if auditRepository == nil {
+		return nil, errors.New("auditRepository is required")
+	}
+
+	return &auditService{auditRepository: auditRepository}, nil
+
+}
+
+// GetProductHistory devuelve las mutaciones del producto en orden cronológico. El historial
+// se conserva aunque el producto se haya eliminado de forma definitiva.
+func (as *auditService) GetProductHistory(productID int, q query.Query) (query.Page[domain.AuditEntry], error) {
+
+	return as.auditRepository.Find(domain.AuditFilter{ProductID: &productID}, q)
+
+}
+
+// FindAuditEntries devuelve las mutaciones que cumplen el filtro en orden cronológico
+func (as *auditService) FindAuditEntries(filter domain.AuditFilter, q query.Query) (query.Page[domain.AuditEntry], error) {
+
+	return as.auditRepository.Find(filter, q)
+
+}
@@ -0,0 +1,166 @@
+package service
+
+import (
+	"PRACTICAS-GO-WEB/internal/domain"
+	"PRACTICAS-GO-WEB/internal/query"
+	"PRACTICAS-GO-WEB/internal/repository"
+
+	"context"
+	"errors"
+	"fmt"
+)
+
+type CategoryService interface {
+	GetCategories(q query.Query) (query.Page[any], error)
+	GetCategoryTree() ([]domain.CategoryTreeResponse, error)
+	GetCategoryByID(id int) (domain.CategoryResponse, error)
+	GetCategoryProducts(id int, q query.Query, currency string) (query.Page[any], error)
+	PostCategory(category domain.CategoryRequest) (domain.CategoryResponse, error)
+	PutCategory(id int, category domain.CategoryRequest) (domain.CategoryResponse, error)
+	DeleteCategory(ctx context.Context, id int, force bool) error
+}
+
+// categoryService administra el árbol de categorías. Los productos de cada categoría se
+// consultan y modifican a través del servicio de productos.
+type categoryService struct {
+	categoryRepository repository.CategoryRepository
+	productService     ProductService
+}
+
+func NewCategoryService(categoryRepository repository.CategoryRepository, productService ProductService) (*categoryService, error) {
+
+	if categoryRepository == nil {
+		return nil, errors.New("categoryRepository is required")
+	}
+
+	if productService == nil {
+		return nil, errors.New("productService is required")
+	}
+
+	return &categoryService{categoryRepository: categoryRepository, productService: productService}, nil
+
+}
+
+func (cs *categoryService) GetCategories(q query.Query) (query.Page[any], error) {
+
+	page, err := cs.categoryRepository.Find(q)
+	if err != nil {
+		return query.Page[any]{}, err
+	}
+
+	// Devolver solo los campos pedidos
+	items, err := query.Project(domain.CategoryResponsesFromCategories(page.Items), q.Fields)
+	if err != nil {
+		return query.Page[any]{}, err
+	}
+
+	return query.WithItems(page, items), nil
+
+}
+
+// GetCategoryTree devuelve todas las categorías anidadas bajo sus categorías padre
+func (cs *categoryService) GetCategoryTree() ([]domain.CategoryTreeResponse, error) {
+
+	categories, err := cs.categoryRepository.GetAll()
+	if err != nil {
+		return nil, err
+	}
+
+	return domain.CategoryTreeFromCategories(categories), nil
+
+}
+
+func (cs *categoryService) GetCategoryByID(id int) (domain.CategoryResponse, error) {
+
+	category, err := cs.categoryRepository.Get(id)
+	if err != nil {
+		return domain.CategoryResponse{}, err
+	}
+
+	return domain.CategoryResponseFromCategory(category), nil
+
+}
+
+// GetCategoryProducts devuelve los productos asignados a la categoría o a cualquiera de sus
+// subcategorías
+func (cs *categoryService) GetCategoryProducts(id int, q query.Query, currency string) (query.Page[any], error) {
+
+	categoryIDs, err := cs.categoryRepository.Descendants(id)
+	if err != nil {
+		return query.Page[any]{}, err
+	}
+
+	return cs.productService.SearchProducts(domain.ProductFilter{CategoryIDs: categoryIDs}, q, currency)
+
+}
+
+func (cs *categoryService) PostCategory(category domain.CategoryRequest) (domain.CategoryResponse, error) {
+
+	if err := category.Validate(); err != nil {
+		return domain.CategoryResponse{}, err
+	}
+
+	categoryCreated, err := cs.categoryRepository.Create(domain.CategoryFromCategoryRequest(category))
+	if err != nil {
+		return domain.CategoryResponse{}, err
+	}
+
+	return domain.CategoryResponseFromCategory(categoryCreated), nil
+
+}
+
+// PutCategory reemplaza el nombre y la categoría padre. Mover una categoría mueve también
+// sus subcategorías y sus productos.
+func (cs *categoryService) PutCategory(id int, category domain.CategoryRequest) (domain.CategoryResponse, error) {
+
+	if err := category.Validate(); err != nil {
+		return domain.CategoryResponse{}, err
+	}
+
+	categoryToUpdate := domain.CategoryFromCategoryRequest(category)
+	categoryToUpdate.ID = id
+
+	categoryUpdated, err := cs.categoryRepository.Update(categoryToUpdate)
+	if err != nil {
+		return domain.CategoryResponse{}, err
+	}
+
+	return domain.CategoryResponseFromCategory(categoryUpdated), nil
+
+}
+
+// DeleteCategory elimina la categoría. Sin force, una categoría con subcategorías o con
+// productos asignados no se elimina; con force, las subcategorías pasan a depender del padre
+// de la categoría y los productos dejan de tenerla asignada.
+func (cs *categoryService) DeleteCategory(ctx context.Context, id int, force bool) error {
+
+	if _, err := cs.categoryRepository.Get(id); err != nil {
+		return err
+	}
+
+	if !force {
+		page, err := cs.productService.SearchProducts(domain.ProductFilter{CategoryIDs: []int{id}}, query.Query{Limit: 1}, "")
+		if err != nil {
+			return err
+		}
+		if page.Total > 0 {
+			return domain.NewError(domain.ErrConflict, "La categoría %d tiene %d productos asignados; use force para eliminarla igualmente", id, page.Total)
+		}
+	}
+
+	if _, err := cs.categoryRepository.Delete(id, force); err != nil {
+		return err
+	}
+
+	if !force {
+		return nil
+	}
+
+	// Los productos en la papelera conservan la categoría hasta que se restauran
+	if _, err := cs.productService.UnassignCategory(ctx, id); err != nil {
+		return fmt.Errorf("Error al quitar la categoría eliminada de sus productos: %w", err)
+	}
+
+	return nil
+
+}
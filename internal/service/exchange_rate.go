@@ -0,0 +1,52 @@
+package service
+
+import (
+	"PRACTICAS-GO-WEB/internal/domain"
+	"PRACTICAS-GO-WEB/internal/repository"
+
+	"errors"
+)
+
+type ExchangeRateService interface {
+	GetExchangeRates() (domain.ExchangeRatesResponse, error)
+	ReloadExchangeRates() (domain.ExchangeRatesResponse, bool, error)
+}
+
+type exchangeRateService struct {
+	exchangeRateRepository repository.ExchangeRateRepository
+}
+
+func NewExchangeRateService(exchangeRateRepository repository.ExchangeRateRepository) (*exchangeRateService, error) {
+
+	if exchangeRateRepository == nil {
+		return nil, errors.New("exchangeRateRepository is required")
+	}
+
+	return &exchangeRateService{exchangeRateRepository: exchangeRateRepository}, nil
+
+}
+
+// GetExchangeRates devuelve la tabla de cotizaciones vigente
+func (es *exchangeRateService) GetExchangeRates() (domain.ExchangeRatesResponse, error) {
+
+	rates, err := es.exchangeRateRepository.Get()
+	if err != nil {
+		return domain.ExchangeRatesResponse{}, err
+	}
+
+	return domain.ExchangeRatesResponseFromExchangeRates(rates), nil
+
+}
+
+// ReloadExchangeRates vuelve a leer el archivo de cotizaciones e indica si la tabla cambió. Un
+// archivo inválido se informa como error de validación y la tabla vigente no cambia.
+func (es *exchangeRateService) ReloadExchangeRates() (domain.ExchangeRatesResponse, bool, error) {
+
+	rates, changed, err := es.exchangeRateRepository.Reload()
+	if err != nil {
+		return domain.ExchangeRatesResponse{}, false, err
+	}
+
+	return domain.ExchangeRatesResponseFromExchangeRates(rates), changed, nil
+
+}
@@ -0,0 +1,96 @@
+package service
+
+import (
+	"PRACTICAS-GO-WEB/internal/domain"
+	"PRACTICAS-GO-WEB/internal/query"
+
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// GetExpiringProducts devuelve la página de los productos que cumplen el filtro y vencen en
+// los próximos días que abarca within, sin contar los ya vencidos. Sin orden pedido, se
+// ordenan por vencimiento.
+func (ps *productService) GetExpiringProducts(filter domain.ProductFilter, within time.Duration, q query.Query, currency string) (query.Page[any], error) {
+
+	if within <= 0 {
+		return query.Page[any]{}, query.NewParamError("within", "el plazo de vencimiento debe ser positivo")
+	}
+
+	return ps.SearchProducts(domain.ExpiringFilter(filter, time.Now(), within), byExpiration(q), currency)
+
+}
+
+// GetExpiredProducts devuelve la página de los productos que cumplen el filtro y ya vencieron.
+// Sin orden pedido, se ordenan por vencimiento.
+func (ps *productService) GetExpiredProducts(filter domain.ProductFilter, q query.Query, currency string) (query.Page[any], error) {
+
+	return ps.SearchProducts(domain.ExpiredFilter(filter, time.Now()), byExpiration(q), currency)
+
+}
+
+// UnpublishExpired despublica los productos vencidos y devuelve cuántos cambiaron. Cada
+// cambio queda en la auditoría; un producto modificado en simultáneo se vuelve a leer.
+func (ps *productService) UnpublishExpired(ctx context.Context) (int, error) {
+
+	products, err := ps.productRepository.GetAll()
+	if err != nil {
+		return 0, err
+	}
+
+	now := time.Now()
+	unpublished := 0
+
+	for _, product := range products {
+		if !product.IsPublished || !product.IsExpired(now) {
+			continue
+		}
+
+		changed, err := withRetry(nil, func(_ []int) (bool, error) {
+
+			oldProduct, err := ps.productRepository.Get(product.ID)
+			if err != nil {
+				return false, err
+			}
+
+			productToUpdate := oldProduct.Clone()
+			if !productToUpdate.UnpublishExpired(now) {
+				return false, nil
+			}
+
+			productUpdated, err := ps.productRepository.Update(productToUpdate, oldProduct.Version)
+			if err != nil {
+				return false, err
+			}
+
+			ps.record(ctx, domain.AuditUnpublishExpired, &oldProduct, &productUpdated)
+
+			return true, nil
+		})
+		if errors.Is(err, domain.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return unpublished, fmt.Errorf("Error al despublicar el producto vencido %d: %w", product.ID, err)
+		}
+		if changed {
+			unpublished++
+		}
+	}
+
+	return unpublished, nil
+
+}
+
+// byExpiration ordena la consulta por vencimiento si no pide otro orden
+func byExpiration(q query.Query) query.Query {
+
+	if len(q.Sort) == 0 {
+		q.Sort = []query.SortKey{{Field: "expiration_date"}}
+	}
+
+	return q
+
+}
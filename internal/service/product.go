@@ -1,107 +1,255 @@
 package service
 
 import (
+	"PRACTICAS-GO-WEB/internal/auth"
 	"PRACTICAS-GO-WEB/internal/domain"
+	"PRACTICAS-GO-WEB/internal/patch"
+	"PRACTICAS-GO-WEB/internal/query"
 	"PRACTICAS-GO-WEB/internal/repository"
+	"PRACTICAS-GO-WEB/pkg/web"
 
+	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"log"
 	"slices"
+	"sync"
 	"time"
 )
 
 type ProductService interface {
-	GetProducts() ([]domain.ProductResponse, error)
-	GetProductByID(id int) (domain.ProductResponse, error)
-	SearchProductByPrice(priceGt float64) ([]domain.ProductResponse, error)
-	PostProduct(product domain.ProductRequest) (domain.ProductResponse, error)
-	PutProduct(id int, product domain.ProductRequest) (domain.ProductResponse, error)
-	PatchProduct(id int, product domain.ProductRequest) (domain.ProductResponse, error)
-	DeleteProduct(id int) error
+	GetProducts(q query.Query, currency string) (query.Page[any], error)
+	GetProductByID(id int, currency string) (domain.ProductResponse, error)
+	SearchProducts(filter domain.ProductFilter, q query.Query, currency string) (query.Page[any], error)
+	PostProduct(ctx context.Context, product domain.ProductRequest) (domain.ProductResponse, error)
+	PutProduct(ctx context.Context, id int, product domain.ProductRequest, ifMatch ...int) (domain.ProductResponse, error)
+	PatchProduct(ctx context.Context, id int, productPatch patch.Patch, ifMatch ...int) (domain.ProductResponse, error)
+	DeleteProduct(ctx context.Context, id int, ifMatch ...int) error
+	GetTrash(q query.Query) (query.Page[any], error)
+	RestoreProduct(ctx context.Context, id int, ifMatch ...int) (domain.ProductResponse, error)
+	PurgeProduct(ctx context.Context, id int, ifMatch ...int) error
+	PurgeTrash(ctx context.Context, retention time.Duration) (int, error)
+	GetProductAt(id int, at time.Time) (domain.ProductResponse, error)
+	GetProductPrices(id int) (domain.ProductPricesResponse, error)
+	SchedulePrice(ctx context.Context, id int, price domain.PriceRequest, ifMatch ...int) (domain.ProductPricesResponse, error)
+	ApplyScheduledPrices(ctx context.Context) (int, error)
+	UnassignCategory(ctx context.Context, categoryID int) (int, error)
+	GetProductStock(id int) (domain.ProductStockResponse, error)
+	SetStock(ctx context.Context, id int, warehouseID int, stock domain.StockRequest, ifMatch ...int) (domain.ProductStockResponse, error)
+	TransferStock(ctx context.Context, id int, transfer domain.TransferRequest, ifMatch ...int) (domain.ProductStockResponse, error)
+	GetWarehouseStock(warehouseID int, q query.Query) (query.Page[domain.WarehouseStockResponse], error)
+	RecordStockMovement(ctx context.Context, id int, movement domain.StockMovementRequest, ifMatch ...int) (domain.StockMovement, error)
+	GetStockMovements(id int, filter domain.StockMovementFilter, q query.Query) (query.Page[domain.StockMovement], error)
+	ReconcileStock(ctx context.Context) (int, error)
+	GetExpiringProducts(filter domain.ProductFilter, within time.Duration, q query.Query, currency string) (query.Page[any], error)
+	GetExpiredProducts(filter domain.ProductFilter, q query.Query, currency string) (query.Page[any], error)
+	UnpublishExpired(ctx context.Context) (int, error)
+	ReserveStock(ctx context.Context, id int, reservation domain.ReservationRequest) (domain.Reservation, error)
+	GetReservations(id int, filter domain.ReservationFilter, q query.Query) (query.Page[domain.Reservation], error)
+	GetReservation(id int, reservationID int) (domain.Reservation, error)
+	ConfirmReservation(ctx context.Context, id int, reservationID int) (domain.Reservation, error)
+	ReleaseReservation(ctx context.Context, id int, reservationID int) (domain.Reservation, error)
+	ExpireReservations(ctx context.Context) (int, error)
 }
 
+// productService registra en la auditoría cada mutación de productos, convierte los precios
+// con la tabla de cotizaciones y verifica que las categorías y los depósitos indicados existan.
+// La cantidad de cada producto surge del libro de stock: los movimientos se registran de a uno
+// por vez, con stockMu tomado, para que el producto refleje siempre los saldos del libro. Las
+// reservas se crean y se cierran con el mismo lock, así nunca retienen más de lo disponible.
 type productService struct {
-	productRepository repository.ProductRepository
+	productRepository       repository.ProductRepository
+	auditRepository         repository.AuditRepository
+	exchangeRateRepository  repository.ExchangeRateRepository
+	categoryRepository      repository.CategoryRepository
+	warehouseRepository     repository.WarehouseRepository
+	stockMovementRepository repository.StockMovementRepository
+	reservationRepository   repository.ReservationRepository
+	// allowNegativeStock permite movimientos que dejan existencias negativas
+	allowNegativeStock bool
+	// reservationTTL es el plazo de las reservas que no indican otro
+	reservationTTL time.Duration
+	stockMu        sync.Mutex
 }
 
-func NewProductService(productRepository repository.ProductRepository) (*productService, error) {
+func NewProductService(productRepository repository.ProductRepository, auditRepository repository.AuditRepository, exchangeRateRepository repository.ExchangeRateRepository, categoryRepository repository.CategoryRepository, warehouseRepository repository.WarehouseRepository, stockMovementRepository repository.StockMovementRepository, reservationRepository repository.ReservationRepository, allowNegativeStock bool, reservationTTL time.Duration) (*productService, error) {
 
 	if productRepository == nil {
 		return nil, errors.New("productRepository is required")
 	}
 
-	return &productService{productRepository: productRepository}, nil
+	if auditRepository == nil {
+		return nil, errors.New("auditRepository is required")
+	}
 
-}
+	if exchangeRateRepository == nil {
+		return nil, errors.New("exchangeRateRepository is required")
+	}
 
-func (ps *productService) GetProducts() ([]domain.ProductResponse, error) {
+	if categoryRepository == nil {
+		return nil, errors.New("categoryRepository is required")
+	}
 
-	var products, err = ps.productRepository.GetAll()
+	if warehouseRepository == nil {
+		return nil, errors.New("warehouseRepository is required")
+	}
 
-	if err != nil {
-		return nil, err
+	if stockMovementRepository == nil {
+		return nil, errors.New("stockMovementRepository is required")
 	}
 
-	productsResponse := domain.ProductResponsesFromProductsBase(products)
+	if reservationRepository == nil {
+		return nil, errors.New("reservationRepository is required")
+	}
 
-	return productsResponse, nil
+	return &productService{
+		productRepository:       productRepository,
+		auditRepository:         auditRepository,
+		exchangeRateRepository:  exchangeRateRepository,
+		categoryRepository:      categoryRepository,
+		warehouseRepository:     warehouseRepository,
+		stockMovementRepository: stockMovementRepository,
+		reservationRepository:   reservationRepository,
+		allowNegativeStock:      allowNegativeStock,
+		reservationTTL:          reservationTTL,
+	}, nil
 
 }
 
-func (ps *productService) GetProductByID(id int) (domain.ProductResponse, error) {
+// GetProducts devuelve la página pedida de productos. Con currency, los precios se informan
+// en esa moneda.
+func (ps *productService) GetProducts(q query.Query, currency string) (query.Page[any], error) {
+	return ps.SearchProducts(domain.ProductFilter{}, q, currency)
+}
+
+func (ps *productService) GetProductByID(id int, currency string) (domain.ProductResponse, error) {
 
 	product, err := ps.productRepository.Get(id)
 	if err != nil {
 		return domain.ProductResponse{}, err
 	}
 
-	productResponse := domain.ProductResponseFromProductBase(product)
+	rates, err := ps.exchangeRates(currency)
+	if err != nil {
+		return domain.ProductResponse{}, err
+	}
 
-	return productResponse, nil
+	return domain.ProductResponseInCurrency(ps.withCurrentReserved(product, time.Now()), currency, rates)
 
 }
 
-func (ps *productService) SearchProductByPrice(priceGt float64) ([]domain.ProductResponse, error) {
+// SearchProducts devuelve la página de los productos que cumplen el filtro. Los filtros de
+// precio se evalúan en su moneda y, con currency, los precios se informan en esa moneda.
+func (ps *productService) SearchProducts(filter domain.ProductFilter, q query.Query, currency string) (query.Page[any], error) {
 
-	var products, err = ps.productRepository.GetAll()
+	rates, err := ps.exchangeRates(currency)
 	if err != nil {
-		return nil, err
+		return query.Page[any]{}, err
 	}
+	filter.Rates = rates
 
-	filteredProducts := slices.DeleteFunc(slices.Clone(products), func(product domain.Product) bool {
-		return !(product.Price >= priceGt)
-	})
-	if len(filteredProducts) == 0 {
-		return nil, fmt.Errorf("No se encontraron productos con un precio mayor o igual a %f", priceGt)
+	if filter.Text != nil {
+		return ps.searchProductsByText(filter, q, currency)
+	}
+
+	page, err := ps.productRepository.Find(filter, q)
+	if err != nil {
+		return query.Page[any]{}, err
 	}
 
-	productsResponses := domain.ProductResponsesFromProductsBase(filteredProducts)
+	now := time.Now()
+	productsResponses := make([]domain.ProductResponse, len(page.Items))
+	for i, product := range page.Items {
+		if productsResponses[i], err = domain.ProductResponseInCurrency(ps.withCurrentReserved(product, now), currency, rates); err != nil {
+			return query.Page[any]{}, err
+		}
+	}
 
-	return productsResponses, nil
+	// Devolver solo los campos pedidos
+	items, err := query.Project(productsResponses, q.Fields)
+	if err != nil {
+		return query.Page[any]{}, err
+	}
+
+	return query.WithItems(page, items), nil
 
 }
 
-func (ps *productService) validateCodeValue(codeValue string) error {
+// searchProductsByText busca por texto completo e incluye la relevancia de cada producto
+func (ps *productService) searchProductsByText(filter domain.ProductFilter, q query.Query, currency string) (query.Page[any], error) {
 
-	var products, err = ps.productRepository.GetAll()
+	page, err := ps.productRepository.FindText(filter, q)
 	if err != nil {
-		return err
+		return query.Page[any]{}, err
 	}
 
-	var index int = slices.IndexFunc(products, func(product domain.Product) bool {
-		return product.CodeValue == codeValue
-	})
+	now := time.Now()
+	productsResponses := make([]domain.ProductSearchResponse, len(page.Items))
+	for i, scored := range page.Items {
+		productResponse, err := domain.ProductResponseInCurrency(ps.withCurrentReserved(scored.Product, now), currency, filter.Rates)
+		if err != nil {
+			return query.Page[any]{}, err
+		}
+		productsResponses[i] = domain.ProductSearchResponse{
+			ProductResponse: productResponse,
+			Score:           scored.Score,
+		}
+	}
+
+	// La relevancia se incluye siempre, aunque no se la haya pedido entre los campos
+	fields := q.Fields
+	if len(fields) > 0 && !slices.Contains(fields, "score") {
+		fields = append(slices.Clone(fields), "score")
+	}
+
+	items, err := query.Project(productsResponses, fields)
+	if err != nil {
+		return query.Page[any]{}, err
+	}
+
+	return query.WithItems(page, items), nil
+
+}
+
+// exchangeRates devuelve la tabla de cotizaciones vigente y verifica que admita la moneda
+// pedida, si se indicó una
+func (ps *productService) exchangeRates(currency string) (*domain.ExchangeRates, error) {
+
+	rates, err := ps.exchangeRateRepository.Get()
+	if err != nil {
+		return nil, err
+	}
 
-	if index == -1 {
+	if currency != "" && !rates.Supports(currency) {
+		return nil, domain.NewError(domain.ErrUnsupportedCurrency, "La moneda %s no figura en la tabla de cotizaciones", currency)
+	}
+
+	return &rates, nil
+
+}
+
+func (ps *productService) validateCodeValue(codeValue string) error {
+
+	product, err := ps.productRepository.GetByCodeValue(codeValue)
+	if errors.Is(err, domain.ErrNotFound) {
 		return nil
 	}
+	if err != nil {
+		return err
+	}
 
-	return fmt.Errorf("Ya existe un producto registrado con el codigo %s", codeValue)
+	// Los códigos de los productos en la papelera siguen reservados hasta que se purguen
+	if product.IsDeleted() {
+		return domain.NewError(domain.ErrDuplicateCodeValue, "Ya existe un producto en la papelera con el codigo %s", codeValue)
+	}
+
+	return domain.NewError(domain.ErrDuplicateCodeValue, "Ya existe un producto registrado con el codigo %s", codeValue)
 }
 
 func (ps *productService) validateNewProduct(newProduct domain.Product) error {
 
-	err := newProduct.ValidateProduct()
+	err := newProduct.ValidateNewProduct()
 	if err != nil {
 		return err
 	}
@@ -111,119 +259,530 @@ func (ps *productService) validateNewProduct(newProduct domain.Product) error {
 		return err
 	}
 
-	return nil
+	return ps.validateCategories(newProduct.CategoryIDs)
 
 }
 
-func (ps *productService) PostProduct(product domain.ProductRequest) (domain.ProductResponse, error) {
+// validateCategories verifica que existan todas las categorías asignadas al producto
+func (ps *productService) validateCategories(categoryIDs []int) error {
 
-	newProduct, err := domain.ProductFromProductRequest(product)
-	if err != nil {
-		return domain.ProductResponse{}, err
+	var validation domain.ValidationError
+
+	for _, id := range ps.missingCategories(categoryIDs) {
+		validation.Add("category_ids", fmt.Sprintf("No existe la categoría con el ID %d", id))
 	}
 
-	id, err := ps.productRepository.GetNextID()
+	return validation.Err()
+
+}
+
+// missingCategories devuelve las categorías indicadas que no existen
+func (ps *productService) missingCategories(categoryIDs []int) []int {
+
+	var missing []int
+	for _, id := range categoryIDs {
+		if _, err := ps.categoryRepository.Get(id); errors.Is(err, domain.ErrNotFound) {
+			missing = append(missing, id)
+		}
+	}
+
+	return missing
+
+}
+
+func (ps *productService) PostProduct(ctx context.Context, product domain.ProductRequest) (domain.ProductResponse, error) {
+
+	newProduct, err := domain.ProductFromProductRequest(product)
 	if err != nil {
-		return domain.ProductResponse{}, fmt.Errorf("Error al crear un nuevo producto: %s", err.Error())
+		return domain.ProductResponse{}, err
 	}
-	newProduct.ID = id
 
+	// El ID lo asigna el repositorio al insertar el producto
 	if err := ps.validateNewProduct(newProduct); err != nil {
-		return domain.ProductResponse{}, fmt.Errorf("Ocurrió un error durante la creación del nuevo producto: %s", err.Error())
+		return domain.ProductResponse{}, fmt.Errorf("Ocurrió un error durante la creación del nuevo producto: %w", err)
 	}
 
+	// La historia de precios comienza con el precio inicial
+	now := time.Now()
+	newProduct.SchedulePrice(newProduct.Price, now, now)
+
 	productCreated, err := ps.productRepository.Create(newProduct)
 	if err != nil {
-		return domain.ProductResponse{}, fmt.Errorf("Error al crear un nuevo producto: %s", err.Error())
+		return domain.ProductResponse{}, fmt.Errorf("Error al crear un nuevo producto: %w", err)
+	}
+
+	// La cantidad inicial se registra como saldo inicial en el libro de stock; sin él, el
+	// producto se descarta para que su cantidad no quede fuera del libro
+	ps.stockMu.Lock()
+	err = ps.startLedger(productCreated)
+	ps.stockMu.Unlock()
+	if err != nil {
+		if purgeErr := ps.productRepository.Purge(productCreated.ID); purgeErr != nil {
+			log.Printf("Error al descartar el producto %d sin saldo inicial: %s", productCreated.ID, purgeErr)
+		}
+		return domain.ProductResponse{}, fmt.Errorf("Error al registrar el saldo inicial del nuevo producto: %w", err)
 	}
 
+	ps.record(ctx, domain.AuditCreate, nil, &productCreated)
+
 	return domain.ProductResponseFromProductBase(productCreated), nil
 
 }
 
-func (ps *productService) PutProduct(id int, product domain.ProductRequest) (domain.ProductResponse, error) {
+// maxMutationAttempts es la cantidad de intentos de una modificación sin versión esperada
+const maxMutationAttempts = 3
+
+// withRetry ejecuta una modificación que lee el producto y fija la versión leída como la
+// esperada, para que la auditoría registre exactamente el estado reemplazado. Sin versión
+// esperada por el cliente, una modificación concurrente entre la lectura y la escritura se
+// reintenta.
+func withRetry[T any](ifMatch []int, mutate func(ifMatch []int) (T, error)) (T, error) {
+
+	for attempt := 1; ; attempt++ {
+		result, err := mutate(ifMatch)
+
+		if len(ifMatch) == 0 && errors.Is(err, domain.ErrVersionMismatch) && attempt < maxMutationAttempts {
+			continue
+		}
+
+		return result, err
+	}
+
+}
+
+// expectedVersions devuelve las versiones esperadas por el cliente o, si no indicó ninguna,
+// la versión leída
+func expectedVersions(ifMatch []int, product domain.Product) []int {
+
+	if len(ifMatch) == 0 {
+		return []int{product.Version}
+	}
+
+	return ifMatch
+}
+
+// PutProduct reemplaza el producto. Si se indican versiones en ifMatch, la versión actual
+// del producto debe ser una de ellas.
+func (ps *productService) PutProduct(ctx context.Context, id int, product domain.ProductRequest, ifMatch ...int) (domain.ProductResponse, error) {
 
 	productToUpdate, err := domain.ProductFromProductRequest(product)
 	if err != nil {
 		return domain.ProductResponse{}, err
 	}
 
-	if _, err := ps.GetProductByID(id); err != nil {
+	productToUpdate.ID = id
+
+	return withRetry(ifMatch, func(ifMatch []int) (domain.ProductResponse, error) {
+
+		oldProduct, err := ps.productRepository.Get(id)
+		if err != nil {
+			return domain.ProductResponse{}, err
+		}
+
+		if err := productToUpdate.ValidateProduct(); err != nil {
+			return domain.ProductResponse{}, err
+		}
+
+		if err := ps.validateCategories(productToUpdate.CategoryIDs); err != nil {
+			return domain.ProductResponse{}, err
+		}
+
+		productToUpdate.InheritPrices(oldProduct, time.Now())
+
+		if err := productToUpdate.InheritStock(oldProduct); err != nil {
+			return domain.ProductResponse{}, err
+		}
+
+		productUpdated, err := ps.productRepository.Update(productToUpdate, expectedVersions(ifMatch, oldProduct)...)
+		if err != nil {
+			return domain.ProductResponse{}, err
+		}
+
+		ps.record(ctx, domain.AuditUpdate, &oldProduct, &productUpdated)
+
+		return domain.ProductResponseFromProductBase(ps.withCurrentReserved(productUpdated, time.Now())), nil
+	})
+}
+
+// PatchProduct aplica el parche sobre el producto de forma atómica: el resultado se valida
+// completo y, si algo falla, el producto no se modifica. Si se indican versiones en ifMatch,
+// la versión actual del producto debe ser una de ellas.
+func (ps *productService) PatchProduct(ctx context.Context, id int, productPatch patch.Patch, ifMatch ...int) (domain.ProductResponse, error) {
+
+	return withRetry(ifMatch, func(ifMatch []int) (domain.ProductResponse, error) {
+		return ps.patchProduct(ctx, id, productPatch, ifMatch)
+	})
+
+}
+
+func (ps *productService) patchProduct(ctx context.Context, id int, productPatch patch.Patch, ifMatch []int) (domain.ProductResponse, error) {
+
+	oldProduct, err := ps.productRepository.Get(id)
+	if err != nil {
 		return domain.ProductResponse{}, err
 	}
 
-	productToUpdate.ID = id
+	// Aplicar el parche sobre los campos modificables del producto
+	document, err := json.Marshal(domain.ProductRequestFromProduct(oldProduct))
+	if err != nil {
+		return domain.ProductResponse{}, fmt.Errorf("Error al serializar el producto: %s", err.Error())
+	}
 
-	if err := productToUpdate.ValidateProduct(); err != nil {
+	patched, err := productPatch.Apply(document)
+	if err != nil {
+		return domain.ProductResponse{}, err
+	}
+
+	// El resultado se valida como un reemplazo completo, reuniendo todos los campos inválidos
+	var validation domain.ValidationError
+	productRequest, err := domain.ProductRequestFromDocument(patched)
+	if err := validation.Merge(err); err != nil {
+		return domain.ProductResponse{}, err
+	}
+	if err := validation.MergeNew(productRequest.ValidateFull()); err != nil {
+		return domain.ProductResponse{}, err
+	}
+	if err := validation.Err(); err != nil {
 		return domain.ProductResponse{}, err
 	}
 
-	productUpdated, err := ps.productRepository.Update(productToUpdate)
+	productPatched, err := domain.ProductFromProductRequest(productRequest)
 	if err != nil {
 		return domain.ProductResponse{}, err
 	}
+	productPatched.ID = id
+	productPatched.InheritPrices(oldProduct, time.Now())
 
-	return domain.ProductResponseFromProductBase(productUpdated), nil
-}
+	if err := productPatched.InheritStock(oldProduct); err != nil {
+		return domain.ProductResponse{}, err
+	}
 
-func (ps *productService) PatchProduct(id int, product domain.ProductRequest) (domain.ProductResponse, error) {
+	if err := ps.validateCategories(productPatched.CategoryIDs); err != nil {
+		return domain.ProductResponse{}, err
+	}
 
-	oldProduct, err := ps.productRepository.Get(id)
+	productUpdated, err := ps.productRepository.Update(productPatched, expectedVersions(ifMatch, oldProduct)...)
 	if err != nil {
 		return domain.ProductResponse{}, err
 	}
 
-	if product.Name != nil {
-		oldProduct.Name = *product.Name
+	ps.record(ctx, domain.AuditPatch, &oldProduct, &productUpdated)
+
+	return domain.ProductResponseFromProductBase(ps.withCurrentReserved(productUpdated, time.Now())), nil
+
+}
+
+// DeleteProduct envía el producto a la papelera. Si se indican versiones en ifMatch, la
+// versión actual del producto debe ser una de ellas.
+func (ps *productService) DeleteProduct(ctx context.Context, id int, ifMatch ...int) error {
+
+	_, err := withRetry(ifMatch, func(ifMatch []int) (domain.Product, error) {
+
+		oldProduct, err := ps.productRepository.Get(id)
+		if err != nil {
+			return domain.Product{}, err
+		}
+
+		productDeleted, err := ps.productRepository.Delete(id, expectedVersions(ifMatch, oldProduct)...)
+		if err != nil {
+			return domain.Product{}, err
+		}
+
+		ps.record(ctx, domain.AuditDelete, &oldProduct, &productDeleted)
+
+		return productDeleted, nil
+	})
+
+	return err
+
+}
+
+// GetTrash devuelve la página pedida de los productos en la papelera
+func (ps *productService) GetTrash(q query.Query) (query.Page[any], error) {
+
+	page, err := ps.productRepository.FindDeleted(q)
+	if err != nil {
+		return query.Page[any]{}, err
 	}
 
-	if product.Quantity != nil {
-		oldProduct.Quantity = *product.Quantity
+	productsResponses := domain.ProductResponsesFromProductsBase(page.Items)
+
+	// Devolver solo los campos pedidos
+	items, err := query.Project(productsResponses, q.Fields)
+	if err != nil {
+		return query.Page[any]{}, err
 	}
 
-	if (product.CodeValue != nil) && (*product.CodeValue != oldProduct.CodeValue) {
-		if ps.validateCodeValue(*product.CodeValue) != nil {
-			oldProduct.CodeValue = *product.CodeValue
+	return query.WithItems(page, items), nil
+
+}
+
+// RestoreProduct recupera un producto de la papelera. Si se indican versiones en ifMatch,
+// la versión actual del producto debe ser una de ellas.
+func (ps *productService) RestoreProduct(ctx context.Context, id int, ifMatch ...int) (domain.ProductResponse, error) {
+
+	return withRetry(ifMatch, func(ifMatch []int) (domain.ProductResponse, error) {
+
+		oldProduct, err := ps.productRepository.GetIncludingDeleted(id)
+		if err != nil {
+			return domain.ProductResponse{}, err
 		}
-	}
 
-	if product.Expiration != nil {
-		expiration, err := time.Parse("02/01/2006", *product.Expiration)
+		productRestored, err := ps.productRepository.Restore(id, expectedVersions(ifMatch, oldProduct)...)
 		if err != nil {
 			return domain.ProductResponse{}, err
 		}
 
-		oldProduct.Expiration = &expiration
+		ps.record(ctx, domain.AuditRestore, &oldProduct, &productRestored)
+
+		// Las categorías eliminadas mientras el producto estaba en la papelera se le quitan
+		if missing := ps.missingCategories(productRestored.CategoryIDs); len(missing) > 0 {
+			productToUpdate := productRestored.Clone()
+			productToUpdate.CategoryIDs = slices.DeleteFunc(productToUpdate.CategoryIDs, func(id int) bool {
+				return slices.Contains(missing, id)
+			})
+			productUpdated, err := ps.productRepository.Update(productToUpdate, productRestored.Version)
+			if err == nil {
+				ps.record(ctx, domain.AuditUnassignCategory, &productRestored, &productUpdated)
+				productRestored = productUpdated
+			}
+		}
+
+		// La cantidad del producto restaurado vuelve a tomarse del libro de stock
+		if productSynced, err := ps.reconcileProduct(ctx, productRestored); err == nil {
+			productRestored = productSynced
+		}
+
+		return domain.ProductResponseFromProductBase(ps.withCurrentReserved(productRestored, time.Now())), nil
+	})
+
+}
+
+// PurgeProduct elimina el producto de forma definitiva, esté o no en la papelera. Si se
+// indican versiones en ifMatch, la versión actual del producto debe ser una de ellas.
+func (ps *productService) PurgeProduct(ctx context.Context, id int, ifMatch ...int) error {
+
+	_, err := withRetry(ifMatch, func(ifMatch []int) (domain.Product, error) {
+
+		oldProduct, err := ps.productRepository.GetIncludingDeleted(id)
+		if err != nil {
+			return domain.Product{}, err
+		}
+
+		if err := ps.productRepository.Purge(id, expectedVersions(ifMatch, oldProduct)...); err != nil {
+			return domain.Product{}, err
+		}
+
+		ps.record(ctx, domain.AuditPurge, &oldProduct, nil)
+
+		return oldProduct, nil
+	})
+
+	return err
+
+}
+
+// PurgeTrash elimina de forma definitiva los productos que llevan en la papelera más tiempo
+// que la retención indicada y devuelve cuántos se eliminaron
+func (ps *productService) PurgeTrash(ctx context.Context, retention time.Duration) (int, error) {
+
+	purged, err := ps.productRepository.PurgeDeletedBefore(time.Now().Add(-retention))
+	if err != nil {
+		return 0, fmt.Errorf("Error al vaciar la papelera: %w", err)
 	}
 
-	if product.Price != nil {
-		oldProduct.Price = *product.Price
+	for _, product := range purged {
+		ps.record(ctx, domain.AuditPurge, &product, nil)
 	}
 
-	if oldProduct.ValidateProduct() != nil {
+	return len(purged), nil
+
+}
+
+// GetProductAt devuelve el producto con el precio vigente en el instante indicado
+func (ps *productService) GetProductAt(id int, at time.Time) (domain.ProductResponse, error) {
+
+	product, err := ps.productRepository.Get(id)
+	if err != nil {
 		return domain.ProductResponse{}, err
 	}
 
-	productUpdated, err := ps.productRepository.Update(oldProduct)
+	price, ok := product.PriceAt(at)
+	if !ok {
+		return domain.ProductResponse{}, domain.NewError(domain.ErrNotFound, "El producto con el ID %d no tenía precio el %s", id, at.Format(time.RFC3339))
+	}
+	product.Price = price
+
+	return domain.ProductResponseFromProductBase(product), nil
+
+}
+
+// GetProductPrices devuelve la historia de precios del producto, incluidos los programados
+func (ps *productService) GetProductPrices(id int) (domain.ProductPricesResponse, error) {
+
+	product, err := ps.productRepository.Get(id)
 	if err != nil {
-		return domain.ProductResponse{}, err
+		return domain.ProductPricesResponse{}, err
 	}
 
-	return domain.ProductResponseFromProductBase(productUpdated), nil
+	return domain.ProductPricesResponseFromProduct(product, time.Now()), nil
 
 }
 
-func (ps *productService) DeleteProduct(id int) error {
+// SchedulePrice agrega un precio a la historia del producto. Un precio con vigencia futura
+// entra en vigencia automáticamente con ApplyScheduledPrices. Si se indican versiones en
+// ifMatch, la versión actual del producto debe ser una de ellas.
+func (ps *productService) SchedulePrice(ctx context.Context, id int, price domain.PriceRequest, ifMatch ...int) (domain.ProductPricesResponse, error) {
 
-	if _, err := ps.GetProductByID(id); err != nil {
-		return err
+	now := time.Now()
+
+	return withRetry(ifMatch, func(ifMatch []int) (domain.ProductPricesResponse, error) {
+
+		oldProduct, err := ps.productRepository.Get(id)
+		if err != nil {
+			return domain.ProductPricesResponse{}, err
+		}
+
+		// El precio se programa en la moneda del producto
+		point, err := price.Validate(now, oldProduct.Price.Currency)
+		if err != nil {
+			return domain.ProductPricesResponse{}, err
+		}
+
+		productToUpdate := oldProduct.Clone()
+		productToUpdate.SchedulePrice(point.Price, point.EffectiveFrom, now)
+
+		productUpdated, err := ps.productRepository.Update(productToUpdate, expectedVersions(ifMatch, oldProduct)...)
+		if err != nil {
+			return domain.ProductPricesResponse{}, err
+		}
+
+		ps.record(ctx, domain.AuditSchedulePrice, &oldProduct, &productUpdated)
+
+		return domain.ProductPricesResponseFromProduct(productUpdated, now), nil
+	})
+
+}
+
+// ApplyScheduledPrices pone en vigencia los precios programados que ya alcanzaron su fecha y
+// devuelve cuántos productos cambiaron de precio. Un producto modificado en simultáneo se
+// actualiza en la próxima ejecución.
+func (ps *productService) ApplyScheduledPrices(ctx context.Context) (int, error) {
+
+	products, err := ps.productRepository.GetAll()
+	if err != nil {
+		return 0, err
+	}
+
+	now := time.Now()
+	applied := 0
+
+	for _, product := range products {
+		productToUpdate := product.Clone()
+		if !productToUpdate.ApplyPrices(now) {
+			continue
+		}
+
+		productUpdated, err := ps.productRepository.Update(productToUpdate, product.Version)
+		if errors.Is(err, domain.ErrVersionMismatch) || errors.Is(err, domain.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return applied, fmt.Errorf("Error al aplicar el precio programado del producto %d: %w", product.ID, err)
+		}
+
+		ps.record(ctx, domain.AuditApplyPrice, &product, &productUpdated)
+		applied++
 	}
 
-	err := ps.productRepository.Delete(id)
+	return applied, nil
+
+}
+
+// UnassignCategory quita la categoría de todos los productos que la tienen asignada y
+// devuelve cuántos productos cambiaron. Un producto modificado en simultáneo se vuelve a leer.
+func (ps *productService) UnassignCategory(ctx context.Context, categoryID int) (int, error) {
+
+	products, err := ps.productRepository.GetAll()
 	if err != nil {
-		return err
+		return 0, err
+	}
+
+	unassigned := 0
+
+	for _, product := range products {
+		if !product.InCategory([]int{categoryID}) {
+			continue
+		}
+
+		_, err := withRetry(nil, func(ifMatch []int) (domain.Product, error) {
+
+			oldProduct, err := ps.productRepository.Get(product.ID)
+			if err != nil {
+				return domain.Product{}, err
+			}
+
+			productToUpdate := oldProduct.Clone()
+			productToUpdate.CategoryIDs = slices.DeleteFunc(productToUpdate.CategoryIDs, func(id int) bool {
+				return id == categoryID
+			})
+
+			productUpdated, err := ps.productRepository.Update(productToUpdate, oldProduct.Version)
+			if err != nil {
+				return domain.Product{}, err
+			}
+
+			ps.record(ctx, domain.AuditUnassignCategory, &oldProduct, &productUpdated)
+
+			return productUpdated, nil
+		})
+		if errors.Is(err, domain.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return unassigned, fmt.Errorf("Error al quitar la categoría %d del producto %d: %w", categoryID, product.ID, err)
+		}
+
+		unassigned++
+	}
+
+	return unassigned, nil
+
+}
+
+// record registra la mutación en la auditoría. El actor y el ID de la solicitud se obtienen
+// del contexto; sin identidad autenticada el actor es el sistema. La mutación ya fue
+// persistida, por lo que un fallo al auditar se informa en el log sin revertirla.
+func (ps *productService) record(ctx context.Context, operation domain.AuditOperation, before *domain.Product, after *domain.Product) {
+
+	entry := domain.AuditEntry{
+		Timestamp: time.Now().UTC(),
+		Actor:     actorFromContext(ctx),
+		RequestID: web.RequestIDFromContext(ctx),
+		Operation: operation,
+		Changes:   domain.DiffProducts(before, after),
+	}
+
+	product := after
+	if product == nil {
+		product = before
+	}
+	entry.ProductID = product.ID
+	entry.CodeValue = product.CodeValue
+
+	if _, err := ps.auditRepository.Append(entry); err != nil {
+		log.Printf("Error al auditar la operación %s del producto %d: %s", operation, entry.ProductID, err)
+	}
+
+}
+
+// actorFromContext devuelve el nombre de la identidad autenticada o, sin ella, el sistema
+func actorFromContext(ctx context.Context) string {
+
+	if identity, ok := auth.IdentityFromContext(ctx); ok {
+		return identity.Name
 	}
 
-	return nil
+	return domain.AuditActorSystem
 
 }
@@ -0,0 +1,227 @@
+package service
+
+import (
+	"PRACTICAS-GO-WEB/internal/domain"
+	"PRACTICAS-GO-WEB/internal/patch"
+	"PRACTICAS-GO-WEB/internal/repository"
+
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// memoryStorage guarda en memoria el último estado escrito, serializado como JSON. Mientras
+// failWrites está activo, las escrituras fallan.
+type memoryStorage struct {
+	mu         sync.Mutex
+	data       []byte
+	failWrites bool
+}
+
+func (ms *memoryStorage) Read(emptyListEntity any) error {
+
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	if ms.data == nil {
+		return nil
+	}
+
+	return json.Unmarshal(ms.data, emptyListEntity)
+}
+
+func (ms *memoryStorage) Write(emptyListEntity any) error {
+
+	data, err := json.Marshal(emptyListEntity)
+	if err != nil {
+		return err
+	}
+
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	if ms.failWrites {
+		return errors.New("escritura rechazada")
+	}
+	ms.data = data
+
+	return nil
+}
+
+// memoryLog agrega en memoria los registros serializados como JSON
+type memoryLog struct {
+	mu      sync.Mutex
+	records []json.RawMessage
+}
+
+func (ml *memoryLog) Read(emptyListEntity any) error {
+
+	ml.mu.Lock()
+	defer ml.mu.Unlock()
+
+	data, err := json.Marshal(ml.records)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(data, emptyListEntity)
+}
+
+func (ml *memoryLog) Append(entity any) error {
+
+	data, err := json.Marshal(entity)
+	if err != nil {
+		return err
+	}
+
+	ml.mu.Lock()
+	defer ml.mu.Unlock()
+
+	ml.records = append(ml.records, data)
+
+	return nil
+}
+
+// setFailWrites hace que las escrituras siguientes fallen o vuelvan a funcionar
+func (ms *memoryStorage) setFailWrites(failWrites bool) {
+
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	ms.failWrites = failWrites
+
+}
+
+// newTestProductService crea el servicio de productos sobre almacenamientos en memoria; los
+// productos se guardan en products. El libro de stock puede traer movimientos previos.
+func newTestProductService(t *testing.T, products *memoryStorage, movements ...domain.StockMovement) *productService {
+
+	t.Helper()
+
+	ledger := &memoryLog{}
+	for _, movement := range movements {
+		if err := ledger.Append(movement); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	pr, err := repository.NewProductRepository(products)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ar, err := repository.NewAuditRepository(&memoryLog{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rr, err := repository.NewExchangeRateRepository(&memoryStorage{data: []byte(`{"base":"USD","rates":{}}`)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cr, err := repository.NewCategoryRepository(&memoryStorage{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	wr, err := repository.NewWarehouseRepository(&memoryStorage{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	mr, err := repository.NewStockMovementRepository(ledger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resr, err := repository.NewReservationRepository(&memoryStorage{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ps, err := NewProductService(pr, ar, rr, cr, wr, mr, resr, false, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return ps
+}
+
+// productRequest decodifica la solicitud de un producto escrita como JSON
+func productRequest(t *testing.T, document string) domain.ProductRequest {
+
+	t.Helper()
+
+	var request domain.ProductRequest
+	if err := json.Unmarshal([]byte(document), &request); err != nil {
+		t.Fatal(err)
+	}
+
+	return request
+}
+
+func TestEditSoldOutProduct(t *testing.T) {
+
+	ps := newTestProductService(t, &memoryStorage{})
+	ctx := context.Background()
+
+	created, err := ps.PostProduct(ctx, productRequest(t, `{"name":"Yerba","quantity":5,"code_value":"YER-1","is_published":true,"price":10}`))
+	if err != nil {
+		t.Fatalf("PostProduct: %v", err)
+	}
+
+	saleType, quantity := string(domain.MovementSale), 5
+	if _, err := ps.RecordStockMovement(ctx, created.ID, domain.StockMovementRequest{Type: &saleType, Quantity: &quantity}); err != nil {
+		t.Fatalf("RecordStockMovement: %v", err)
+	}
+
+	updated, err := ps.PutProduct(ctx, created.ID, productRequest(t, `{"name":"Yerba suave","quantity":0,"code_value":"YER-1","is_published":true,"price":10}`))
+	if err != nil {
+		t.Fatalf("PutProduct de un producto agotado: %v", err)
+	}
+	if updated.Name != "Yerba suave" || updated.Quantity != 0 {
+		t.Fatalf("PutProduct devolvió %q con cantidad %d", updated.Name, updated.Quantity)
+	}
+
+	mergePatch, err := patch.DecodeMergePatch([]byte(`{"name":"X"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	patched, err := ps.PatchProduct(ctx, created.ID, mergePatch)
+	if err != nil {
+		t.Fatalf("PatchProduct de un producto agotado: %v", err)
+	}
+	if patched.Name != "X" || patched.Quantity != 0 {
+		t.Fatalf("PatchProduct devolvió %q con cantidad %d", patched.Name, patched.Quantity)
+	}
+
+}
+
+func TestCreateProductRequiresQuantity(t *testing.T) {
+
+	ps := newTestProductService(t, &memoryStorage{})
+
+	_, err := ps.PostProduct(context.Background(), productRequest(t, `{"name":"Yerba","quantity":0,"code_value":"YER-1","is_published":true,"price":10}`))
+	if !errors.Is(err, domain.ErrValidation) {
+		t.Fatalf("PostProduct con cantidad cero devolvió %v, se esperaba un error de validación", err)
+	}
+
+}
+
+func TestCreateProductNeverAdoptsExistingLedger(t *testing.T) {
+
+	// Movimientos de un producto purgado con el ID que recibirá el próximo producto
+	ps := newTestProductService(t, &memoryStorage{}, domain.StockMovement{ID: 1, ProductID: 1, Type: domain.MovementAdjustment, Quantity: 7, Reason: domain.OpeningBalanceReason, Actor: domain.AuditActorSystem})
+
+	_, err := ps.PostProduct(context.Background(), productRequest(t, `{"name":"Yerba","quantity":3,"code_value":"YER-1","is_published":true,"price":10}`))
+	if err == nil {
+		t.Fatal("PostProduct adoptó el libro de stock de otro producto")
+	}
+
+	products, err := ps.productRepository.GetAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(products) != 0 {
+		t.Fatalf("el producto sin saldo inicial no se descartó: %+v", products)
+	}
+
+}
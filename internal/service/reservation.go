@@ -0,0 +1,300 @@
+package service
+
+import (
+	"PRACTICAS-GO-WEB/internal/domain"
+	"PRACTICAS-GO-WEB/internal/query"
+
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+)
+
+// ReserveStock retiene existencias disponibles del producto durante el plazo pedido. La
+// reserva se registra con stockMu tomado, como los movimientos, para que dos reservas o una
+// reserva y una venta simultáneas nunca comprometan más existencias de las disponibles.
+func (ps *productService) ReserveStock(ctx context.Context, id int, reservationRequest domain.ReservationRequest) (domain.Reservation, error) {
+
+	reservation, ttl, err := reservationRequest.Validate(ps.reservationTTL)
+	if err != nil {
+		return domain.Reservation{}, err
+	}
+
+	if reservation.WarehouseID != 0 {
+		if _, err := ps.warehouseRepository.Get(reservation.WarehouseID); errors.Is(err, domain.ErrNotFound) {
+			var validation domain.ValidationError
+			validation.Add("warehouse_id", fmt.Sprintf("No existe el depósito con el ID %d", reservation.WarehouseID))
+			return domain.Reservation{}, validation.Err()
+		}
+	}
+
+	ps.stockMu.Lock()
+	defer ps.stockMu.Unlock()
+
+	product, err := ps.productRepository.Get(id)
+	if err != nil {
+		return domain.Reservation{}, err
+	}
+
+	if err := ps.openLedger(product); err != nil {
+		return domain.Reservation{}, err
+	}
+
+	now := time.Now().UTC()
+	balances, _ := ps.stockMovementRepository.Balances(id)
+	reserved := ps.reservationRepository.Reserved(id, now)
+
+	if available := balances[reservation.WarehouseID] - reserved[reservation.WarehouseID]; available < reservation.Quantity {
+		if reservation.WarehouseID == 0 {
+			return domain.Reservation{}, domain.NewError(domain.ErrConflict, "El producto %d tiene %d unidades disponibles sin depósito asignado", id, max(available, 0))
+		}
+		return domain.Reservation{}, domain.NewError(domain.ErrConflict, "El producto %d tiene %d unidades disponibles en el depósito %d", id, max(available, 0), reservation.WarehouseID)
+	}
+
+	reservation.ProductID = id
+	reservation.Status = domain.ReservationActive
+	reservation.Actor = actorFromContext(ctx)
+	reservation.CreatedAt = now
+	reservation.ExpiresAt = now.Add(ttl)
+
+	reservationCreated, err := ps.reservationRepository.Create(reservation)
+	if err != nil {
+		return domain.Reservation{}, err
+	}
+
+	if _, err := ps.syncStock(ctx, id, domain.AuditReserveStock); err != nil {
+		log.Printf("Error al actualizar la existencia reservada del producto %d: %s", id, err)
+	}
+
+	return reservationCreated, nil
+
+}
+
+// GetReservations devuelve la página pedida de las reservas del producto que cumplen el
+// filtro, en el orden en que se crearon
+func (ps *productService) GetReservations(id int, filter domain.ReservationFilter, q query.Query) (query.Page[domain.Reservation], error) {
+
+	if _, err := ps.productRepository.GetIncludingDeleted(id); err != nil {
+		return query.Page[domain.Reservation]{}, err
+	}
+
+	filter.ProductID = &id
+
+	return ps.reservationRepository.Find(filter, q, time.Now())
+
+}
+
+// GetReservation devuelve la reserva del producto
+func (ps *productService) GetReservation(id int, reservationID int) (domain.Reservation, error) {
+
+	reservation, err := ps.productReservation(id, reservationID)
+	if err != nil {
+		return domain.Reservation{}, err
+	}
+
+	return reservation.At(time.Now()), nil
+
+}
+
+// ConfirmReservation convierte la reserva en una venta del producto. La venta sale del
+// depósito de la reserva y de los lotes que vencen primero. Una vez registrada la venta, la
+// confirmación se informa como exitosa aunque no se puedan actualizar la reserva o el producto.
+func (ps *productService) ConfirmReservation(ctx context.Context, id int, reservationID int) (domain.Reservation, error) {
+
+	ps.stockMu.Lock()
+	defer ps.stockMu.Unlock()
+
+	reservation, err := ps.activeReservation(ctx, id, reservationID)
+	if err != nil {
+		return domain.Reservation{}, err
+	}
+
+	product, err := ps.productRepository.Get(id)
+	if err != nil {
+		return domain.Reservation{}, err
+	}
+
+	// La reserva se cierra antes de registrar la venta para que la venta pueda usar la
+	// existencia que retenía; si la venta falla, la reserva vuelve a quedar activa
+	confirmed := reservation
+	confirmed.Close(domain.ReservationConfirmed, time.Now().UTC())
+	if _, err := ps.reservationRepository.Update(confirmed); err != nil {
+		return domain.Reservation{}, err
+	}
+
+	reference := reservation.Reference
+	if reference == "" {
+		reference = fmt.Sprintf("reserva %d", reservation.ID)
+	}
+
+	movement, err := ps.appendMovement(ctx, product, domain.StockMovement{
+		Type:        domain.MovementSale,
+		Quantity:    -reservation.Quantity,
+		WarehouseID: reservation.WarehouseID,
+		Reason:      fmt.Sprintf("Reserva %d confirmada", reservation.ID),
+		Reference:   reference,
+	})
+	if err != nil {
+		if _, rollbackErr := ps.reservationRepository.Update(reservation); rollbackErr != nil {
+			log.Printf("Error al reactivar la reserva %d: %s", reservation.ID, rollbackErr)
+		}
+		return domain.Reservation{}, err
+	}
+
+	// La venta ya está en el libro de stock y la reserva cerrada: informar un error haría que el
+	// cliente repitiera una confirmación ya aplicada. El producto lo corrige la próxima
+	// sincronización o la conciliación.
+	confirmed.MovementID = movement.ID
+	if updated, err := ps.reservationRepository.Update(confirmed); err != nil {
+		log.Printf("La venta %d se registró pero no se pudo actualizar la reserva %d: %s", movement.ID, reservation.ID, err)
+	} else {
+		confirmed = updated
+	}
+
+	if _, err := ps.syncStock(ctx, id, domain.AuditConfirmReservation); err != nil {
+		log.Printf("La venta %d se registró pero no se pudo actualizar el producto %d: %s", movement.ID, id, err)
+	}
+
+	return confirmed, nil
+
+}
+
+// ReleaseReservation libera la reserva antes de que venza y devuelve sus existencias a las
+// disponibles
+func (ps *productService) ReleaseReservation(ctx context.Context, id int, reservationID int) (domain.Reservation, error) {
+
+	ps.stockMu.Lock()
+	defer ps.stockMu.Unlock()
+
+	reservation, err := ps.activeReservation(ctx, id, reservationID)
+	if err != nil {
+		return domain.Reservation{}, err
+	}
+
+	reservation.Close(domain.ReservationReleased, time.Now().UTC())
+	if reservation, err = ps.reservationRepository.Update(reservation); err != nil {
+		return domain.Reservation{}, err
+	}
+
+	ps.syncReserved(ctx, id, domain.AuditReleaseReservation)
+
+	return reservation, nil
+
+}
+
+// ExpireReservations cierra las reservas cuyo plazo terminó y devuelve cuántas vencieron. Sus
+// existencias ya no estaban retenidas; cerrarlas actualiza la existencia reservada informada
+// por cada producto.
+func (ps *productService) ExpireReservations(ctx context.Context) (int, error) {
+
+	ps.stockMu.Lock()
+	defer ps.stockMu.Unlock()
+
+	now := time.Now().UTC()
+	products := make(map[int]bool)
+	expired := 0
+
+	for _, reservation := range ps.reservationRepository.Due(now) {
+		reservation.Close(domain.ReservationExpired, now)
+		if _, err := ps.reservationRepository.Update(reservation); err != nil {
+			return expired, fmt.Errorf("Error al cerrar la reserva vencida %d: %w", reservation.ID, err)
+		}
+		products[reservation.ProductID] = true
+		expired++
+	}
+
+	for productID := range products {
+		ps.syncReserved(ctx, productID, domain.AuditExpireReservation)
+	}
+
+	return expired, nil
+
+}
+
+// productReservation devuelve la reserva si pertenece al producto
+func (ps *productService) productReservation(id int, reservationID int) (domain.Reservation, error) {
+
+	reservation, err := ps.reservationRepository.Get(reservationID)
+	if err != nil {
+		return domain.Reservation{}, err
+	}
+
+	if reservation.ProductID != id {
+		return domain.Reservation{}, domain.NewError(domain.ErrNotFound, "No se encontró la reserva con el ID %d del producto %d", reservationID, id)
+	}
+
+	return reservation, nil
+
+}
+
+// activeReservation devuelve la reserva del producto si todavía retiene existencias. Una
+// reserva cuyo plazo terminó se cierra como vencida. Debe llamarse con stockMu tomado.
+func (ps *productService) activeReservation(ctx context.Context, id int, reservationID int) (domain.Reservation, error) {
+
+	reservation, err := ps.productReservation(id, reservationID)
+	if err != nil {
+		return domain.Reservation{}, err
+	}
+
+	now := time.Now().UTC()
+	if reservation.Status == domain.ReservationActive && !reservation.IsHeld(now) {
+		reservation.Close(domain.ReservationExpired, now)
+		if _, err := ps.reservationRepository.Update(reservation); err != nil {
+			return domain.Reservation{}, err
+		}
+		ps.syncReserved(ctx, id, domain.AuditExpireReservation)
+	}
+
+	if reservation.Status != domain.ReservationActive {
+		return domain.Reservation{}, domain.NewError(domain.ErrConflict, "La reserva %d no está activa, su estado es %s", reservation.ID, reservation.Status)
+	}
+
+	return reservation, nil
+
+}
+
+// syncReserved actualiza la existencia reservada del producto tras cerrar una reserva. El
+// producto puede estar en la papelera o eliminado; sus reservas se cierran igual. Debe
+// llamarse con stockMu tomado.
+func (ps *productService) syncReserved(ctx context.Context, id int, operation domain.AuditOperation) {
+
+	if _, err := ps.syncStock(ctx, id, operation); err != nil && !errors.Is(err, domain.ErrNotFound) {
+		log.Printf("Error al actualizar la existencia reservada del producto %d: %s", id, err)
+	}
+
+}
+
+// withCurrentReserved devuelve el producto con la existencia reservada en el instante now. La
+// almacenada se actualiza al crear y cerrar reservas, pero una reserva vencida sigue contando
+// en ella hasta que el barrido la cierra, aunque ya no retenga existencias.
+func (ps *productService) withCurrentReserved(product domain.Product, now time.Time) domain.Product {
+
+	product.Reserved = totalQuantity(ps.reservationRepository.Reserved(product.ID, now))
+
+	return product
+
+}
+
+// checkReserved verifica que el movimiento no deje en el depósito del que descuenta menos
+// existencias que las reservadas. Debe llamarse con stockMu tomado.
+func (ps *productService) checkReserved(movement domain.StockMovement) error {
+
+	reserved := ps.reservationRepository.Reserved(movement.ProductID, time.Now())
+	if reserved[movement.WarehouseID] == 0 {
+		return nil
+	}
+
+	balances, _ := ps.stockMovementRepository.Balances(movement.ProductID)
+	movement.Apply(balances)
+
+	if balance := balances[movement.WarehouseID]; balance < reserved[movement.WarehouseID] {
+		if movement.WarehouseID == 0 {
+			return domain.NewError(domain.ErrConflict, "El producto %d tiene %d unidades reservadas sin depósito asignado y su existencia quedaría en %d", movement.ProductID, reserved[0], balance)
+		}
+		return domain.NewError(domain.ErrConflict, "El producto %d tiene %d unidades reservadas en el depósito %d y su existencia quedaría en %d", movement.ProductID, reserved[movement.WarehouseID], movement.WarehouseID, balance)
+	}
+
+	return nil
+
+}
@@ -0,0 +1,93 @@
+package service
+
+import (
+	"PRACTICAS-GO-WEB/internal/domain"
+
+	"context"
+	"testing"
+	"time"
+)
+
+func TestConfirmReservationSucceedsWhenProductSyncFails(t *testing.T) {
+
+	products := &memoryStorage{}
+	ps := newTestProductService(t, products)
+	ctx := context.Background()
+
+	created, err := ps.PostProduct(ctx, productRequest(t, `{"name":"Yerba","quantity":5,"code_value":"YER-1","is_published":true,"price":10}`))
+	if err != nil {
+		t.Fatalf("PostProduct: %v", err)
+	}
+
+	quantity := 2
+	reservation, err := ps.ReserveStock(ctx, created.ID, domain.ReservationRequest{Quantity: &quantity})
+	if err != nil {
+		t.Fatalf("ReserveStock: %v", err)
+	}
+
+	// La venta llega al libro, pero el producto no se puede almacenar
+	products.setFailWrites(true)
+	confirmed, err := ps.ConfirmReservation(ctx, created.ID, reservation.ID)
+	if err != nil {
+		t.Fatalf("ConfirmReservation informó un error para una venta registrada: %v", err)
+	}
+	if confirmed.Status != domain.ReservationConfirmed || confirmed.MovementID == 0 {
+		t.Fatalf("ConfirmReservation devolvió la reserva %+v", confirmed)
+	}
+
+	stored, err := ps.reservationRepository.Get(reservation.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.MovementID != confirmed.MovementID {
+		t.Fatalf("la reserva almacenada tiene el movimiento %d, se esperaba %d", stored.MovementID, confirmed.MovementID)
+	}
+	if balances, _ := ps.stockMovementRepository.Balances(created.ID); totalQuantity(balances) != 3 {
+		t.Fatalf("el libro de stock quedó en %v, se esperaban 3 unidades", balances)
+	}
+
+}
+
+func TestExpiredReservationIsNotReportedBeforeTheSweep(t *testing.T) {
+
+	ps := newTestProductService(t, &memoryStorage{})
+	ctx := context.Background()
+
+	created, err := ps.PostProduct(ctx, productRequest(t, `{"name":"Yerba","quantity":5,"code_value":"YER-1","is_published":true,"price":10}`))
+	if err != nil {
+		t.Fatalf("PostProduct: %v", err)
+	}
+
+	quantity, ttl := 2, "20ms"
+	if _, err := ps.ReserveStock(ctx, created.ID, domain.ReservationRequest{Quantity: &quantity, TTL: &ttl}); err != nil {
+		t.Fatalf("ReserveStock: %v", err)
+	}
+
+	product, err := ps.GetProductByID(created.ID, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if product.Reserved != 2 || product.Available != 3 {
+		t.Fatalf("con la reserva vigente se informan %d reservadas y %d disponibles", product.Reserved, product.Available)
+	}
+
+	// Sin ejecutar el barrido de reservas vencidas
+	time.Sleep(50 * time.Millisecond)
+
+	product, err = ps.GetProductByID(created.ID, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if product.Reserved != 0 || product.Available != 5 {
+		t.Fatalf("con la reserva vencida se informan %d reservadas y %d disponibles", product.Reserved, product.Available)
+	}
+
+	stock, err := ps.GetProductStock(created.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stock.Reserved != 0 || stock.Available != 5 {
+		t.Fatalf("las existencias informan %d reservadas y %d disponibles", stock.Reserved, stock.Available)
+	}
+
+}
@@ -0,0 +1,469 @@
+package service
+
+import (
+	"PRACTICAS-GO-WEB/internal/domain"
+	"PRACTICAS-GO-WEB/internal/query"
+	"PRACTICAS-GO-WEB/pkg/web"
+
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"maps"
+	"slices"
+	"time"
+)
+
+// GetProductStock devuelve las existencias del producto en cada depósito
+func (ps *productService) GetProductStock(id int) (domain.ProductStockResponse, error) {
+
+	product, err := ps.productRepository.Get(id)
+	if err != nil {
+		return domain.ProductStockResponse{}, err
+	}
+
+	return domain.ProductStockResponseFromProduct(ps.withCurrentReserved(product, time.Now()), ps.warehouseNames()), nil
+
+}
+
+// SetStock fija la existencia del producto en el depósito registrando en el libro de stock el
+// ajuste necesario. Si se indican versiones en ifMatch, la versión actual del producto debe
+// ser una de ellas.
+func (ps *productService) SetStock(ctx context.Context, id int, warehouseID int, stock domain.StockRequest, ifMatch ...int) (domain.ProductStockResponse, error) {
+
+	quantity, err := stock.Validate()
+	if err != nil {
+		return domain.ProductStockResponse{}, err
+	}
+
+	if _, err := ps.warehouseRepository.Get(warehouseID); err != nil {
+		return domain.ProductStockResponse{}, err
+	}
+
+	ps.stockMu.Lock()
+	defer ps.stockMu.Unlock()
+
+	product, err := ps.productForMovement(id, ifMatch)
+	if err != nil {
+		return domain.ProductStockResponse{}, err
+	}
+
+	balances, _ := ps.stockMovementRepository.Balances(id)
+	if difference := quantity - balances[warehouseID]; difference != 0 {
+		movement := domain.StockMovement{
+			Type:        domain.MovementAdjustment,
+			Quantity:    difference,
+			WarehouseID: warehouseID,
+			Reason:      fmt.Sprintf("Existencia fijada en %d unidades", quantity),
+		}
+		if product, err = ps.applyMovement(ctx, product, movement, domain.AuditSetStock); err != nil {
+			return domain.ProductStockResponse{}, err
+		}
+	}
+
+	return domain.ProductStockResponseFromProduct(ps.withCurrentReserved(product, time.Now()), ps.warehouseNames()), nil
+
+}
+
+// TransferStock mueve existencias del producto entre dos depósitos. La transferencia es un
+// único movimiento del libro de stock, por lo que se aplica completa o no se aplica, y nunca
+// deja en negativo el depósito de origen. Si se indican versiones en ifMatch, la versión
+// actual del producto debe ser una de ellas.
+func (ps *productService) TransferStock(ctx context.Context, id int, transfer domain.TransferRequest, ifMatch ...int) (domain.ProductStockResponse, error) {
+
+	if err := transfer.Validate(); err != nil {
+		return domain.ProductStockResponse{}, err
+	}
+
+	var validation domain.ValidationError
+	if _, err := ps.warehouseRepository.Get(*transfer.FromWarehouseID); errors.Is(err, domain.ErrNotFound) {
+		validation.Add("from_warehouse_id", fmt.Sprintf("No existe el depósito con el ID %d", *transfer.FromWarehouseID))
+	}
+	if _, err := ps.warehouseRepository.Get(*transfer.ToWarehouseID); errors.Is(err, domain.ErrNotFound) {
+		validation.Add("to_warehouse_id", fmt.Sprintf("No existe el depósito con el ID %d", *transfer.ToWarehouseID))
+	}
+	if err := validation.Err(); err != nil {
+		return domain.ProductStockResponse{}, err
+	}
+
+	ps.stockMu.Lock()
+	defer ps.stockMu.Unlock()
+
+	product, err := ps.productForMovement(id, ifMatch)
+	if err != nil {
+		return domain.ProductStockResponse{}, err
+	}
+
+	movement := domain.StockMovement{
+		Type:          domain.MovementTransfer,
+		Quantity:      *transfer.Quantity,
+		WarehouseID:   *transfer.FromWarehouseID,
+		ToWarehouseID: *transfer.ToWarehouseID,
+	}
+	if product, err = ps.applyMovement(ctx, product, movement, domain.AuditTransferStock); err != nil {
+		return domain.ProductStockResponse{}, err
+	}
+
+	return domain.ProductStockResponseFromProduct(ps.withCurrentReserved(product, time.Now()), ps.warehouseNames()), nil
+
+}
+
+// GetWarehouseStock devuelve la página pedida de los productos con existencias en el depósito
+func (ps *productService) GetWarehouseStock(warehouseID int, q query.Query) (query.Page[domain.WarehouseStockResponse], error) {
+
+	page, err := ps.productRepository.Find(domain.ProductFilter{WarehouseID: &warehouseID}, q)
+	if err != nil {
+		return query.Page[domain.WarehouseStockResponse]{}, err
+	}
+
+	stock := make([]domain.WarehouseStockResponse, len(page.Items))
+	for i, product := range page.Items {
+		stock[i] = domain.WarehouseStockResponseFromProduct(product, warehouseID)
+	}
+
+	return query.WithItems(page, stock), nil
+
+}
+
+// RecordStockMovement registra un movimiento en el libro de stock del producto y devuelve el
+// movimiento con la existencia resultante. Salvo que se permitan existencias negativas, un
+// egreso que supera la existencia disponible se rechaza. Si se indican versiones en ifMatch,
+// la versión actual del producto debe ser una de ellas. Una vez registrado, el movimiento se
+// informa como exitoso aunque no se pueda actualizar el producto.
+func (ps *productService) RecordStockMovement(ctx context.Context, id int, movementRequest domain.StockMovementRequest, ifMatch ...int) (domain.StockMovement, error) {
+
+	movement, err := movementRequest.Validate()
+	if err != nil {
+		return domain.StockMovement{}, err
+	}
+
+	if movement.WarehouseID != 0 {
+		if _, err := ps.warehouseRepository.Get(movement.WarehouseID); errors.Is(err, domain.ErrNotFound) {
+			var validation domain.ValidationError
+			validation.Add("warehouse_id", fmt.Sprintf("No existe el depósito con el ID %d", movement.WarehouseID))
+			return domain.StockMovement{}, validation.Err()
+		}
+	}
+
+	ps.stockMu.Lock()
+	defer ps.stockMu.Unlock()
+
+	product, err := ps.productForMovement(id, ifMatch)
+	if err != nil {
+		return domain.StockMovement{}, err
+	}
+
+	if err := ps.openLedger(product); err != nil {
+		return domain.StockMovement{}, err
+	}
+
+	recorded, err := ps.appendMovement(ctx, product, movement)
+	if err != nil {
+		return domain.StockMovement{}, err
+	}
+
+	// El libro de stock prevalece: si el producto no se actualiza ahora, lo corrige la próxima
+	// sincronización o la conciliación. Informar el error haría que el cliente repitiera un
+	// movimiento ya registrado.
+	if _, err := ps.syncStock(ctx, product.ID, domain.AuditStockMovement); err != nil {
+		log.Printf("El movimiento %d se registró pero no se pudo actualizar el producto %d: %s", recorded.ID, product.ID, err)
+	}
+
+	return recorded, nil
+
+}
+
+// GetStockMovements devuelve la página pedida de los movimientos del producto que cumplen el
+// filtro, en orden cronológico. El libro se conserva aunque el producto esté en la papelera.
+func (ps *productService) GetStockMovements(id int, filter domain.StockMovementFilter, q query.Query) (query.Page[domain.StockMovement], error) {
+
+	if _, err := ps.productRepository.GetIncludingDeleted(id); err != nil {
+		return query.Page[domain.StockMovement]{}, err
+	}
+
+	filter.ProductID = &id
+
+	return ps.stockMovementRepository.Find(filter, q)
+
+}
+
+// ReconcileStock registra el saldo inicial de los productos que todavía no tienen movimientos
+// y corrige la cantidad de los productos que no coincide con el libro de stock, que prevalece.
+// Devuelve cuántos productos se corrigieron.
+func (ps *productService) ReconcileStock(ctx context.Context) (int, error) {
+
+	products, err := ps.productRepository.GetAll()
+	if err != nil {
+		return 0, err
+	}
+
+	reconciled := 0
+	for _, product := range products {
+		productSynced, err := ps.reconcileProduct(ctx, product)
+		if err != nil {
+			return reconciled, fmt.Errorf("Error al conciliar las existencias del producto %d: %w", product.ID, err)
+		}
+		if productSynced.Version != product.Version {
+			reconciled++
+		}
+	}
+
+	return reconciled, nil
+
+}
+
+// reconcileProduct registra el saldo inicial del producto si no tiene movimientos y alinea su
+// cantidad con el libro de stock
+func (ps *productService) reconcileProduct(ctx context.Context, product domain.Product) (domain.Product, error) {
+
+	ps.stockMu.Lock()
+	defer ps.stockMu.Unlock()
+
+	if err := ps.openLedger(product); err != nil {
+		return domain.Product{}, err
+	}
+
+	return ps.syncStock(ctx, product.ID, domain.AuditStockMovement)
+
+}
+
+// productForMovement devuelve el producto sobre el que se registra un movimiento, verificando
+// la versión esperada por el cliente
+func (ps *productService) productForMovement(id int, ifMatch []int) (domain.Product, error) {
+
+	product, err := ps.productRepository.Get(id)
+	if err != nil {
+		return domain.Product{}, err
+	}
+
+	if len(ifMatch) > 0 && !slices.Contains(ifMatch, product.Version) {
+		return domain.Product{}, domain.NewError(domain.ErrVersionMismatch, "El producto con el ID %d fue modificado, su versión actual es %d", product.ID, product.Version)
+	}
+
+	return product, nil
+
+}
+
+// openLedger registra como saldo inicial la existencia de un producto que todavía no tiene
+// movimientos en el libro de stock. Debe llamarse con stockMu tomado.
+func (ps *productService) openLedger(product domain.Product) error {
+
+	if _, exists := ps.stockMovementRepository.Balances(product.ID); exists {
+		return nil
+	}
+
+	for _, movement := range domain.OpeningMovements(product) {
+		movement.Timestamp = time.Now().UTC()
+		movement.Actor = domain.AuditActorSystem
+		if _, err := ps.stockMovementRepository.Append(movement, false); err != nil {
+			return err
+		}
+	}
+
+	return nil
+
+}
+
+// startLedger registra el saldo inicial de un producto recién creado. Un libro con movimientos
+// para el mismo ID sería de otro producto, por lo que nunca se adopta. Debe llamarse con
+// stockMu tomado.
+func (ps *productService) startLedger(product domain.Product) error {
+
+	if _, exists := ps.stockMovementRepository.Balances(product.ID); exists {
+		return domain.NewError(domain.ErrStorage, "El libro de stock ya tiene movimientos del producto %d", product.ID)
+	}
+
+	return ps.openLedger(product)
+
+}
+
+// applyMovement registra el movimiento y actualiza el producto con los saldos resultantes. Si
+// el movimiento se registró pero el producto no se pudo actualizar, devuelve el producto con
+// los saldos del libro sin almacenarlo, como RecordStockMovement. Debe llamarse con stockMu
+// tomado.
+func (ps *productService) applyMovement(ctx context.Context, product domain.Product, movement domain.StockMovement, operation domain.AuditOperation) (domain.Product, error) {
+
+	if err := ps.openLedger(product); err != nil {
+		return domain.Product{}, err
+	}
+
+	recorded, err := ps.appendMovement(ctx, product, movement)
+	if err != nil {
+		return domain.Product{}, err
+	}
+
+	productSynced, err := ps.syncStock(ctx, product.ID, operation)
+	if err != nil {
+		log.Printf("El movimiento %d se registró pero no se pudo actualizar el producto %d: %s", recorded.ID, product.ID, err)
+		return ps.withLedger(product), nil
+	}
+
+	return productSynced, nil
+
+}
+
+// appendMovement agrega el movimiento al libro de stock con el actor y la solicitud del
+// contexto. Los egresos que no indican un lote se reparten entre los lotes que vencen primero.
+// Las transferencias nunca dejan en negativo el depósito de origen; el resto de los egresos,
+// salvo que se permitan existencias negativas; en ese caso, un egreso sin depósito que solo
+// cubren las existencias de los depósitos requiere indicar el depósito. Debe llamarse con
+// stockMu tomado.
+func (ps *productService) appendMovement(ctx context.Context, product domain.Product, movement domain.StockMovement) (domain.StockMovement, error) {
+
+	lots := ps.stockMovementRepository.Lots(product.ID)
+
+	switch {
+	case movement.Type == domain.MovementTransfer:
+	case movement.Quantity > 0 && len(movement.Lots) > 0:
+		lotMovement, err := lotReceipt(lots, movement.Lots[0])
+		if err != nil {
+			return domain.StockMovement{}, err
+		}
+		movement.Lots = []domain.LotMovement{lotMovement}
+	case movement.Quantity < 0 && len(movement.Lots) == 0:
+		balances, _ := ps.stockMovementRepository.Balances(product.ID)
+		unlotted := totalQuantity(balances)
+		for _, lot := range lots {
+			unlotted -= lot.Quantity
+		}
+		movement.Lots = domain.AllocateFEFO(lots, unlotted, product.Expiration, -movement.Quantity)
+	}
+
+	movement.ProductID = product.ID
+	movement.Timestamp = time.Now().UTC()
+	movement.Actor = actorFromContext(ctx)
+	movement.RequestID = web.RequestIDFromContext(ctx)
+
+	guard := !ps.allowNegativeStock || movement.Type == domain.MovementTransfer
+	if guard && (movement.Type == domain.MovementTransfer || movement.Quantity < 0) {
+		if err := ps.checkWarehouse(movement); err != nil {
+			return domain.StockMovement{}, err
+		}
+		if err := ps.checkReserved(movement); err != nil {
+			return domain.StockMovement{}, err
+		}
+	}
+
+	return ps.stockMovementRepository.Append(movement, guard)
+
+}
+
+// checkWarehouse exige indicar el depósito en un egreso sin depósito que la existencia sin
+// depósito asignado no cubre pero la existencia total del producto sí, en lugar de rechazarlo
+// por falta de existencias
+func (ps *productService) checkWarehouse(movement domain.StockMovement) error {
+
+	if movement.Type == domain.MovementTransfer || movement.WarehouseID != 0 {
+		return nil
+	}
+
+	balances, _ := ps.stockMovementRepository.Balances(movement.ProductID)
+	if balances[0]+movement.Quantity >= 0 || totalQuantity(balances)+movement.Quantity < 0 {
+		return nil
+	}
+
+	var validation domain.ValidationError
+	validation.Add("warehouse_id", fmt.Sprintf("El producto tiene %d unidades sin depósito asignado; indique el depósito del que salen las %d unidades", max(balances[0], 0), -movement.Quantity))
+	return validation.Err()
+
+}
+
+// syncStock fija la cantidad, las existencias por depósito y los lotes del producto según el
+// libro de stock, y la existencia reservada según las reservas vigentes. Una modificación
+// concurrente del producto se reintenta con la versión nueva. Debe llamarse con stockMu tomado.
+func (ps *productService) syncStock(ctx context.Context, id int, operation domain.AuditOperation) (domain.Product, error) {
+
+	return withRetry(nil, func(_ []int) (domain.Product, error) {
+
+		oldProduct, err := ps.productRepository.Get(id)
+		if err != nil {
+			return domain.Product{}, err
+		}
+
+		productToUpdate := ps.withLedger(oldProduct)
+		if productToUpdate.Quantity == oldProduct.Quantity && productToUpdate.Reserved == oldProduct.Reserved && maps.Equal(productToUpdate.Stock, oldProduct.Stock) && domain.SameLots(productToUpdate.Lots, oldProduct.Lots) {
+			return oldProduct, nil
+		}
+
+		productUpdated, err := ps.productRepository.Update(productToUpdate, oldProduct.Version)
+		if err != nil {
+			return domain.Product{}, err
+		}
+
+		ps.record(ctx, operation, &oldProduct, &productUpdated)
+
+		return productUpdated, nil
+	})
+
+}
+
+// withLedger devuelve una copia del producto con la cantidad, las existencias por depósito y
+// los lotes del libro de stock, y la existencia reservada según las reservas vigentes. Debe
+// llamarse con stockMu tomado.
+func (ps *productService) withLedger(product domain.Product) domain.Product {
+
+	balances, _ := ps.stockMovementRepository.Balances(product.ID)
+
+	product = product.Clone()
+	product.SetBalances(balances)
+	product.SetLots(ps.stockMovementRepository.Lots(product.ID))
+	product.Reserved = totalQuantity(ps.reservationRepository.Reserved(product.ID, time.Now()))
+
+	return product
+
+}
+
+// lotReceipt verifica el ingreso de mercadería a un lote. Un lote nuevo requiere su
+// vencimiento y, si no se indica, se recibe en la fecha del día; uno existente conserva sus
+// fechas.
+func lotReceipt(lots map[string]domain.Lot, lotMovement domain.LotMovement) (domain.LotMovement, error) {
+
+	var validation domain.ValidationError
+
+	lot, exists := lots[lotMovement.Code]
+	switch {
+	case !exists && lotMovement.Expiration == nil:
+		validation.Add("expiration_date", fmt.Sprintf("El vencimiento es un campo requerido al recibir el lote nuevo %s", lotMovement.Code))
+	case !exists && lotMovement.ReceivedDate == nil:
+		today := time.Now().UTC().Truncate(24 * time.Hour)
+		lotMovement.ReceivedDate = &today
+	case exists:
+		if lotMovement.Expiration != nil && !lotMovement.Expiration.Equal(lot.Expiration) {
+			validation.Add("expiration_date", fmt.Sprintf("El lote %s vence el %s", lot.Code, lot.Expiration.Format("02/01/2006")))
+		}
+		if lotMovement.ReceivedDate != nil && !lotMovement.ReceivedDate.Equal(lot.ReceivedDate) {
+			validation.Add("received_date", fmt.Sprintf("El lote %s se recibió el %s", lot.Code, lot.ReceivedDate.Format("02/01/2006")))
+		}
+		lotMovement.Expiration = nil
+		lotMovement.ReceivedDate = nil
+	}
+
+	return lotMovement, validation.Err()
+
+}
+
+// totalQuantity es la existencia total de los saldos por depósito
+func totalQuantity(balances map[int]int) int {
+
+	total := 0
+	for _, quantity := range balances {
+		total += quantity
+	}
+
+	return total
+
+}
+
+// warehouseNames devuelve el nombre de cada depósito por ID
+func (ps *productService) warehouseNames() map[int]string {
+
+	warehouses, _ := ps.warehouseRepository.GetAll()
+
+	names := make(map[int]string, len(warehouses))
+	for _, warehouse := range warehouses {
+		names[warehouse.ID] = warehouse.Name
+	}
+
+	return names
+
+}
@@ -0,0 +1,91 @@
+package service
+
+import (
+	"PRACTICAS-GO-WEB/internal/domain"
+
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestRecordStockMovementSucceedsWhenProductSyncFails(t *testing.T) {
+
+	products := &memoryStorage{}
+	ps := newTestProductService(t, products)
+	ctx := context.Background()
+
+	created, err := ps.PostProduct(ctx, productRequest(t, `{"name":"Yerba","quantity":5,"code_value":"YER-1","is_published":true,"price":10}`))
+	if err != nil {
+		t.Fatalf("PostProduct: %v", err)
+	}
+
+	// El movimiento llega al libro, pero el producto no se puede almacenar
+	products.setFailWrites(true)
+	saleType, quantity := string(domain.MovementSale), 2
+	recorded, err := ps.RecordStockMovement(ctx, created.ID, domain.StockMovementRequest{Type: &saleType, Quantity: &quantity})
+	if err != nil {
+		t.Fatalf("RecordStockMovement informó un error para un movimiento registrado: %v", err)
+	}
+	if recorded.ID == 0 {
+		t.Fatal("RecordStockMovement no devolvió el movimiento registrado")
+	}
+	if balances, _ := ps.stockMovementRepository.Balances(created.ID); totalQuantity(balances) != 3 {
+		t.Fatalf("el libro de stock quedó en %v, se esperaban 3 unidades", balances)
+	}
+
+	// La conciliación alinea el producto con el libro
+	products.setFailWrites(false)
+	if _, err := ps.ReconcileStock(ctx); err != nil {
+		t.Fatalf("ReconcileStock: %v", err)
+	}
+	product, err := ps.productRepository.Get(created.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if product.Quantity != 3 {
+		t.Fatalf("la cantidad del producto es %d, se esperaban 3", product.Quantity)
+	}
+
+}
+
+func TestOutflowWithoutWarehouseRequiresWarehouseWhenStockIsAssigned(t *testing.T) {
+
+	products := &memoryStorage{}
+	ps := newTestProductService(t, products)
+	ctx := context.Background()
+
+	warehouse, err := ps.warehouseRepository.Create(domain.Warehouse{Name: "Central"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	created, err := ps.PostProduct(ctx, productRequest(t, `{"name":"Yerba","quantity":2,"code_value":"YER-1","is_published":true,"price":10}`))
+	if err != nil {
+		t.Fatalf("PostProduct: %v", err)
+	}
+
+	receiptType, received := string(domain.MovementPurchaseReceipt), 5
+	if _, err := ps.RecordStockMovement(ctx, created.ID, domain.StockMovementRequest{Type: &receiptType, Quantity: &received, WarehouseID: &warehouse.ID}); err != nil {
+		t.Fatalf("RecordStockMovement: %v", err)
+	}
+
+	// Las 2 unidades sin depósito no cubren la venta, pero las 7 del producto sí
+	saleType, sold := string(domain.MovementSale), 4
+	_, err = ps.RecordStockMovement(ctx, created.ID, domain.StockMovementRequest{Type: &saleType, Quantity: &sold})
+	var validation *domain.ValidationError
+	if !errors.As(err, &validation) || !validation.Has("warehouse_id") {
+		t.Fatalf("se esperaba un error de validación en warehouse_id, se obtuvo %v", err)
+	}
+
+	// Con el depósito indicado, la venta se registra
+	if _, err := ps.RecordStockMovement(ctx, created.ID, domain.StockMovementRequest{Type: &saleType, Quantity: &sold, WarehouseID: &warehouse.ID}); err != nil {
+		t.Fatalf("RecordStockMovement con depósito: %v", err)
+	}
+
+	// Lo que supera la existencia total sigue siendo un conflicto
+	sold = 10
+	if _, err := ps.RecordStockMovement(ctx, created.ID, domain.StockMovementRequest{Type: &saleType, Quantity: &sold}); !errors.Is(err, domain.ErrConflict) {
+		t.Fatalf("se esperaba un conflicto por falta de existencias, se obtuvo %v", err)
+	}
+
+}
@@ -0,0 +1,133 @@
+package service
+
+import (
+	"PRACTICAS-GO-WEB/internal/domain"
+	"PRACTICAS-GO-WEB/internal/query"
+	"PRACTICAS-GO-WEB/internal/repository"
+
+	"errors"
+)
+
+type WarehouseService interface {
+	GetWarehouses(q query.Query) (query.Page[any], error)
+	GetWarehouseByID(id int) (domain.WarehouseResponse, error)
+	GetWarehouseStock(id int, q query.Query) (query.Page[domain.WarehouseStockResponse], error)
+	PostWarehouse(warehouse domain.WarehouseRequest) (domain.WarehouseResponse, error)
+	PutWarehouse(id int, warehouse domain.WarehouseRequest) (domain.WarehouseResponse, error)
+	DeleteWarehouse(id int) error
+}
+
+// warehouseService administra los depósitos. Las existencias de cada depósito se consultan a
+// través del servicio de productos.
+type warehouseService struct {
+	warehouseRepository repository.WarehouseRepository
+	productService      ProductService
+}
+
+func NewWarehouseService(warehouseRepository repository.WarehouseRepository, productService ProductService) (*warehouseService, error) {
+
+	if warehouseRepository == nil {
+		return nil, errors.New("warehouseRepository is required")
+	}
+
+	if productService == nil {
+		return nil, errors.New("productService is required")
+	}
+
+	return &warehouseService{warehouseRepository: warehouseRepository, productService: productService}, nil
+
+}
+
+func (ws *warehouseService) GetWarehouses(q query.Query) (query.Page[any], error) {
+
+	page, err := ws.warehouseRepository.Find(q)
+	if err != nil {
+		return query.Page[any]{}, err
+	}
+
+	// Devolver solo los campos pedidos
+	items, err := query.Project(domain.WarehouseResponsesFromWarehouses(page.Items), q.Fields)
+	if err != nil {
+		return query.Page[any]{}, err
+	}
+
+	return query.WithItems(page, items), nil
+
+}
+
+func (ws *warehouseService) GetWarehouseByID(id int) (domain.WarehouseResponse, error) {
+
+	warehouse, err := ws.warehouseRepository.Get(id)
+	if err != nil {
+		return domain.WarehouseResponse{}, err
+	}
+
+	return domain.WarehouseResponseFromWarehouse(warehouse), nil
+
+}
+
+// GetWarehouseStock devuelve la página pedida de los productos con existencias en el depósito
+func (ws *warehouseService) GetWarehouseStock(id int, q query.Query) (query.Page[domain.WarehouseStockResponse], error) {
+
+	if _, err := ws.warehouseRepository.Get(id); err != nil {
+		return query.Page[domain.WarehouseStockResponse]{}, err
+	}
+
+	return ws.productService.GetWarehouseStock(id, q)
+
+}
+
+func (ws *warehouseService) PostWarehouse(warehouse domain.WarehouseRequest) (domain.WarehouseResponse, error) {
+
+	if err := warehouse.Validate(); err != nil {
+		return domain.WarehouseResponse{}, err
+	}
+
+	warehouseCreated, err := ws.warehouseRepository.Create(domain.WarehouseFromWarehouseRequest(warehouse))
+	if err != nil {
+		return domain.WarehouseResponse{}, err
+	}
+
+	return domain.WarehouseResponseFromWarehouse(warehouseCreated), nil
+
+}
+
+func (ws *warehouseService) PutWarehouse(id int, warehouse domain.WarehouseRequest) (domain.WarehouseResponse, error) {
+
+	if err := warehouse.Validate(); err != nil {
+		return domain.WarehouseResponse{}, err
+	}
+
+	warehouseToUpdate := domain.WarehouseFromWarehouseRequest(warehouse)
+	warehouseToUpdate.ID = id
+
+	warehouseUpdated, err := ws.warehouseRepository.Update(warehouseToUpdate)
+	if err != nil {
+		return domain.WarehouseResponse{}, err
+	}
+
+	return domain.WarehouseResponseFromWarehouse(warehouseUpdated), nil
+
+}
+
+// DeleteWarehouse elimina el depósito. Un depósito con existencias no se elimina: primero
+// deben transferirse a otro depósito.
+func (ws *warehouseService) DeleteWarehouse(id int) error {
+
+	if _, err := ws.warehouseRepository.Get(id); err != nil {
+		return err
+	}
+
+	page, err := ws.productService.GetWarehouseStock(id, query.Query{Limit: 1})
+	if err != nil {
+		return err
+	}
+	if page.Total > 0 {
+		return domain.NewError(domain.ErrConflict, "El depósito %d tiene existencias de %d productos; transfiéralas antes de eliminarlo", id, page.Total)
+	}
+
+	_, err = ws.warehouseRepository.Delete(id)
+
+	return err
+
+}
@@ -1,6 +1,65 @@
 package storage
 
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
 type Storage interface {
 	Read(emptyListEntity any) error
 	Write(emptyListEntity any) error
 }
+
+const (
+	// TypeJSON almacena los datos como un array JSON
+	TypeJSON = "json"
+	// TypeCSV almacena los datos como un CSV con fila de encabezado
+	TypeCSV = "csv"
+	// TypeJournal almacena un snapshot JSON y un log de mutaciones que se compacta periódicamente
+	TypeJournal = "journal"
+)
+
+type Config struct {
+	// Type es el formato del almacenamiento (json, csv o journal)
+	Type string
+	// FileName es la ruta del archivo de datos (el snapshot en el caso del journal)
+	FileName string
+	// Backups es la cantidad de generaciones .bak que se conservan del archivo de datos
+	Backups int
+	// JournalCompactEvery es la cantidad de registros del journal a partir de la cual se compacta
+	JournalCompactEvery int
+}
+
+// TypeFromFileName deduce el tipo de almacenamiento a partir de la extensión del archivo
+func TypeFromFileName(fileName string) string {
+
+	switch strings.ToLower(filepath.Ext(fileName)) {
+	case ".csv":
+		return TypeCSV
+	default:
+		return TypeJSON
+	}
+
+}
+
+// JournalFileName devuelve el nombre del log de mutaciones asociado al archivo de datos
+func JournalFileName(fileName string) string {
+	return fileName + ".journal"
+}
+
+// función para crear el almacenamiento según la configuración recibida
+func NewStorage(cfg Config) (Storage, error) {
+
+	switch strings.ToLower(cfg.Type) {
+	case TypeJSON:
+		return NewStorageJSON(cfg.FileName, cfg.Backups)
+	case TypeCSV:
+		return NewStorageCSV(cfg.FileName, cfg.Backups)
+	case TypeJournal:
+		return NewStorageJournal(cfg.FileName, JournalFileName(cfg.FileName), cfg.Backups, cfg.JournalCompactEvery)
+	default:
+		return nil, fmt.Errorf("El tipo de almacenamiento %q no es válido", cfg.Type)
+	}
+
+}
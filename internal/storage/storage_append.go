@@ -0,0 +1,127 @@
+package storage
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+)
+
+// AppendLog es un almacenamiento de solo agregado: los registros existentes nunca se
+// modifican ni se eliminan. Read deserializa todos los registros en un slice.
+type AppendLog interface {
+	Read(emptyListEntity any) error
+	Append(entity any) error
+}
+
+type storageAppendLog struct {
+	mu sync.Mutex
+	// fileName es el archivo donde se agregan los registros, un JSON por línea
+	fileName string
+}
+
+// función para crear un almacenamiento de solo agregado en fileName. El archivo se crea
+// si no existe y una última línea incompleta se descarta.
+func NewStorageAppendLog(fileName string) (AppendLog, error) {
+
+	if err := repairLog(fileName); err != nil {
+		return nil, err
+	}
+
+	return &storageAppendLog{fileName: fileName}, nil
+}
+
+// función para leer todos los registros y deserializarlos en un slice
+func (sa *storageAppendLog) Read(emptyListEntity any) error {
+
+	sa.mu.Lock()
+	defer sa.mu.Unlock()
+
+	file, err := os.Open(sa.fileName)
+	if err != nil {
+		return fmt.Errorf("Error al abrir el registro %s: %v", sa.fileName, err)
+	}
+	defer file.Close()
+
+	// Los registros se leen como los elementos de un array JSON
+	var content bytes.Buffer
+	content.WriteByte('[')
+
+	reader := bufio.NewReader(file)
+	records := 0
+	for line := 1; ; line++ {
+		record, err := reader.ReadBytes('\n')
+		if err != nil && !errors.Is(err, io.EOF) {
+			return fmt.Errorf("Error al leer el registro %s: %v", sa.fileName, err)
+		}
+		complete := err == nil
+
+		record = bytes.TrimSpace(record)
+		if len(record) > 0 {
+			if !json.Valid(record) {
+				// Una última línea incompleta es una escritura interrumpida y se ignora
+				if !complete {
+					break
+				}
+				return fmt.Errorf("Error en la línea %d del registro %s: no es un JSON válido", line, sa.fileName)
+			}
+			if records > 0 {
+				content.WriteByte(',')
+			}
+			content.Write(record)
+			records++
+		}
+
+		if !complete {
+			break
+		}
+	}
+
+	content.WriteByte(']')
+
+	if err := json.Unmarshal(content.Bytes(), emptyListEntity); err != nil {
+		return fmt.Errorf("Error al deserializar el registro %s: %v", sa.fileName, err)
+	}
+
+	return nil
+
+}
+
+// función para agregar un registro al final del archivo y sincronizarlo a disco
+func (sa *storageAppendLog) Append(entity any) error {
+
+	line, err := json.Marshal(entity)
+	if err != nil {
+		return fmt.Errorf("Error al serializar el registro: %s", err)
+	}
+	line = append(line, '\n')
+
+	sa.mu.Lock()
+	defer sa.mu.Unlock()
+
+	file, err := os.OpenFile(sa.fileName, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("Error al abrir el registro %s: %s", sa.fileName, err)
+	}
+
+	if _, err := file.Write(line); err != nil {
+		file.Close()
+		return fmt.Errorf("Error al escribir en el registro %s: %s", sa.fileName, err)
+	}
+
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return fmt.Errorf("Error al sincronizar el registro %s: %s", sa.fileName, err)
+	}
+
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("Error al cerrar el registro %s: %s", sa.fileName, err)
+	}
+
+	return nil
+
+}
@@ -0,0 +1,336 @@
+package storage
+
+import (
+	"encoding"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+type storageCSV struct {
+	fileName string
+	// backups es la cantidad de generaciones .bak que se conservan junto al archivo
+	backups int
+}
+
+// csvColumn relaciona una columna del archivo con el campo del struct que la contiene
+type csvColumn struct {
+	name  string
+	index int
+}
+
+var textUnmarshalerType = reflect.TypeFor[encoding.TextUnmarshaler]()
+var textMarshalerType = reflect.TypeFor[encoding.TextMarshaler]()
+
+func NewStorageCSV(fileName string, backups int) (Storage, error) {
+
+	file, err := os.Open(fileName)
+	if err != nil {
+		return nil, fmt.Errorf("Error al abrir el archivo CSV: %v\n", err)
+	}
+	file.Close()
+
+	if backups < 0 {
+		return nil, fmt.Errorf("La cantidad de backups no puede ser negativa: %d", backups)
+	}
+
+	storageCSV := &storageCSV{fileName: fileName, backups: backups}
+
+	return storageCSV, nil
+}
+
+// función para leer un archivo CSV y deserializarlo en un slice de structs.
+// La primera fila es el encabezado y cada columna se asocia al campo cuyo tag json
+// tiene el mismo nombre.
+func (sc *storageCSV) Read(emptyListEntity any) error {
+
+	slice, elemType, err := csvSliceTarget(emptyListEntity)
+	if err != nil {
+		return err
+	}
+
+	// Abrir el archivo
+	file, err := os.Open(sc.fileName)
+	if err != nil {
+		return fmt.Errorf("Error al abrir el archivo CSV: %v\n", err)
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = 0
+
+	header, err := reader.Read()
+	if errors.Is(err, io.EOF) {
+		// Un archivo vacío equivale a una lista vacía
+		slice.Set(reflect.MakeSlice(slice.Type(), 0, 0))
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("Error al leer el encabezado del CSV: %v", err)
+	}
+
+	columns, err := csvColumnsFromHeader(elemType, header)
+	if err != nil {
+		return err
+	}
+
+	result := reflect.MakeSlice(slice.Type(), 0, 0)
+
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("Error al leer el CSV: %v", err)
+		}
+
+		line, _ := reader.FieldPos(0)
+
+		elem := reflect.New(elemType).Elem()
+		for i, column := range columns {
+			if err := setCSVField(elem.Field(column.index), record[i]); err != nil {
+				return fmt.Errorf("Error en la línea %d, columna %q: %s", line, column.name, err.Error())
+			}
+		}
+
+		result = reflect.Append(result, elem)
+	}
+
+	slice.Set(result)
+
+	return nil
+
+}
+
+// función para escribir un slice de structs en un archivo CSV con su fila de encabezado
+func (sc *storageCSV) Write(emptyListEntity any) error {
+
+	value := reflect.ValueOf(emptyListEntity)
+	for value.Kind() == reflect.Pointer {
+		value = value.Elem()
+	}
+	if value.Kind() != reflect.Slice || value.Type().Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("El CSV solo admite slices de structs, se recibió %T", emptyListEntity)
+	}
+
+	columns := csvColumnsFromType(value.Type().Elem())
+
+	return writeFileAtomic(sc.fileName, sc.backups, func(w io.Writer) error {
+
+		writer := csv.NewWriter(w)
+
+		header := make([]string, len(columns))
+		for i, column := range columns {
+			header[i] = column.name
+		}
+		if err := writer.Write(header); err != nil {
+			return fmt.Errorf("Error al escribir el encabezado del CSV: %s", err)
+		}
+
+		for row := 0; row < value.Len(); row++ {
+			record := make([]string, len(columns))
+			for i, column := range columns {
+				cell, err := formatCSVField(value.Index(row).Field(column.index))
+				if err != nil {
+					return fmt.Errorf("Error en la fila %d, columna %q: %s", row+1, column.name, err.Error())
+				}
+				record[i] = cell
+			}
+			if err := writer.Write(record); err != nil {
+				return fmt.Errorf("Error al escribir el CSV: %s", err)
+			}
+		}
+
+		writer.Flush()
+		if err := writer.Error(); err != nil {
+			return fmt.Errorf("Error al escribir el CSV: %s", err)
+		}
+
+		return nil
+	})
+
+}
+
+// csvSliceTarget valida que el destino sea un puntero a un slice de structs
+func csvSliceTarget(emptyListEntity any) (reflect.Value, reflect.Type, error) {
+
+	value := reflect.ValueOf(emptyListEntity)
+	if value.Kind() != reflect.Pointer || value.IsNil() || value.Elem().Kind() != reflect.Slice {
+		return reflect.Value{}, nil, fmt.Errorf("El CSV solo puede leerse en un puntero a slice, se recibió %T", emptyListEntity)
+	}
+
+	elemType := value.Elem().Type().Elem()
+	if elemType.Kind() != reflect.Struct {
+		return reflect.Value{}, nil, fmt.Errorf("El CSV solo puede leerse en un slice de structs, se recibió %T", emptyListEntity)
+	}
+
+	return value.Elem(), elemType, nil
+}
+
+// csvColumnsFromType devuelve las columnas de un struct según sus tags json
+func csvColumnsFromType(structType reflect.Type) []csvColumn {
+
+	var columns []csvColumn
+
+	for i := 0; i < structType.NumField(); i++ {
+		field := structType.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		name := field.Name
+		if tag, ok := field.Tag.Lookup("json"); ok {
+			tagName, _, _ := strings.Cut(tag, ",")
+			if tagName == "-" {
+				continue
+			}
+			if tagName != "" {
+				name = tagName
+			}
+		}
+
+		columns = append(columns, csvColumn{name: name, index: i})
+	}
+
+	return columns
+}
+
+// csvColumnsFromHeader asocia cada columna del encabezado a un campo del struct
+func csvColumnsFromHeader(structType reflect.Type, header []string) ([]csvColumn, error) {
+
+	byName := make(map[string]csvColumn)
+	for _, column := range csvColumnsFromType(structType) {
+		byName[column.name] = column
+	}
+
+	columns := make([]csvColumn, len(header))
+	seen := make(map[string]bool)
+
+	for i, name := range header {
+		name = strings.TrimSpace(name)
+		column, ok := byName[name]
+		if !ok {
+			return nil, fmt.Errorf("Error en la línea 1: la columna %q no es válida", name)
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("Error en la línea 1: la columna %q está repetida", name)
+		}
+		seen[name] = true
+		columns[i] = column
+	}
+
+	return columns, nil
+}
+
+// setCSVField convierte el texto de una celda al tipo del campo
+func setCSVField(field reflect.Value, cell string) error {
+
+	if field.Kind() == reflect.Pointer {
+		if cell == "" {
+			field.SetZero()
+			return nil
+		}
+		ptr := reflect.New(field.Type().Elem())
+		if err := setCSVField(ptr.Elem(), cell); err != nil {
+			return err
+		}
+		field.Set(ptr)
+		return nil
+	}
+
+	if reflect.PointerTo(field.Type()).Implements(textUnmarshalerType) {
+		if cell == "" {
+			field.SetZero()
+			return nil
+		}
+		return field.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(cell))
+	}
+
+	if cell == "" {
+		field.SetZero()
+		return nil
+	}
+
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(cell)
+	case reflect.Bool:
+		value, err := strconv.ParseBool(cell)
+		if err != nil {
+			return fmt.Errorf("%q no es un valor booleano válido", cell)
+		}
+		field.SetBool(value)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		value, err := strconv.ParseInt(cell, 10, field.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("%q no es un número entero válido", cell)
+		}
+		field.SetInt(value)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		value, err := strconv.ParseUint(cell, 10, field.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("%q no es un número entero positivo válido", cell)
+		}
+		field.SetUint(value)
+	case reflect.Float32, reflect.Float64:
+		value, err := strconv.ParseFloat(cell, field.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("%q no es un número decimal válido", cell)
+		}
+		field.SetFloat(value)
+	default:
+		// Los tipos compuestos (slices, maps, structs) se guardan como JSON dentro de la celda
+		if err := json.Unmarshal([]byte(cell), field.Addr().Interface()); err != nil {
+			return fmt.Errorf("%q no es un JSON válido: %s", cell, err)
+		}
+	}
+
+	return nil
+}
+
+// formatCSVField convierte el valor de un campo al texto de la celda
+func formatCSVField(field reflect.Value) (string, error) {
+
+	if field.Kind() == reflect.Pointer {
+		if field.IsNil() {
+			return "", nil
+		}
+		return formatCSVField(field.Elem())
+	}
+
+	if field.Type().Implements(textMarshalerType) {
+		text, err := field.Interface().(encoding.TextMarshaler).MarshalText()
+		return string(text), err
+	}
+
+	switch field.Kind() {
+	case reflect.String:
+		return field.String(), nil
+	case reflect.Bool:
+		return strconv.FormatBool(field.Bool()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(field.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(field.Uint(), 10), nil
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(field.Float(), 'f', -1, field.Type().Bits()), nil
+	}
+
+	if (field.Kind() == reflect.Slice || field.Kind() == reflect.Map) && field.IsNil() {
+		return "", nil
+	}
+
+	content, err := json.Marshal(field.Interface())
+	if err != nil {
+		return "", err
+	}
+
+	return string(content), nil
+}
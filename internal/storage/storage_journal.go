@@ -0,0 +1,365 @@
+package storage
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+type Operation string
+
+const (
+	OperationCreate Operation = "create"
+	OperationUpdate Operation = "update"
+	OperationDelete Operation = "delete"
+)
+
+// JournalRecord es una mutación individual registrada en el log
+type JournalRecord struct {
+	Op        Operation       `json:"op"`
+	ID        int             `json:"id"`
+	Payload   json.RawMessage `json:"payload,omitempty"`
+	Timestamp time.Time       `json:"timestamp"`
+}
+
+// Journal es un almacenamiento que, además de escribir el estado completo, permite
+// registrar mutaciones individuales que se aplican sobre el último snapshot.
+type Journal interface {
+	Storage
+	Append(op Operation, id int, payload any) error
+	Compact() error
+}
+
+type storageJournal struct {
+	mu sync.Mutex
+	// snapshot guarda el estado completo al momento de la última compactación
+	snapshot Storage
+	// logName es el archivo donde se agregan las mutaciones, un registro JSON por línea
+	logName string
+	// compactEvery es la cantidad de registros a partir de la cual se compacta automáticamente
+	compactEvery int
+	// records es la cantidad de registros agregados desde la última compactación
+	records int
+}
+
+// función para crear un almacenamiento por journal: el snapshot se guarda en fileName y
+// las mutaciones en logName. Las entidades almacenadas deben tener un campo "id".
+func NewStorageJournal(fileName string, logName string, backups int, compactEvery int) (Journal, error) {
+
+	snapshot, err := NewStorageJSON(fileName, backups)
+	if err != nil {
+		return nil, err
+	}
+
+	if compactEvery < 0 {
+		return nil, fmt.Errorf("El umbral de compactación no puede ser negativo: %d", compactEvery)
+	}
+
+	if err := repairLog(logName); err != nil {
+		return nil, err
+	}
+
+	storageJournal := &storageJournal{snapshot: snapshot, logName: logName, compactEvery: compactEvery}
+
+	records, err := storageJournal.readLog()
+	if err != nil {
+		return nil, err
+	}
+	storageJournal.records = len(records)
+
+	return storageJournal, nil
+}
+
+// función para reconstruir el estado reproduciendo el journal sobre el último snapshot
+func (sj *storageJournal) Read(emptyListEntity any) error {
+
+	sj.mu.Lock()
+	defer sj.mu.Unlock()
+
+	state, err := sj.replay()
+	if err != nil {
+		return err
+	}
+
+	if err := json.Unmarshal(state, emptyListEntity); err != nil {
+		return fmt.Errorf("Error al deserializar el estado del journal: %v", err)
+	}
+
+	return nil
+
+}
+
+// función para escribir el estado completo; equivale a una compactación con el estado recibido
+func (sj *storageJournal) Write(emptyListEntity any) error {
+
+	sj.mu.Lock()
+	defer sj.mu.Unlock()
+
+	if err := sj.snapshot.Write(emptyListEntity); err != nil {
+		return err
+	}
+
+	return sj.truncateLog()
+
+}
+
+// función para agregar una mutación al journal sin reescribir el estado completo
+func (sj *storageJournal) Append(op Operation, id int, payload any) error {
+
+	record := JournalRecord{Op: op, ID: id, Timestamp: time.Now().UTC()}
+
+	if op != OperationDelete {
+		content, err := json.Marshal(payload)
+		if err != nil {
+			return fmt.Errorf("Error al serializar el registro del journal: %s", err)
+		}
+		record.Payload = content
+	}
+
+	line, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("Error al serializar el registro del journal: %s", err)
+	}
+	line = append(line, '\n')
+
+	sj.mu.Lock()
+	defer sj.mu.Unlock()
+
+	file, err := os.OpenFile(sj.logName, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("Error al abrir el journal: %s", err)
+	}
+
+	if _, err := file.Write(line); err != nil {
+		file.Close()
+		return fmt.Errorf("Error al escribir en el journal: %s", err)
+	}
+
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return fmt.Errorf("Error al sincronizar el journal: %s", err)
+	}
+
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("Error al cerrar el journal: %s", err)
+	}
+
+	sj.records++
+
+	if sj.compactEvery > 0 && sj.records >= sj.compactEvery {
+		// El registro ya quedó persistido, por lo que un fallo al compactar no invalida la mutación
+		if err := sj.compact(); err != nil {
+			log.Printf("Error al compactar el journal: %s", err)
+		}
+	}
+
+	return nil
+
+}
+
+// función para volcar el journal en un nuevo snapshot y vaciar el log
+func (sj *storageJournal) Compact() error {
+
+	sj.mu.Lock()
+	defer sj.mu.Unlock()
+
+	return sj.compact()
+
+}
+
+func (sj *storageJournal) compact() error {
+
+	if sj.records == 0 {
+		return nil
+	}
+
+	state, err := sj.replay()
+	if err != nil {
+		return err
+	}
+
+	// El snapshot se escribe antes de vaciar el log: si el proceso se interrumpe entre
+	// ambos pasos, reproducir el log sobre el nuevo snapshot da el mismo estado.
+	if err := sj.snapshot.Write(json.RawMessage(state)); err != nil {
+		return err
+	}
+
+	return sj.truncateLog()
+
+}
+
+func (sj *storageJournal) truncateLog() error {
+
+	if err := os.Truncate(sj.logName, 0); err != nil {
+		return fmt.Errorf("Error al vaciar el journal: %s", err)
+	}
+	sj.records = 0
+
+	return nil
+
+}
+
+// replay devuelve el estado resultante como un array JSON. La reproducción es idempotente:
+// un create sobre un ID existente lo reemplaza y un delete sobre un ID inexistente se ignora.
+func (sj *storageJournal) replay() ([]byte, error) {
+
+	var entities []json.RawMessage
+	if err := sj.snapshot.Read(&entities); err != nil {
+		return nil, err
+	}
+
+	ids := make([]int, len(entities))
+	positions := make(map[int]int, len(entities))
+	for i, entity := range entities {
+		var key struct {
+			ID int `json:"id"`
+		}
+		if err := json.Unmarshal(entity, &key); err != nil {
+			return nil, fmt.Errorf("Error al leer el ID del elemento %d del snapshot: %v", i, err)
+		}
+		ids[i] = key.ID
+		positions[key.ID] = i
+	}
+
+	records, err := sj.readLog()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, record := range records {
+		position, exists := positions[record.ID]
+
+		switch record.Op {
+		case OperationCreate, OperationUpdate:
+			if exists {
+				entities[position] = record.Payload
+				continue
+			}
+			positions[record.ID] = len(entities)
+			entities = append(entities, record.Payload)
+			ids = append(ids, record.ID)
+		case OperationDelete:
+			if !exists {
+				continue
+			}
+			entities[position] = nil
+			delete(positions, record.ID)
+		}
+	}
+
+	// Descartar los elementos eliminados conservando el orden original
+	state := make([]json.RawMessage, 0, len(positions))
+	for i, entity := range entities {
+		if entity == nil {
+			continue
+		}
+		if position, ok := positions[ids[i]]; !ok || position != i {
+			continue
+		}
+		state = append(state, entity)
+	}
+
+	content, err := json.Marshal(state)
+	if err != nil {
+		return nil, fmt.Errorf("Error al serializar el estado del journal: %v", err)
+	}
+
+	return content, nil
+
+}
+
+// readLog lee todos los registros del journal. Una última línea incompleta (por ejemplo por
+// una caída a mitad de escritura) se descarta; cualquier otra línea inválida es un error.
+func (sj *storageJournal) readLog() ([]JournalRecord, error) {
+
+	file, err := os.Open(sj.logName)
+	if err != nil {
+		return nil, fmt.Errorf("Error al abrir el journal: %v", err)
+	}
+	defer file.Close()
+
+	var records []JournalRecord
+
+	reader := bufio.NewReader(file)
+	for line := 1; ; line++ {
+		content, err := reader.ReadBytes('\n')
+		if err != nil && !errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("Error al leer el journal: %v", err)
+		}
+		complete := err == nil
+
+		content = bytes.TrimSpace(content)
+		if len(content) > 0 {
+			var record JournalRecord
+			if decodeErr := json.Unmarshal(content, &record); decodeErr != nil {
+				if !complete {
+					log.Printf("Se descarta la última línea incompleta del journal %s", sj.logName)
+					break
+				}
+				return nil, fmt.Errorf("Error en la línea %d del journal: %v", line, decodeErr)
+			}
+			if err := record.validate(); err != nil {
+				return nil, fmt.Errorf("Error en la línea %d del journal: %s", line, err.Error())
+			}
+			records = append(records, record)
+		}
+
+		if !complete {
+			break
+		}
+	}
+
+	return records, nil
+
+}
+
+// repairLog crea el journal si no existe y descarta una última línea incompleta, para que
+// los registros que se agreguen luego no queden pegados a ella.
+func repairLog(logName string) error {
+
+	content, err := os.ReadFile(logName)
+	if errors.Is(err, os.ErrNotExist) {
+		if err := os.WriteFile(logName, nil, 0644); err != nil {
+			return fmt.Errorf("Error al crear el journal: %v", err)
+		}
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("Error al abrir el journal: %v", err)
+	}
+
+	if len(content) == 0 || content[len(content)-1] == '\n' {
+		return nil
+	}
+
+	log.Printf("Se descarta la última línea incompleta del journal %s", logName)
+	if err := os.Truncate(logName, int64(bytes.LastIndexByte(content, '\n')+1)); err != nil {
+		return fmt.Errorf("Error al reparar el journal: %v", err)
+	}
+
+	return nil
+
+}
+
+func (record JournalRecord) validate() error {
+
+	switch record.Op {
+	case OperationCreate, OperationUpdate:
+		if len(record.Payload) == 0 {
+			return fmt.Errorf("la operación %s no tiene contenido", record.Op)
+		}
+	case OperationDelete:
+	default:
+		return fmt.Errorf("la operación %q no es válida", record.Op)
+	}
+
+	return nil
+
+}
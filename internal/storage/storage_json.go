@@ -1,17 +1,22 @@
 package storage
 
 import (
+	"bufio"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"os"
+	"path/filepath"
 )
 
 type storageJSON struct {
 	fileName string
+	// backups es la cantidad de generaciones .bak que se conservan junto al archivo
+	backups int
 }
 
-func NewStorageJSON(fileName string) (Storage, error) {
+func NewStorageJSON(fileName string, backups int) (Storage, error) {
 
 	file, err := os.Open(fileName)
 	if err != nil {
@@ -19,7 +24,11 @@ func NewStorageJSON(fileName string) (Storage, error) {
 	}
 	file.Close()
 
-	storageJSON := &storageJSON{fileName: fileName}
+	if backups < 0 {
+		return nil, fmt.Errorf("La cantidad de backups no puede ser negativa: %d", backups)
+	}
+
+	storageJSON := &storageJSON{fileName: fileName, backups: backups}
 
 	return storageJSON, nil
 }
@@ -49,19 +58,161 @@ func (sj *storageJSON) Read(emptyListEntity any) error {
 
 }
 
-// función para escribir un slice de Product en un archivo JSON
+// función para escribir un slice de Product en un archivo JSON.
+// La escritura se realiza sobre un archivo temporal en el mismo directorio que luego
+// reemplaza al original de forma atómica, de modo que un fallo a mitad de la escritura
+// nunca deja el archivo original truncado.
 func (sj *storageJSON) Write(emptyListEntity any) error {
 
-	// Crear el archivo
-	file, err := os.Create(sj.fileName)
+	return writeFileAtomic(sj.fileName, sj.backups, func(w io.Writer) error {
+		// Serializar el slice de Product a JSON y escribirlo en el archivo
+		if err := json.NewEncoder(w).Encode(emptyListEntity); err != nil {
+			return fmt.Errorf("Error al serializar el JSON: %s", err)
+		}
+		return nil
+	})
+
+}
+
+// writeFileAtomic escribe el contenido generado por encode en un archivo temporal,
+// lo sincroniza a disco, rota los backups y lo renombra sobre fileName.
+func writeFileAtomic(fileName string, backups int, encode func(w io.Writer) error) (err error) {
+
+	dir, base := filepath.Split(fileName)
+	if dir == "" {
+		dir = "."
+	}
+
+	// Crear el archivo temporal en el mismo directorio para que el rename sea atómico
+	tmp, err := os.CreateTemp(dir, "."+base+".tmp-*")
 	if err != nil {
-		return fmt.Errorf("Error al crear el archivo Json: %s\n", err)
+		return fmt.Errorf("Error al crear el archivo temporal: %s", err)
+	}
+	tmpName := tmp.Name()
+
+	// Ante cualquier error se descarta el archivo temporal
+	defer func() {
+		if err != nil {
+			tmp.Close()
+			os.Remove(tmpName)
+		}
+	}()
+
+	writer := bufio.NewWriter(tmp)
+	if err = encode(writer); err != nil {
+		return err
+	}
+
+	if err = writer.Flush(); err != nil {
+		return fmt.Errorf("Error al escribir el archivo temporal: %s", err)
 	}
-	defer file.Close()
 
-	// Serializar el slice de Product a JSON y escribirlo en el archivo
-	json.NewEncoder(file).Encode(emptyListEntity)
+	if err = tmp.Sync(); err != nil {
+		return fmt.Errorf("Error al sincronizar el archivo temporal: %s", err)
+	}
+
+	if err = tmp.Close(); err != nil {
+		return fmt.Errorf("Error al cerrar el archivo temporal: %s", err)
+	}
+
+	// Conservar los permisos del archivo original
+	if info, statErr := os.Stat(fileName); statErr == nil {
+		if err = os.Chmod(tmpName, info.Mode().Perm()); err != nil {
+			return fmt.Errorf("Error al copiar los permisos del archivo: %s", err)
+		}
+	}
+
+	if err = rotateBackups(fileName, backups); err != nil {
+		return err
+	}
+
+	if err = os.Rename(tmpName, fileName); err != nil {
+		return fmt.Errorf("Error al reemplazar el archivo %s: %s", fileName, err)
+	}
+
+	syncDir(dir)
 
 	return nil
 
 }
+
+// backupName devuelve el nombre del backup de la generación indicada (1 es la más reciente)
+func backupName(fileName string, generation int) string {
+	if generation == 1 {
+		return fileName + ".bak"
+	}
+	return fmt.Sprintf("%s.bak.%d", fileName, generation)
+}
+
+// rotateBackups desplaza las generaciones existentes (.bak -> .bak.2 -> ...) y guarda
+// el contenido actual del archivo como la generación más reciente.
+func rotateBackups(fileName string, backups int) error {
+
+	if backups <= 0 {
+		return nil
+	}
+
+	if _, err := os.Stat(fileName); errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+
+	// Descartar la generación más antigua y desplazar el resto
+	if err := os.Remove(backupName(fileName, backups)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("Error al eliminar el backup más antiguo: %s", err)
+	}
+	for generation := backups - 1; generation >= 1; generation-- {
+		err := os.Rename(backupName(fileName, generation), backupName(fileName, generation+1))
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("Error al rotar los backups: %s", err)
+		}
+	}
+
+	// El hard link conserva el contenido anterior luego del rename sin copiar el archivo;
+	// si el sistema de archivos no lo soporta se realiza una copia.
+	if err := os.Link(fileName, backupName(fileName, 1)); err != nil {
+		if err := copyFile(fileName, backupName(fileName, 1)); err != nil {
+			return fmt.Errorf("Error al crear el backup: %s", err)
+		}
+	}
+
+	return nil
+
+}
+
+func copyFile(src, dst string) error {
+
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+
+	if err := out.Sync(); err != nil {
+		out.Close()
+		return err
+	}
+
+	return out.Close()
+
+}
+
+// syncDir sincroniza el directorio para que el rename sobreviva a una caída del sistema.
+// Algunos sistemas no permiten sincronizar directorios, por lo que el error se ignora.
+func syncDir(dir string) {
+	d, err := os.Open(dir)
+	if err != nil {
+		return
+	}
+	d.Sync()
+	d.Close()
+}
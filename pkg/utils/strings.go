@@ -0,0 +1,36 @@
+package utils
+
+import (
+	"strings"
+	"unicode"
+)
+
+// accentFolds reemplaza las letras acentuadas más comunes del español y otros idiomas
+// latinos por su letra base
+var accentFolds = map[rune]string{
+	'á': "a", 'à': "a", 'ä': "a", 'â': "a", 'ã': "a", 'å': "a",
+	'é': "e", 'è': "e", 'ë': "e", 'ê': "e",
+	'í': "i", 'ì': "i", 'ï': "i", 'î': "i",
+	'ó': "o", 'ò': "o", 'ö': "o", 'ô': "o", 'õ': "o", 'ø': "o",
+	'ú': "u", 'ù': "u", 'ü': "u", 'û': "u",
+	'ñ': "n", 'ç': "c", 'ý': "y", 'ÿ': "y",
+	'æ': "ae", 'œ': "oe", 'ß': "ss",
+}
+
+// FoldString normaliza un texto para compararlo sin distinguir mayúsculas ni acentos
+func FoldString(s string) string {
+
+	var builder strings.Builder
+	builder.Grow(len(s))
+
+	for _, r := range s {
+		r = unicode.ToLower(r)
+		if fold, ok := accentFolds[r]; ok {
+			builder.WriteString(fold)
+			continue
+		}
+		builder.WriteRune(r)
+	}
+
+	return builder.String()
+}
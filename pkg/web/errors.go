@@ -0,0 +1,49 @@
+package web
+
+import (
+	"errors"
+	"net/http"
+	"sync"
+)
+
+type errorStatus struct {
+	target     error
+	statusCode int
+}
+
+var (
+	errorStatusesMu sync.RWMutex
+	errorStatuses   []errorStatus
+)
+
+// RegisterErrorStatus asocia un error (comparado con errors.Is) a un código de estado HTTP
+func RegisterErrorStatus(target error, statusCode int) {
+
+	errorStatusesMu.Lock()
+	defer errorStatusesMu.Unlock()
+
+	errorStatuses = append(errorStatuses, errorStatus{target: target, statusCode: statusCode})
+
+}
+
+// StatusFromError devuelve el código de estado registrado para el error, o 500 si no hay ninguno
+func StatusFromError(err error) int {
+
+	errorStatusesMu.RLock()
+	defer errorStatusesMu.RUnlock()
+
+	for _, status := range errorStatuses {
+		if errors.Is(err, status.target) {
+			return status.statusCode
+		}
+	}
+
+	return http.StatusInternalServerError
+
+}
+
+// ErrorFromError responde con el error como application/problem+json, con el código de estado
+// que le corresponde y los errores por campo que contenga
+func ErrorFromError(w http.ResponseWriter, r *http.Request, err error) {
+	WriteProblem(w, ProblemFromError(r, err))
+}
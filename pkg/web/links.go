@@ -0,0 +1,47 @@
+package web
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Link es un enlace de la respuesta con su relación (RFC 8288)
+type Link struct {
+	URL string
+	Rel string
+}
+
+// SetLinks agrega el encabezado Link con los enlaces indicados
+func SetLinks(w http.ResponseWriter, links ...Link) {
+
+	if len(links) == 0 {
+		return
+	}
+
+	values := make([]string, len(links))
+	for i, link := range links {
+		values[i] = fmt.Sprintf(`<%s>; rel="%s"`, link.URL, link.Rel)
+	}
+
+	w.Header().Set("Link", strings.Join(values, ", "))
+}
+
+// URLWithQuery devuelve la URL de la solicitud con los parámetros de consulta reemplazados;
+// los parámetros con valor vacío se eliminan
+func URLWithQuery(r *http.Request, params map[string]string) string {
+
+	values := r.URL.Query()
+	for name, value := range params {
+		if value == "" {
+			values.Del(name)
+			continue
+		}
+		values.Set(name, value)
+	}
+
+	target := url.URL{Path: r.URL.Path, RawQuery: values.Encode()}
+
+	return target.String()
+}
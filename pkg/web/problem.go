@@ -0,0 +1,120 @@
+package web
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"sync"
+)
+
+// ContentTypeProblem es el tipo de contenido de las respuestas de error (RFC 7807)
+const ContentTypeProblem = "application/problem+json"
+
+// FieldError describe un problema puntual en uno de los campos de la solicitud
+type FieldError struct {
+	Field  string `json:"field"`
+	Reason string `json:"reason"`
+}
+
+// ProblemDetails es el cuerpo de una respuesta de error según la RFC 7807
+type ProblemDetails struct {
+	Type     string       `json:"type"`
+	Title    string       `json:"title"`
+	Status   int          `json:"status"`
+	Detail   string       `json:"detail,omitempty"`
+	Instance string       `json:"instance,omitempty"`
+	Errors   []FieldError `json:"errors,omitempty"`
+}
+
+// serverErrorDetails es el detalle genérico que reciben los clientes ante un error del
+// servidor; los códigos que no figuran usan el de StatusInternalServerError
+var serverErrorDetails = map[int]string{
+	http.StatusInternalServerError: "Error interno del servidor",
+	http.StatusNotImplemented:      "Operación no implementada",
+	http.StatusServiceUnavailable:  "Servicio no disponible",
+}
+
+var (
+	fieldErrorsExtractorsMu sync.RWMutex
+	fieldErrorsExtractors   []func(err error) []FieldError
+)
+
+// RegisterFieldErrors registra una función que obtiene los errores por campo contenidos en un error
+func RegisterFieldErrors(extractor func(err error) []FieldError) {
+
+	fieldErrorsExtractorsMu.Lock()
+	defer fieldErrorsExtractorsMu.Unlock()
+
+	fieldErrorsExtractors = append(fieldErrorsExtractors, extractor)
+
+}
+
+// NewProblem crea el detalle de un problema para la solicitud r
+func NewProblem(r *http.Request, statusCode int, detail string) ProblemDetails {
+
+	problem := ProblemDetails{
+		Type:   "about:blank",
+		Title:  http.StatusText(statusCode),
+		Status: statusCode,
+		Detail: detail,
+	}
+
+	if r != nil && r.URL != nil {
+		problem.Instance = r.URL.RequestURI()
+	}
+
+	return problem
+
+}
+
+// ProblemFromError crea el detalle de un problema a partir de un error, con el código de
+// estado registrado para él y los errores por campo que contenga. Los errores del servidor
+// pueden exponer rutas o mensajes del almacenamiento: se registran en el log y el detalle
+// informado es genérico.
+func ProblemFromError(r *http.Request, err error) ProblemDetails {
+
+	statusCode := StatusFromError(err)
+	detail := err.Error()
+	if statusCode >= http.StatusInternalServerError {
+		detail = serverErrorDetails[statusCode]
+		if detail == "" {
+			detail = serverErrorDetails[http.StatusInternalServerError]
+		}
+		if r != nil && r.URL != nil {
+			log.Printf("Error interno en %s %s: %s", r.Method, r.URL.RequestURI(), err)
+		} else {
+			log.Printf("Error interno: %s", err)
+		}
+	}
+
+	problem := NewProblem(r, statusCode, detail)
+
+	fieldErrorsExtractorsMu.RLock()
+	defer fieldErrorsExtractorsMu.RUnlock()
+
+	for _, extractor := range fieldErrorsExtractors {
+		problem.Errors = append(problem.Errors, extractor(err)...)
+	}
+
+	return problem
+
+}
+
+// WriteProblem responde con el detalle del problema como application/problem+json
+func WriteProblem(w http.ResponseWriter, problem ProblemDetails) {
+
+	// Establecer el encabezado Content-Type
+	w.Header().Set("Content-Type", ContentTypeProblem)
+
+	// Establecer el código de estado del response
+	w.WriteHeader(problem.Status)
+
+	// Serializar el problema a JSON y enviarlo en la respuesta
+	json.NewEncoder(w).Encode(problem)
+
+}
+
+// Problem responde con un problema del código de estado y detalle indicados
+func Problem(w http.ResponseWriter, r *http.Request, statusCode int, detail string) {
+	WriteProblem(w, NewProblem(r, statusCode, detail))
+}
@@ -0,0 +1,16 @@
+package web
+
+import "context"
+
+type requestIDKey struct{}
+
+// ContextWithRequestID devuelve un contexto que contiene el ID de la solicitud
+func ContextWithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, requestID)
+}
+
+// RequestIDFromContext devuelve el ID de la solicitud del contexto o, si no tiene, un texto vacío
+func RequestIDFromContext(ctx context.Context) string {
+	requestID, _ := ctx.Value(requestIDKey{}).(string)
+	return requestID
+}
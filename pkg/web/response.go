@@ -9,9 +9,15 @@ type Response struct {
 	Code    int    `json:"code"`
 	Message string `json:"message"`
 	Data    any    `json:"data,omitempty"`
+	Meta    any    `json:"meta,omitempty"`
 }
 
 func Success(w http.ResponseWriter, statusCode int, message string, data interface{}) {
+	SuccessWithMeta(w, statusCode, message, data, nil)
+}
+
+// SuccessWithMeta responde como Success e incluye metadatos de la respuesta (por ejemplo, de paginación)
+func SuccessWithMeta(w http.ResponseWriter, statusCode int, message string, data any, meta any) {
 
 	// Establecer el encabezado Content-Type
 	w.Header().Set("Content-Type", "application/json")
@@ -24,6 +30,7 @@ func Success(w http.ResponseWriter, statusCode int, message string, data interfa
 		Code:    statusCode,
 		Message: message,
 		Data:    data,
+		Meta:    meta,
 	})
 
 	if err != nil {